@@ -221,32 +221,17 @@ func (cacher *Cacher) cacheGet(val *cacheValue, args ...interface{}) (ok bool, d
 	defer conn.Close()
 
 	key := cacher.getKey(args...)
-	if err = redigoplus.Send(conn, "GET", key); err != nil {
-		return
-	}
-	if err = redigoplus.Send(conn, "TTL", key); err != nil {
-		return
-	}
-	if err = conn.Flush(); err != nil {
-		return
-	}
-
-	ok, err = redigoplus.Result(conn.Receive()).Value(val)
-	if err != nil {
+	var expire int64
+	p := redigoplus.NewPipeline(conn)
+	get := p.Value(val, "GET", key)
+	p.Value(&expire, "TTL", key)
+	if err = p.Exec(); err != nil {
 		return false, 0, err
 	}
-	if !ok {
+	if !get.OK() {
 		return
 	}
-
-	var expire int64
-	expire, err = redigo.Int64(conn.Receive())
-	if err != nil {
-		return
-	}
-
-	deadline = time.Now().Unix() + expire
-	return
+	return true, time.Now().Unix() + expire, nil
 }
 
 func init() {
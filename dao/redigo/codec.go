@@ -0,0 +1,450 @@
+package redigo
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	jsonplus "github.com/cheetah-fun-gs/goplus/encoding/json"
+	redigo "github.com/gomodule/redigo/redis"
+)
+
+// hash tag: redis:"name,opt", - 忽略, 未指定为字段名; 未指定名称的嵌入结构体展开
+// 基础类型(字符串/整数/浮点/布尔/[]byte)和 time.Time(RFC3339Nano) 直接存储, 实现 TextMarshaler 和 TextUnmarshaler 的类型存储为文本, 其他类型存储为 json
+// 选项: counter 计数字段 可用 HIncrField; omitempty 零值不写入
+
+var (
+	timeType            = reflect.TypeOf(time.Time{})
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+type hashField struct {
+	name      string
+	index     []int
+	typ       reflect.Type
+	counter   bool
+	omitempty bool
+}
+
+type hashMap struct {
+	fields []*hashField
+	names  map[string]*hashField
+}
+
+var hashMaps sync.Map // reflect.Type -> *hashMap
+
+// collectHashFields 按定义顺序收集所有候选字段 含嵌入结构体的字段
+func collectHashFields(typ reflect.Type, index []int, fields []*hashField) []*hashField {
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		tag := field.Tag.Get("redis")
+		if tag == "-" {
+			continue
+		}
+		splits := strings.Split(tag, ",")
+		name := splits[0]
+		fieldIndex := append(append([]int{}, index...), i)
+
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct && field.Type != timeType {
+			fields = collectHashFields(field.Type, fieldIndex, fields)
+			continue
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		f := &hashField{name: name, index: fieldIndex, typ: field.Type}
+		for _, opt := range splits[1:] {
+			switch opt {
+			case "counter":
+				f.counter = true
+			case "omitempty":
+				f.omitempty = true
+			}
+		}
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+// buildHashMap 同名字段 浅层优先, 同一深度有多个时都忽略 与 Go 的字段遮蔽一致
+func buildHashMap(typ reflect.Type, m *hashMap) {
+	fields := collectHashFields(typ, nil, nil)
+	depths := map[string]int{}
+	counts := map[string]int{}
+	for _, f := range fields {
+		depth, ok := depths[f.name]
+		switch {
+		case !ok || len(f.index) < depth:
+			depths[f.name], counts[f.name] = len(f.index), 1
+		case len(f.index) == depth:
+			counts[f.name]++
+		}
+	}
+	for _, f := range fields {
+		if len(f.index) == depths[f.name] && counts[f.name] == 1 {
+			m.fields = append(m.fields, f)
+			m.names[f.name] = f
+		}
+	}
+}
+
+func getHashMap(typ reflect.Type) *hashMap {
+	if m, ok := hashMaps.Load(typ); ok {
+		return m.(*hashMap)
+	}
+	m := &hashMap{names: map[string]*hashField{}}
+	buildHashMap(typ, m)
+	hashMaps.Store(typ, m)
+	return m
+}
+
+func structValue(v interface{}) (reflect.Value, *hashMap, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return rv, nil, fmt.Errorf("v must be a non-nil struct pointer")
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return rv, nil, fmt.Errorf("v must be struct or pointer of struct")
+	}
+	return rv, getHashMap(rv.Type()), nil
+}
+
+// selectFields 按名称选择字段 names 为空则为全部
+func (m *hashMap) selectFields(names []string) ([]*hashField, error) {
+	if len(names) == 0 {
+		return m.fields, nil
+	}
+	fields := []*hashField{}
+	for _, name := range names {
+		f, ok := m.names[name]
+		if !ok {
+			return nil, fmt.Errorf("field not found: %v", name)
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+// isTextCodec 类型或其指针同时实现 TextMarshaler 和 TextUnmarshaler 时使用文本格式, 编解码判断一致
+func isTextCodec(typ reflect.Type) bool {
+	ptr := reflect.PtrTo(typ)
+	return ptr.Implements(textMarshalerType) && ptr.Implements(textUnmarshalerType)
+}
+
+// encodeHashValue 编码字段值 nil 指针返回 false
+func encodeHashValue(v reflect.Value) (string, bool, error) {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return "", false, nil
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), true, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), true, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), true, nil
+	case reflect.Float32:
+		return strconv.FormatFloat(v.Float(), 'g', -1, 32), true, nil
+	case reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, 64), true, nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), true, nil
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return string(v.Bytes()), true, nil
+		}
+	}
+	if v.Type() == timeType {
+		return v.Interface().(time.Time).Format(time.RFC3339Nano), true, nil
+	}
+	if isTextCodec(v.Type()) {
+		if !v.CanAddr() {
+			pv := reflect.New(v.Type())
+			pv.Elem().Set(v)
+			v = pv.Elem()
+		}
+		data, err := v.Addr().Interface().(encoding.TextMarshaler).MarshalText()
+		return string(data), true, err
+	}
+
+	data, err := jsonplus.Dump(v.Interface())
+	return data, true, err
+}
+
+// decodeHashValue 解码字段值到 v, v 须可设置
+func decodeHashValue(data string, v reflect.Value) error {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return decodeHashValue(data, v.Elem())
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(data)
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(data, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(data, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+		return nil
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(data, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+		return nil
+	case reflect.Bool:
+		b, err := strconv.ParseBool(data)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+		return nil
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			v.SetBytes([]byte(data))
+			return nil
+		}
+	}
+	if v.Type() == timeType {
+		t, err := time.Parse(time.RFC3339Nano, data)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	}
+	if isTextCodec(v.Type()) {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(data))
+	}
+	return jsonplus.Load(data, v.Addr().Interface())
+}
+
+// fieldValue 按路径取字段 途经 nil 嵌入指针返回 false
+func fieldValue(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return v, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+// encodeHash 编码结构体的字段 返回 HSET 的 field value 参数
+func encodeHash(rv reflect.Value, fields []*hashField) ([]interface{}, error) {
+	args := []interface{}{}
+	for _, f := range fields {
+		fv, ok := fieldValue(rv, f.index)
+		if !ok || f.omitempty && fv.IsZero() {
+			continue
+		}
+		data, ok, err := encodeHashValue(fv)
+		if err != nil {
+			return nil, fmt.Errorf("field %v: %v", f.name, err)
+		}
+		if ok {
+			args = append(args, f.name, data)
+		}
+	}
+	return args, nil
+}
+
+// HSetStruct 按 redis tag 写入结构体 fields 为空则写入全部字段, 返回新增的字段数
+func HSetStruct(conn redigo.Conn, key string, v interface{}, fields ...string) (int, error) {
+	rv, m, err := structValue(v)
+	if err != nil {
+		return 0, err
+	}
+	selected, err := m.selectFields(fields)
+	if err != nil {
+		return 0, err
+	}
+	args, err := encodeHash(rv, selected)
+	if err != nil {
+		return 0, err
+	}
+	if len(args) == 0 {
+		return 0, nil
+	}
+	return redigo.Int(conn.Do("HSET", append([]interface{}{key}, args...)...))
+}
+
+// HashChanges 对比两个同类型结构体 返回编码后不同的字段名
+func HashChanges(old, new interface{}) ([]string, error) {
+	oldValue, m, err := structValue(old)
+	if err != nil {
+		return nil, err
+	}
+	newValue, _, err := structValue(new)
+	if err != nil {
+		return nil, err
+	}
+	if oldValue.Type() != newValue.Type() {
+		return nil, fmt.Errorf("type mismatch: %v and %v", oldValue.Type(), newValue.Type())
+	}
+
+	changes := []string{}
+	for _, f := range m.fields {
+		oldArgs, err := encodeHash(oldValue, []*hashField{f})
+		if err != nil {
+			return nil, err
+		}
+		newArgs, err := encodeHash(newValue, []*hashField{f})
+		if err != nil {
+			return nil, err
+		}
+		if !reflect.DeepEqual(oldArgs, newArgs) {
+			changes = append(changes, f.name)
+		}
+	}
+	return changes, nil
+}
+
+// HSetChanged 只写入 new 相对 old 有变化的字段 返回写入的字段名
+// new 中变为 nil 或 omitempty 零值的字段不会被删除
+func HSetChanged(conn redigo.Conn, key string, old, new interface{}) ([]string, error) {
+	changes, err := HashChanges(old, new)
+	if err != nil || len(changes) == 0 {
+		return changes, err
+	}
+	if _, err := HSetStruct(conn, key, new, changes...); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+// HGetStruct 按 redis tag 读取结构体 fields 为空则读取全部字段, 所有字段都不存在时返回 false
+func HGetStruct(conn redigo.Conn, key string, dest interface{}, fields ...string) (bool, error) {
+	rv := reflect.ValueOf(dest)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return false, fmt.Errorf("dest must be a non-nil struct pointer")
+	}
+	rv, m, err := structValue(dest)
+	if err != nil {
+		return false, err
+	}
+	selected, err := m.selectFields(fields)
+	if err != nil {
+		return false, err
+	}
+	if len(selected) == 0 {
+		return false, nil
+	}
+
+	args := []interface{}{key}
+	for _, f := range selected {
+		args = append(args, f.name)
+	}
+	values, err := redigo.Values(conn.Do("HMGET", args...))
+	if err != nil {
+		return false, err
+	}
+
+	ok := false
+	for i, f := range selected {
+		if values[i] == nil {
+			continue
+		}
+		data, err := redigo.String(values[i], nil)
+		if err != nil {
+			return false, err
+		}
+		if err := decodeHashValue(data, fieldByIndex(rv, f.index)); err != nil {
+			return false, fmt.Errorf("field %v: %v", f.name, err)
+		}
+		ok = true
+	}
+	return ok, nil
+}
+
+// fieldByIndex 按路径取字段 途经 nil 嵌入指针会被创建
+func fieldByIndex(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+// counterField 计数字段 dest 须为结构体指针
+func counterField(dest interface{}, field string) (reflect.Value, error) {
+	rv := reflect.ValueOf(dest)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return rv, fmt.Errorf("dest must be a non-nil struct pointer")
+	}
+	rv, m, err := structValue(dest)
+	if err != nil {
+		return rv, err
+	}
+	f, ok := m.names[field]
+	if !ok {
+		return rv, fmt.Errorf("field not found: %v", field)
+	}
+	if !f.counter {
+		return rv, fmt.Errorf("field %v is not counter", field)
+	}
+	return fieldByIndex(rv, f.index), nil
+}
+
+// HIncrField 整数计数字段 HINCRBY delta, 字段须有 counter 选项, dest 的字段更新为增加后的值
+func HIncrField(conn redigo.Conn, key string, dest interface{}, field string, delta int64) (int64, error) {
+	fv, err := counterField(dest, field)
+	if err != nil {
+		return 0, err
+	}
+	n, err := redigo.Int64(conn.Do("HINCRBY", key, field, delta))
+	if err != nil {
+		return 0, err
+	}
+	return n, decodeHashValue(strconv.FormatInt(n, 10), fv)
+}
+
+// HIncrFloatField 浮点计数字段 HINCRBYFLOAT delta, 同 HIncrField
+func HIncrFloatField(conn redigo.Conn, key string, dest interface{}, field string, delta float64) (float64, error) {
+	fv, err := counterField(dest, field)
+	if err != nil {
+		return 0, err
+	}
+	n, err := redigo.Float64(conn.Do("HINCRBYFLOAT", key, field, delta))
+	if err != nil {
+		return 0, err
+	}
+	return n, decodeHashValue(strconv.FormatFloat(n, 'g', -1, 64), fv)
+}
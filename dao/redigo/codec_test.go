@@ -0,0 +1,222 @@
+package redigo
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testLevel 值接收者 MarshalText, 指针接收者 UnmarshalText; 基础类型优先 存储为整数
+type testLevel int
+
+func (l testLevel) MarshalText() ([]byte, error) {
+	return []byte(strings.Repeat("*", int(l))), nil
+}
+
+func (l *testLevel) UnmarshalText(data []byte) error {
+	*l = testLevel(len(data))
+	return nil
+}
+
+// testPoint 都是指针接收者
+type testPoint struct {
+	X, Y int
+}
+
+func (p *testPoint) MarshalText() ([]byte, error) {
+	return []byte(strings.Repeat("x", p.X) + "," + strings.Repeat("y", p.Y)), nil
+}
+
+func (p *testPoint) UnmarshalText(data []byte) error {
+	parts := strings.SplitN(string(data), ",", 2)
+	p.X, p.Y = len(parts[0]), len(parts[1])
+	return nil
+}
+
+type testHashBase struct {
+	ID      int `redis:"id"`
+	Name    string
+	Created time.Time `redis:"created"`
+}
+
+type testHashA struct {
+	Tag string `redis:"tag"`
+}
+
+type testHashB struct {
+	Tag string `redis:"tag"`
+}
+
+type testHashItem struct {
+	testHashA
+	testHashB
+	testHashBase
+	Name  string `redis:"Name"`
+	Score int    `redis:"score,counter"`
+	Note  string `redis:"note,omitempty"`
+	Skip  string `redis:"-"`
+	Level testLevel
+	Point testPoint
+	Extra map[string]int
+	Ptr   *int
+}
+
+func TestGetHashMap(t *testing.T) {
+	m := getHashMap(reflect.TypeOf(testHashItem{}))
+	names := []string{}
+	for _, f := range m.fields {
+		names = append(names, f.name)
+	}
+	want := []string{"id", "created", "Name", "score", "note", "Level", "Point", "Extra", "Ptr"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("getHashMap() names = %v, want %v", names, want)
+	}
+	if f := m.names["Name"]; !reflect.DeepEqual(f.index, []int{3}) {
+		t.Errorf("getHashMap() Name index = %v, want shallow field", f.index)
+	}
+	if !m.names["score"].counter || !m.names["note"].omitempty {
+		t.Errorf("getHashMap() options not parsed")
+	}
+}
+
+func TestHashValue(t *testing.T) {
+	n := 3
+	now := time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC)
+	tests := []struct {
+		name  string
+		value interface{}
+		want  string
+	}{
+		{"string", "a", "a"},
+		{"int8", int8(-3), "-3"},
+		{"uint", uint(3), "3"},
+		{"float32", float32(1.5), "1.5"},
+		{"bool", true, "true"},
+		{"bytes", []byte("ab"), "ab"},
+		{"time", now, "2020-01-02T03:04:05.000000006Z"},
+		{"pointer", &n, "3"},
+		{"basic kind marshaler", testLevel(3), "3"},
+		{"pointer marshaler", testPoint{X: 1, Y: 2}, "x,yy"},
+		{"struct", testHashA{Tag: "a"}, `{"Tag":"a"}`},
+		{"json", map[string]int{"a": 1}, `{"a":1}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 结构体字段取出的值不可寻址
+			data, ok, err := encodeHashValue(reflect.ValueOf(tt.value))
+			if err != nil || !ok || data != tt.want {
+				t.Fatalf("encodeHashValue() = %v, %v, %v, want %v", data, ok, err, tt.want)
+			}
+			dest := reflect.New(reflect.TypeOf(tt.value))
+			if err := decodeHashValue(data, dest.Elem()); err != nil {
+				t.Fatalf("decodeHashValue() error = %v", err)
+			}
+			if got := dest.Elem().Interface(); !reflect.DeepEqual(got, tt.value) {
+				t.Errorf("decodeHashValue() = %#v, want %#v", got, tt.value)
+			}
+		})
+	}
+
+	var nilPtr *int
+	if _, ok, err := encodeHashValue(reflect.ValueOf(nilPtr)); ok || err != nil {
+		t.Errorf("encodeHashValue() nil pointer = %v, %v", ok, err)
+	}
+	var i int
+	if err := decodeHashValue("x", reflect.ValueOf(&i).Elem()); err == nil {
+		t.Errorf("decodeHashValue() bad int want error")
+	}
+}
+
+func TestHashChanges(t *testing.T) {
+	n := 1
+	base := testHashItem{Name: "a", Score: 1, Level: 2, Point: testPoint{X: 1}, Extra: map[string]int{"a": 1}, Ptr: &n}
+	base.ID = 1
+	tests := []struct {
+		name    string
+		change  func(item *testHashItem)
+		want    []string
+		wantErr bool
+	}{
+		{"same", func(item *testHashItem) {}, []string{}, false},
+		{"embedded", func(item *testHashItem) { item.ID = 2 }, []string{"id"}, false},
+		{"shadowed", func(item *testHashItem) { item.testHashBase.Name = "b" }, []string{}, false},
+		{"ignored", func(item *testHashItem) { item.Skip, item.testHashA.Tag = "b", "b" }, []string{}, false},
+		{"fields", func(item *testHashItem) { item.Name, item.Score = "b", 2 }, []string{"Name", "score"}, false},
+		{"text", func(item *testHashItem) { item.Level, item.Point.Y = 3, 1 }, []string{"Level", "Point"}, false},
+		{"json", func(item *testHashItem) { item.Extra = map[string]int{"a": 2} }, []string{"Extra"}, false},
+		{"nil pointer", func(item *testHashItem) { item.Ptr = nil }, []string{"Ptr"}, false},
+		{"same pointer value", func(item *testHashItem) { m := 1; item.Ptr = &m }, []string{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			item := base
+			tt.change(&item)
+			got, err := HashChanges(base, &item)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("HashChanges() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("HashChanges() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := HashChanges(base, &testHashBase{}); err == nil {
+		t.Errorf("HashChanges() type mismatch want error")
+	}
+}
+
+func TestHashStruct(t *testing.T) {
+	hash := map[string]string{}
+	conn := newFakeConn(func(args []string) (interface{}, error) {
+		switch args[0] {
+		case "HSET":
+			added := int64(0)
+			for i := 2; i+1 < len(args); i += 2 {
+				if _, ok := hash[args[i]]; !ok {
+					added++
+				}
+				hash[args[i]] = args[i+1]
+			}
+			return added, nil
+		case "HMGET":
+			values := []interface{}{}
+			for _, field := range args[2:] {
+				if v, ok := hash[field]; ok {
+					values = append(values, []byte(v))
+				} else {
+					values = append(values, nil)
+				}
+			}
+			return values, nil
+		}
+		return nil, nil
+	})
+
+	n := 7
+	item := &testHashItem{Name: "a", Score: 2, Level: 3, Point: testPoint{X: 1, Y: 1}, Ptr: &n}
+	item.ID = 1
+	item.Created = time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	if _, err := HSetStruct(conn, "k", item); err != nil {
+		t.Fatalf("HSetStruct() error = %v", err)
+	}
+	if _, ok := hash["note"]; ok {
+		t.Errorf("HSetStruct() omitempty field written")
+	}
+
+	got := &testHashItem{}
+	if ok, err := HGetStruct(conn, "k", got); err != nil || !ok {
+		t.Fatalf("HGetStruct() = %v, %v", ok, err)
+	}
+	if !reflect.DeepEqual(got, item) {
+		t.Errorf("HGetStruct() = %+v, want %+v", got, item)
+	}
+
+	if ok, err := HGetStruct(conn, "missing", &testHashItem{}, "note"); err != nil || ok {
+		t.Errorf("HGetStruct() missing = %v, %v", ok, err)
+	}
+	if _, err := HSetStruct(conn, "k", item, "unknown"); err == nil {
+		t.Errorf("HSetStruct() unknown field want error")
+	}
+}
@@ -0,0 +1,419 @@
+package redigo
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	jsonplus "github.com/cheetah-fun-gs/goplus/encoding/json"
+	mlogger "github.com/cheetah-fun-gs/goplus/multier/multilogger"
+	uuidplus "github.com/cheetah-fun-gs/goplus/uuid"
+	redigo "github.com/gomodule/redigo/redis"
+)
+
+// 延迟队列的 key: name:delayed 有序集合 id -> 到期时间, name:ready 列表 已到期的 id,
+// name:running 有序集合 id -> 可见性超时时间, name:jobs 哈希 id -> 负载, name:attempts 哈希 id -> 执行次数
+// name:tokens 哈希 id -> 最近一次取出的令牌, name:dead 列表 超过最大次数的任务
+
+// 到期的任务移入 ready, 可见性超时的任务重新移入 ready, ARGV 依次为 now(毫秒) limit, 返回移动的数量
+var delayPromoteScript = NewScript(3, `local moved = 0
+	for i = 1, 2 do
+		local ids = redis.call("ZRANGEBYSCORE", KEYS[i], "-inf", ARGV[1], "LIMIT", 0, ARGV[2])
+		for _, id in ipairs(ids) do
+			redis.call("ZREM", KEYS[i], id)
+			redis.call("RPUSH", KEYS[3], id)
+			moved = moved + 1
+		end
+	end
+	return moved`)
+
+// 取出一个 ready 的任务 加入 running 记录令牌并增加次数, 已取消的任务跳过
+// KEYS 依次为 ready running jobs attempts tokens, ARGV 依次为 可见性超时时间(毫秒) 令牌, 返回 {id, payload, attempts} 或 nil
+var delayReserveScript = NewScript(5, `while true do
+		local id = redis.call("LPOP", KEYS[1])
+		if not id
+		then
+			return nil
+		end
+		local payload = redis.call("HGET", KEYS[3], id)
+		if payload
+		then
+			redis.call("ZADD", KEYS[2], ARGV[1], id)
+			redis.call("HSET", KEYS[5], id, ARGV[2])
+			local attempts = redis.call("HINCRBY", KEYS[4], id, 1)
+			return {id, payload, attempts}
+		end
+	end`)
+
+// 写入任务 已存在的任务从 ready 和 running 中移除, 覆盖负载并重置次数和令牌
+// KEYS 依次为 delayed ready running jobs attempts tokens, ARGV 依次为 id payload 到期时间(毫秒)
+var delayPushScript = NewScript(6, `redis.call("LREM", KEYS[2], 0, ARGV[1])
+	redis.call("ZREM", KEYS[3], ARGV[1])
+	redis.call("HSET", KEYS[4], ARGV[1], ARGV[2])
+	redis.call("HDEL", KEYS[5], ARGV[1])
+	redis.call("HDEL", KEYS[6], ARGV[1])
+	redis.call("ZADD", KEYS[1], ARGV[3], ARGV[1])
+	return 1`)
+
+// 完成 令牌一致且仍在 running 中时删除任务, 已被重新写入、重新取出或可见性超时的不删除
+// KEYS 依次为 running jobs attempts tokens, ARGV 依次为 id 令牌, 返回是否删除
+var delayAckScript = NewScript(4, `if redis.call("HGET", KEYS[4], ARGV[1]) == ARGV[2] and redis.call("ZREM", KEYS[1], ARGV[1]) == 1
+	then
+		redis.call("HDEL", KEYS[2], ARGV[1])
+		redis.call("HDEL", KEYS[3], ARGV[1])
+		redis.call("HDEL", KEYS[4], ARGV[1])
+		return 1
+	end
+	return 0`)
+
+// 移入死信 令牌一致且仍在 running 中时删除任务并写入 dead
+// KEYS 依次为 running jobs attempts tokens dead, ARGV 依次为 id 令牌 死信内容
+var delayBuryScript = NewScript(5, `if redis.call("HGET", KEYS[4], ARGV[1]) == ARGV[2] and redis.call("ZREM", KEYS[1], ARGV[1]) == 1
+	then
+		redis.call("HDEL", KEYS[2], ARGV[1])
+		redis.call("HDEL", KEYS[3], ARGV[1])
+		redis.call("HDEL", KEYS[4], ARGV[1])
+		redis.call("RPUSH", KEYS[5], ARGV[3])
+		return 1
+	end
+	return 0`)
+
+// 重试 令牌一致且仍在 running 中时移入 delayed
+// KEYS 依次为 running delayed tokens, ARGV 依次为 id 令牌 到期时间(毫秒)
+var delayRetryScript = NewScript(3, `if redis.call("HGET", KEYS[3], ARGV[1]) == ARGV[2] and redis.call("ZREM", KEYS[1], ARGV[1]) == 1
+	then
+		redis.call("HDEL", KEYS[3], ARGV[1])
+		redis.call("ZADD", KEYS[2], ARGV[3], ARGV[1])
+		return 1
+	end
+	return 0`)
+
+// 删除任务 KEYS 依次为 delayed ready running jobs attempts tokens, ARGV[1] 为 id, 返回任务是否存在
+var delayRemoveScript = NewScript(6, `redis.call("ZREM", KEYS[1], ARGV[1])
+	redis.call("LREM", KEYS[2], 0, ARGV[1])
+	redis.call("ZREM", KEYS[3], ARGV[1])
+	redis.call("HDEL", KEYS[5], ARGV[1])
+	redis.call("HDEL", KEYS[6], ARGV[1])
+	return redis.call("HDEL", KEYS[4], ARGV[1])`)
+
+// DelayJob 延迟任务
+type DelayJob struct {
+	ID       string
+	Payload  string // json 编码的负载
+	Attempts int    // 第几次执行 从 1 开始
+	Token    string // 本次取出的令牌 完成、重试和移入死信时校验, 任务被重新取出或写入后旧令牌失效
+}
+
+// Scan 负载解析进 dest
+func (job *DelayJob) Scan(dest interface{}) error {
+	return jsonplus.Load(job.Payload, dest)
+}
+
+// DelayHandler 任务处理函数 返回 nil 时任务完成, 否则按 Backoff 重试
+// ctx 在 worker 停止时取消
+type DelayHandler func(ctx context.Context, job *DelayJob) error
+
+// DelayQueueOptions 延迟队列参数
+type DelayQueueOptions struct {
+	PollInterval time.Duration                    // 轮询间隔 默认 1s
+	Concurrency  int                              // 并发处理数 默认 1
+	Visibility   time.Duration                    // 可见性超时 处理超过该时间未完成的任务重新投递, 须大于处理耗时 默认 30s
+	MaxAttempts  int                              // 最大执行次数 超过后移入 name:dead, 0 不限制
+	Backoff      func(attempts int) time.Duration // 第 attempts 次失败后的重试间隔 默认 1s 起指数增长 最大 10min
+	MLogName     string                           // 错误日志 默认 default
+}
+
+// DelayQueue 基于有序集合的延迟队列 负载使用 json 编码
+//
+//	q := redigoplus.NewDelayQueue(pool, "offer:expire", nil)
+//	id, err := q.Push(offerID, 30*time.Minute)
+//	go q.Run(context.Background(), handler)
+type DelayQueue struct {
+	pool   *redigo.Pool
+	name   string
+	opts   DelayQueueOptions
+	mutex  sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewDelayQueue ...
+func NewDelayQueue(pool *redigo.Pool, name string, opts *DelayQueueOptions) *DelayQueue {
+	q := &DelayQueue{pool: pool, name: name}
+	if opts != nil {
+		q.opts = *opts
+	}
+	if q.opts.PollInterval <= 0 {
+		q.opts.PollInterval = time.Second
+	}
+	if q.opts.Concurrency <= 0 {
+		q.opts.Concurrency = 1
+	}
+	if q.opts.Visibility <= 0 {
+		q.opts.Visibility = 30 * time.Second
+	}
+	if q.opts.Backoff == nil {
+		q.opts.Backoff = defaultBackoff
+	}
+	if q.opts.MLogName == "" {
+		q.opts.MLogName = "default"
+	}
+	return q
+}
+
+func defaultBackoff(attempts int) time.Duration {
+	backoff := time.Second
+	for i := 1; i < attempts && backoff < 10*time.Minute; i++ {
+		backoff *= 2
+	}
+	if backoff > 10*time.Minute {
+		backoff = 10 * time.Minute
+	}
+	return backoff
+}
+
+func (q *DelayQueue) key(suffix string) string {
+	return q.name + ":" + suffix
+}
+
+// Push 延迟 delay 后执行 返回任务 id
+func (q *DelayQueue) Push(payload interface{}, delay time.Duration) (string, error) {
+	return q.PushAt("", payload, time.Now().Add(delay))
+}
+
+// PushAt 在 at 时执行 id 为空时自动生成, 已存在的 id 覆盖负载和执行时间 并重置次数, 执行中的旧任务完成后不会删除新任务
+func (q *DelayQueue) PushAt(id string, payload interface{}, at time.Time) (string, error) {
+	data, err := jsonplus.Dump(payload)
+	if err != nil {
+		return "", err
+	}
+	if id == "" {
+		id = uuidplus.NewV4().Base62()
+	}
+
+	conn := q.pool.Get()
+	defer conn.Close()
+
+	_, err = delayPushScript.Do(conn,
+		q.key("delayed"), q.key("ready"), q.key("running"), q.key("jobs"), q.key("attempts"), q.key("tokens"), id, data, unixMilli(at)).Reply()
+	if err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// Cancel 取消任务 返回任务是否存在, 执行中的任务不会被中断 但不会再重试
+func (q *DelayQueue) Cancel(id string) (bool, error) {
+	conn := q.pool.Get()
+	defer conn.Close()
+
+	n, err := redigo.Int(delayRemoveScript.Do(conn,
+		q.key("delayed"), q.key("ready"), q.key("running"), q.key("jobs"), q.key("attempts"), q.key("tokens"), id).Reply())
+	return n == 1, err
+}
+
+// Len 未完成的任务数量 含延迟中、已到期和执行中的
+func (q *DelayQueue) Len() (int, error) {
+	conn := q.pool.Get()
+	defer conn.Close()
+
+	return redigo.Int(conn.Do("HLEN", q.key("jobs")))
+}
+
+// Run 轮询到期的任务并处理 直到 ctx 取消或 Stop, 停止时等待处理中的任务完成后返回
+func (q *DelayQueue) Run(ctx context.Context, handler DelayHandler) error {
+	q.mutex.Lock()
+	if q.cancel != nil {
+		q.mutex.Unlock()
+		return fmt.Errorf("delay queue is running")
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	q.cancel, q.done = cancel, done
+	q.mutex.Unlock()
+
+	defer func() {
+		cancel()
+		q.mutex.Lock()
+		q.cancel, q.done = nil, nil
+		q.mutex.Unlock()
+		close(done)
+	}()
+
+	sem := make(chan struct{}, q.opts.Concurrency)
+	wg := &sync.WaitGroup{}
+	for ctx.Err() == nil {
+		if err := q.promote(); err != nil {
+			mlogger.WarnN(q.opts.MLogName, "delay queue %v promote err: %v", q.name, err)
+		}
+		q.dispatch(ctx, handler, sem, wg)
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(q.opts.PollInterval):
+		}
+	}
+	wg.Wait()
+	return nil
+}
+
+// Stop 停止 并等待 Run 返回
+func (q *DelayQueue) Stop() {
+	q.mutex.Lock()
+	cancel, done := q.cancel, q.done
+	q.mutex.Unlock()
+	if cancel == nil {
+		return
+	}
+	cancel()
+	<-done
+}
+
+// promote 到期和可见性超时的任务移入 ready
+func (q *DelayQueue) promote() error {
+	conn := q.pool.Get()
+	defer conn.Close()
+
+	now := unixMilli(time.Now())
+	_, err := delayPromoteScript.Do(conn, q.key("delayed"), q.key("running"), q.key("ready"), now, 1000).Reply()
+	return err
+}
+
+// dispatch 有空闲的并发时取出任务处理 直到没有 ready 的任务
+func (q *DelayQueue) dispatch(ctx context.Context, handler DelayHandler, sem chan struct{}, wg *sync.WaitGroup) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case sem <- struct{}{}:
+		}
+
+		job, err := q.reserve()
+		if err != nil {
+			mlogger.WarnN(q.opts.MLogName, "delay queue %v reserve err: %v", q.name, err)
+		}
+		if job == nil {
+			<-sem
+			return
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			q.handle(ctx, handler, job)
+		}()
+	}
+}
+
+func (q *DelayQueue) reserve() (*DelayJob, error) {
+	conn := q.pool.Get()
+	defer conn.Close()
+
+	deadline := unixMilli(time.Now().Add(q.opts.Visibility))
+	token := uuidplus.NewV4().Base62()
+	values, err := redigo.Values(delayReserveScript.Do(conn,
+		q.key("ready"), q.key("running"), q.key("jobs"), q.key("attempts"), q.key("tokens"), deadline, token).Reply())
+	if err == redigo.ErrNil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(values) != 3 {
+		return nil, fmt.Errorf("invalid reserve reply: %v", values)
+	}
+	job := &DelayJob{Token: token}
+	if job.ID, err = redigo.String(values[0], nil); err != nil {
+		return nil, err
+	}
+	if job.Payload, err = redigo.String(values[1], nil); err != nil {
+		return nil, err
+	}
+	if job.Attempts, err = redigo.Int(values[2], nil); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+func (q *DelayQueue) handle(ctx context.Context, handler DelayHandler, job *DelayJob) {
+	err := func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("panic: %v", r)
+			}
+		}()
+		return handler(ctx, job)
+	}()
+
+	if err == nil {
+		if err := q.ack(job); err != nil {
+			mlogger.WarnN(q.opts.MLogName, "delay queue %v ack %v err: %v", q.name, job.ID, err)
+		}
+		return
+	}
+
+	mlogger.WarnN(q.opts.MLogName, "delay queue %v handle %v attempts %v err: %v", q.name, job.ID, job.Attempts, err)
+	if q.opts.MaxAttempts > 0 && job.Attempts >= q.opts.MaxAttempts {
+		if err := q.bury(job, err); err != nil {
+			mlogger.WarnN(q.opts.MLogName, "delay queue %v bury %v err: %v", q.name, job.ID, err)
+		}
+		return
+	}
+	if err := q.retry(job); err != nil {
+		mlogger.WarnN(q.opts.MLogName, "delay queue %v retry %v err: %v", q.name, job.ID, err)
+	}
+}
+
+// ack 完成 令牌已失效(已重新写入或重新取出)或任务已不在 running 中时(可见性超时或已取消)忽略
+func (q *DelayQueue) ack(job *DelayJob) error {
+	conn := q.pool.Get()
+	defer conn.Close()
+
+	_, err := delayAckScript.Do(conn, q.key("running"), q.key("jobs"), q.key("attempts"), q.key("tokens"), job.ID, job.Token).Reply()
+	return err
+}
+
+// retry 按 Backoff 延迟后重试 令牌已失效或任务已不在 running 中时忽略
+func (q *DelayQueue) retry(job *DelayJob) error {
+	conn := q.pool.Get()
+	defer conn.Close()
+
+	at := unixMilli(time.Now().Add(q.opts.Backoff(job.Attempts)))
+	_, err := delayRetryScript.Do(conn, q.key("running"), q.key("delayed"), q.key("tokens"), job.ID, job.Token, at).Reply()
+	return err
+}
+
+// delayDeadJob name:dead 中的任务
+type delayDeadJob struct {
+	ID       string `json:"id"`
+	Payload  string `json:"payload"`
+	Attempts int    `json:"attempts"`
+	Error    string `json:"error"`
+	Time     int64  `json:"time"`
+}
+
+// bury 移入 name:dead 并删除任务 令牌已失效或任务已不在 running 中时忽略
+func (q *DelayQueue) bury(job *DelayJob, cause error) error {
+	data, err := jsonplus.Dump(&delayDeadJob{
+		ID:       job.ID,
+		Payload:  job.Payload,
+		Attempts: job.Attempts,
+		Error:    cause.Error(),
+		Time:     time.Now().Unix(),
+	})
+	if err != nil {
+		return err
+	}
+
+	conn := q.pool.Get()
+	defer conn.Close()
+
+	_, err = delayBuryScript.Do(conn, q.key("running"), q.key("jobs"), q.key("attempts"), q.key("tokens"), q.key("dead"), job.ID, job.Token, data).Reply()
+	return err
+}
+
+func unixMilli(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
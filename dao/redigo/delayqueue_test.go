@@ -0,0 +1,309 @@
+package redigo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	redigo "github.com/gomodule/redigo/redis"
+)
+
+func TestDefaultBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{0, time.Second},
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{5, 16 * time.Second},
+		{10, 512 * time.Second},
+		{11, 10 * time.Minute},
+		{100, 10 * time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.attempts), func(t *testing.T) {
+			if got := defaultBackoff(tt.attempts); got != tt.want {
+				t.Errorf("defaultBackoff() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDelayQueuePushAt(t *testing.T) {
+	conn := newFakeConn(func(args []string) (interface{}, error) {
+		return int64(1), nil
+	})
+	q := NewDelayQueue(fakePool(conn), "q", nil)
+	at := time.Unix(10, 0)
+	id, err := q.PushAt("job", map[string]int{"a": 1}, at)
+	if err != nil || id != "job" {
+		t.Fatalf("PushAt() = %v, %v", id, err)
+	}
+	// 写入、移出 ready/running 和重置次数在同一脚本内
+	want := "EVALSHA " + delayPushScript.Hash() + ` 6 q:delayed q:ready q:running q:jobs q:attempts q:tokens job {"a":1} 10000`
+	if len(conn.commands) != 1 || conn.commands[0] != want {
+		t.Errorf("PushAt() commands = %q, want %q", conn.commands, want)
+	}
+
+	conn.commands = nil
+	if id, err := q.Push("x", time.Second); err != nil || id == "" {
+		t.Errorf("Push() = %v, %v", id, err)
+	}
+	if _, err := q.PushAt("", make(chan int), at); err == nil {
+		t.Errorf("PushAt() bad payload want error")
+	}
+}
+
+func TestDelayQueueReserve(t *testing.T) {
+	tests := []struct {
+		name    string
+		reply   interface{}
+		err     error
+		want    *DelayJob
+		wantErr bool
+	}{
+		{"empty", nil, nil, nil, false},
+		{"job", []interface{}{[]byte("a"), []byte(`{"a":1}`), int64(2)}, nil, &DelayJob{ID: "a", Payload: `{"a":1}`, Attempts: 2}, false},
+		{"short reply", []interface{}{[]byte("a")}, nil, nil, true},
+		{"bad attempts", []interface{}{[]byte("a"), []byte("{}"), []byte("x")}, nil, nil, true},
+		{"redis error", nil, redigo.Error("ERR"), nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := newFakeConn(func(args []string) (interface{}, error) {
+				return tt.reply, tt.err
+			})
+			q := NewDelayQueue(fakePool(conn), "q", nil)
+			got, err := q.reserve()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("reserve() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != nil {
+				if got.Token == "" {
+					t.Errorf("reserve() empty token")
+				}
+				got.Token = ""
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("reserve() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDelayQueueHandle(t *testing.T) {
+	boom := errors.New("boom")
+	tests := []struct {
+		name     string
+		attempts int
+		err      error
+		panics   bool
+		want     *Script
+	}{
+		{"ack", 1, nil, false, delayAckScript},
+		{"retry", 1, boom, false, delayRetryScript},
+		{"retry on panic", 1, nil, true, delayRetryScript},
+		{"bury", 3, boom, false, delayBuryScript},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := newFakeConn(func(args []string) (interface{}, error) {
+				return int64(1), nil
+			})
+			q := NewDelayQueue(fakePool(conn), "q", &DelayQueueOptions{MaxAttempts: 3})
+			job := &DelayJob{ID: "job", Payload: "{}", Attempts: tt.attempts}
+			q.handle(context.Background(), func(ctx context.Context, job *DelayJob) error {
+				if tt.panics {
+					panic("boom")
+				}
+				return tt.err
+			}, job)
+
+			if len(conn.commands) != 1 || !strings.HasPrefix(conn.commands[0], "EVALSHA "+tt.want.Hash()+" ") {
+				t.Errorf("handle() commands = %q, want script %v", conn.commands, tt.want.Hash())
+			}
+		})
+	}
+}
+
+// newMiniredisQueue 使用 miniredis 执行真实脚本的队列 可见性超时 1ms, 用完调用 cleanup
+func newMiniredisQueue(t *testing.T) (q *DelayQueue, conn redigo.Conn, cleanup func()) {
+	server, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := &redigo.Pool{
+		Dial: func() (redigo.Conn, error) {
+			return redigo.Dial("tcp", server.Addr())
+		},
+	}
+	conn, err = redigo.Dial("tcp", server.Addr())
+	if err != nil {
+		server.Close()
+		t.Fatal(err)
+	}
+	cleanup = func() {
+		conn.Close()
+		pool.Close()
+		server.Close()
+	}
+	return NewDelayQueue(pool, "q", &DelayQueueOptions{Visibility: time.Millisecond}), conn, cleanup
+}
+
+// reserveDue 到期任务移入 ready 后取出一个
+func reserveDue(t *testing.T, q *DelayQueue) *DelayJob {
+	if err := q.promote(); err != nil {
+		t.Fatalf("promote() error = %v", err)
+	}
+	job, err := q.reserve()
+	if err != nil || job == nil {
+		t.Fatalf("reserve() = %+v, %v", job, err)
+	}
+	return job
+}
+
+func TestDelayQueueStaleToken(t *testing.T) {
+	past := time.Now().Add(-time.Second)
+	ops := map[string]func(q *DelayQueue, job *DelayJob) error{
+		"ack":   func(q *DelayQueue, job *DelayJob) error { return q.ack(job) },
+		"retry": func(q *DelayQueue, job *DelayJob) error { return q.retry(job) },
+		"bury":  func(q *DelayQueue, job *DelayJob) error { return q.bury(job, errors.New("boom")) },
+	}
+	tests := []struct {
+		name        string
+		stale       func(t *testing.T, q *DelayQueue, job *DelayJob) *DelayJob // 使 job 的令牌失效 返回当前的任务
+		wantPayload string
+		wantRunning []string
+		wantDelayed []string
+	}{
+		{
+			name: "re-reserved",
+			stale: func(t *testing.T, q *DelayQueue, job *DelayJob) *DelayJob {
+				time.Sleep(5 * time.Millisecond) // 可见性超时
+				current := reserveDue(t, q)
+				if current.Attempts != 2 || current.Token == job.Token {
+					t.Fatalf("reserve() again = %+v, old %+v", current, job)
+				}
+				return current
+			},
+			wantPayload: `{"v":1}`,
+			wantRunning: []string{"job"},
+			wantDelayed: []string{},
+		},
+		{
+			name: "re-pushed",
+			stale: func(t *testing.T, q *DelayQueue, job *DelayJob) *DelayJob {
+				if _, err := q.PushAt("job", map[string]int{"v": 2}, time.Now().Add(time.Hour)); err != nil {
+					t.Fatal(err)
+				}
+				return nil
+			},
+			wantPayload: `{"v":2}`,
+			wantRunning: []string{},
+			wantDelayed: []string{"job"},
+		},
+		{
+			name: "re-pushed and reserved",
+			stale: func(t *testing.T, q *DelayQueue, job *DelayJob) *DelayJob {
+				if _, err := q.PushAt("job", map[string]int{"v": 2}, past); err != nil {
+					t.Fatal(err)
+				}
+				current := reserveDue(t, q)
+				if current.Attempts != 1 || current.Payload != `{"v":2}` {
+					t.Fatalf("reserve() re-pushed = %+v", current)
+				}
+				return current
+			},
+			wantPayload: `{"v":2}`,
+			wantRunning: []string{"job"},
+			wantDelayed: []string{},
+		},
+	}
+	for _, tt := range tests {
+		for opName, op := range ops {
+			t.Run(tt.name+" "+opName, func(t *testing.T) {
+				q, conn, cleanup := newMiniredisQueue(t)
+				defer cleanup()
+				if _, err := q.PushAt("job", map[string]int{"v": 1}, past); err != nil {
+					t.Fatal(err)
+				}
+				job := reserveDue(t, q)
+				current := tt.stale(t, q, job)
+
+				if err := op(q, job); err != nil {
+					t.Fatalf("%v() error = %v", opName, err)
+				}
+				if payload, _ := redigo.String(conn.Do("HGET", "q:jobs", "job")); payload != tt.wantPayload {
+					t.Errorf("payload = %q, want %q", payload, tt.wantPayload)
+				}
+				if running, _ := redigo.Strings(conn.Do("ZRANGE", "q:running", 0, -1)); fmt.Sprint(running) != fmt.Sprint(tt.wantRunning) {
+					t.Errorf("running = %v, want %v", running, tt.wantRunning)
+				}
+				if delayed, _ := redigo.Strings(conn.Do("ZRANGE", "q:delayed", 0, -1)); fmt.Sprint(delayed) != fmt.Sprint(tt.wantDelayed) {
+					t.Errorf("delayed = %v, want %v", delayed, tt.wantDelayed)
+				}
+				if dead, _ := redigo.Int(conn.Do("LLEN", "q:dead")); dead != 0 {
+					t.Errorf("dead = %v, want 0", dead)
+				}
+
+				// 当前令牌仍可完成
+				if current != nil {
+					if err := q.ack(current); err != nil {
+						t.Fatalf("ack() current error = %v", err)
+					}
+					if n, _ := q.Len(); n != 0 {
+						t.Errorf("Len() after ack current = %v, want 0", n)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestDelayQueueToken(t *testing.T) {
+	q, conn, cleanup := newMiniredisQueue(t)
+	defer cleanup()
+	q.opts.Visibility = time.Minute
+	if _, err := q.PushAt("job", map[string]int{"v": 1}, time.Now().Add(-time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	job := reserveDue(t, q)
+	if err := q.retry(job); err != nil {
+		t.Fatal(err)
+	}
+	if delayed, _ := redigo.Strings(conn.Do("ZRANGE", "q:delayed", 0, -1)); fmt.Sprint(delayed) != "[job]" {
+		t.Errorf("retry() delayed = %v", delayed)
+	}
+	if token, err := conn.Do("HGET", "q:tokens", "job"); token != nil || err != nil {
+		t.Errorf("retry() token = %v, %v, want removed", token, err)
+	}
+	// 重试后旧令牌不能再次完成
+	if err := q.ack(job); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := q.Len(); n != 1 {
+		t.Errorf("Len() after stale ack = %v, want 1", n)
+	}
+
+	if _, err := conn.Do("ZADD", "q:delayed", 0, "job"); err != nil {
+		t.Fatal(err)
+	}
+	job = reserveDue(t, q)
+	if err := q.bury(job, errors.New("boom")); err != nil {
+		t.Fatal(err)
+	}
+	if dead, _ := redigo.Int(conn.Do("LLEN", "q:dead")); dead != 1 {
+		t.Errorf("bury() dead = %v, want 1", dead)
+	}
+	if n, _ := redigo.Int(conn.Do("HLEN", "q:tokens")); n != 0 {
+		t.Errorf("bury() tokens = %v, want 0", n)
+	}
+	if n, _ := q.Len(); n != 0 {
+		t.Errorf("Len() after bury = %v, want 0", n)
+	}
+}
@@ -0,0 +1,90 @@
+package redigo
+
+import (
+	"fmt"
+	"strings"
+
+	redigo "github.com/gomodule/redigo/redis"
+)
+
+// fakeConn 假连接 命令按 handler 返回结果, 记录收到的命令
+type fakeConn struct {
+	handler  func(args []string) (interface{}, error)
+	commands []string
+	pending  []fakeReply
+}
+
+type fakeReply struct {
+	reply interface{}
+	err   error
+}
+
+func newFakeConn(handler func(args []string) (interface{}, error)) *fakeConn {
+	return &fakeConn{handler: handler}
+}
+
+// resetLoadedScripts 清空已加载脚本的缓存 避免测试之间相互影响
+func resetLoadedScripts() {
+	loadedScripts.Lock()
+	loadedScripts.m = map[string]bool{}
+	loadedScripts.Unlock()
+}
+
+// fakePool 每次取连接都返回同一个假连接
+func fakePool(conn *fakeConn) *redigo.Pool {
+	return &redigo.Pool{
+		Dial: func() (redigo.Conn, error) {
+			return conn, nil
+		},
+	}
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Err() error {
+	return nil
+}
+
+func (c *fakeConn) Send(commandName string, args ...interface{}) error {
+	strs := []string{commandName}
+	for _, arg := range args {
+		if data, ok := arg.([]byte); ok {
+			strs = append(strs, string(data))
+		} else {
+			strs = append(strs, fmt.Sprint(arg))
+		}
+	}
+	c.commands = append(c.commands, strings.Join(strs, " "))
+	reply, err := c.handler(strs)
+	c.pending = append(c.pending, fakeReply{reply: reply, err: err})
+	return nil
+}
+
+func (c *fakeConn) Flush() error {
+	return nil
+}
+
+func (c *fakeConn) Receive() (interface{}, error) {
+	if len(c.pending) == 0 {
+		return nil, fmt.Errorf("no pending reply")
+	}
+	r := c.pending[0]
+	c.pending = c.pending[1:]
+	return r.reply, r.err
+}
+
+func (c *fakeConn) Do(commandName string, args ...interface{}) (interface{}, error) {
+	if commandName != "" {
+		if err := c.Send(commandName, args...); err != nil {
+			return nil, err
+		}
+	}
+	var reply interface{}
+	var err error
+	for len(c.pending) > 0 {
+		reply, err = c.Receive()
+	}
+	return reply, err
+}
@@ -26,7 +26,7 @@ func HGet(conn redigo.Conn, key, field string, dest interface{}) (bool, error) {
 	return Result(conn.Do("HGET", key, field)).StringToJSON(dest)
 }
 
-// HMSet HMSet v map[string]***{} or struct
+// HMSet HMSet v map[string]***{} or struct, 结构体建议使用 HSetStruct 保留字段类型
 func HMSet(conn redigo.Conn, key string, v interface{}) (int, error) {
 	data := map[string]interface{}{}
 
@@ -49,7 +49,7 @@ func HMSet(conn redigo.Conn, key string, v interface{}) (int, error) {
 	return redigo.Int(Do(conn, "HSET", args...))
 }
 
-// HMGet HMGet dest map[string]***{} or struct 的指针
+// HMGet HMGet dest map[string]***{} or struct 的指针, 结构体建议使用 HGetStruct
 func HMGet(conn redigo.Conn, key string, dest interface{}) error {
 	data := map[string]interface{}{}
 
@@ -0,0 +1,475 @@
+package redigo
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+	"strconv"
+	"time"
+
+	jsonplus "github.com/cheetah-fun-gs/goplus/encoding/json"
+	redigo "github.com/gomodule/redigo/redis"
+)
+
+// LeaderboardPeriod 榜单周期
+type LeaderboardPeriod int
+
+// LeaderboardPeriod 定义
+const (
+	LeaderboardAll     LeaderboardPeriod = iota // 总榜 不过期
+	LeaderboardDaily                            // 日榜
+	LeaderboardWeekly                           // 周榜 周一开始
+	LeaderboardMonthly                          // 月榜
+)
+
+// 总榜同分排序的起始时间 时间跨度 2^32 秒
+var leaderboardEpoch = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// LeaderboardOptions 榜单参数
+type LeaderboardOptions struct {
+	Ascending bool              // 分数低的排名靠前 如用时, 默认分数高的靠前
+	TieBreak  bool              // 同分时先达到者靠前, 分数须为整数, 绝对值上限: 日榜 2^36 周榜 2^33 月榜 2^31 总榜 2^21
+	Period    LeaderboardPeriod // 周期 key 为 name:日期
+	Location  *time.Location    // 周期划分的时区 默认 time.Local
+	Retention time.Duration     // 周期结束后保留的时间 默认一个周期
+}
+
+// LeaderboardEntry 榜单条目
+type LeaderboardEntry struct {
+	Member string  // json 编码的成员
+	Rank   int     // 排名 从 1 开始
+	Score  float64 // 分数 不含同分排序的时间
+}
+
+// Scan 成员解析进 dest
+func (entry *LeaderboardEntry) Scan(dest interface{}) error {
+	return jsonplus.Load(entry.Member, dest)
+}
+
+// Leaderboard 基于有序集合的排行榜 成员使用 json 编码, 与 ZFind 一致
+//
+//	lb := redigoplus.NewLeaderboard(pool, "rank:level", &redigoplus.LeaderboardOptions{TieBreak: true, Period: redigoplus.LeaderboardDaily})
+//	lb.SetIfBetter(uid, 100)
+//	entries, err := lb.Page(1, 20)
+type Leaderboard struct {
+	pool *redigo.Pool
+	name string
+	opts LeaderboardOptions
+	key  string    // 固定的 key 如合并的榜单
+	at   time.Time // 指定时间所在周期的榜单 零值为当前
+}
+
+// NewLeaderboard ...
+func NewLeaderboard(pool *redigo.Pool, name string, opts *LeaderboardOptions) *Leaderboard {
+	lb := &Leaderboard{pool: pool, name: name}
+	if opts != nil {
+		lb.opts = *opts
+	}
+	if lb.opts.Location == nil {
+		lb.opts.Location = time.Local
+	}
+	return lb
+}
+
+// At 指定时间所在周期的榜单 如昨日榜单
+func (lb *Leaderboard) At(t time.Time) *Leaderboard {
+	at := *lb
+	at.at = t
+	return &at
+}
+
+func (lb *Leaderboard) now() time.Time {
+	if lb.at.IsZero() {
+		return time.Now()
+	}
+	return lb.at
+}
+
+// bucket 时间所在周期的起止时间和 key
+func (lb *Leaderboard) bucket(t time.Time) (start, end time.Time, key string) {
+	t = t.In(lb.opts.Location)
+	year, month, day := t.Date()
+	switch lb.opts.Period {
+	case LeaderboardDaily:
+		start = time.Date(year, month, day, 0, 0, 0, 0, lb.opts.Location)
+		end = start.AddDate(0, 0, 1)
+		key = start.Format("20060102")
+	case LeaderboardWeekly:
+		offset := (int(t.Weekday()) + 6) % 7
+		start = time.Date(year, month, day-offset, 0, 0, 0, 0, lb.opts.Location)
+		end = start.AddDate(0, 0, 7)
+		key = start.Format("20060102")
+	case LeaderboardMonthly:
+		start = time.Date(year, month, 1, 0, 0, 0, 0, lb.opts.Location)
+		end = start.AddDate(0, 1, 0)
+		key = start.Format("200601")
+	default:
+		return leaderboardEpoch, time.Time{}, lb.name
+	}
+	return start, end, lb.name + ":" + key
+}
+
+// Key 当前榜单的 key
+func (lb *Leaderboard) Key() string {
+	if lb.key != "" {
+		return lb.key
+	}
+	_, _, key := lb.bucket(lb.now())
+	return key
+}
+
+// Keys from 到 to 之间所有周期的 key
+func (lb *Leaderboard) Keys(from, to time.Time) []string {
+	if lb.key != "" {
+		return []string{lb.key}
+	}
+	keys := []string{}
+	for t := from; !t.After(to); {
+		_, end, key := lb.bucket(t)
+		keys = append(keys, key)
+		if end.IsZero() {
+			break
+		}
+		t = end
+	}
+	return keys
+}
+
+// tieSpan 同分排序的时间跨度 秒
+func (lb *Leaderboard) tieSpan() int64 {
+	switch lb.opts.Period {
+	case LeaderboardDaily:
+		return 86400
+	case LeaderboardWeekly:
+		return 7 * 86400
+	case LeaderboardMonthly:
+		return 31 * 86400
+	}
+	return 1 << 32
+}
+
+// tieFactor 分数左移的倍数
+func (lb *Leaderboard) tieFactor() float64 {
+	return float64(uint64(1) << uint(bits.Len64(uint64(lb.tieSpan()-1))))
+}
+
+// tieValue 同分排序值 越早越靠前
+func (lb *Leaderboard) tieValue(t time.Time) int64 {
+	start, _, _ := lb.bucket(t)
+	span := lb.tieSpan()
+	offset := t.Unix() - start.Unix()
+	if offset < 0 {
+		offset = 0
+	} else if offset >= span {
+		offset = span - 1
+	}
+	if lb.opts.Ascending {
+		return offset
+	}
+	return span - 1 - offset
+}
+
+func (lb *Leaderboard) encode(score float64, t time.Time) (float64, error) {
+	if !lb.opts.TieBreak {
+		return score, nil
+	}
+	factor := lb.tieFactor()
+	if score != math.Trunc(score) {
+		return 0, fmt.Errorf("score must be integer when tie break: %v", score)
+	}
+	if math.Abs(score) >= float64(uint64(1)<<53)/factor {
+		return 0, fmt.Errorf("score out of range when tie break: %v", score)
+	}
+	return score*factor + float64(lb.tieValue(t)), nil
+}
+
+func (lb *Leaderboard) decode(score float64) float64 {
+	if !lb.opts.TieBreak {
+		return score
+	}
+	return math.Floor(score / lb.tieFactor())
+}
+
+func formatScore(score float64) string {
+	return strconv.FormatFloat(score, 'f', -1, 64)
+}
+
+// expire 周期榜单写入后设置过期 总榜和固定 key 不过期
+func (lb *Leaderboard) expire(p *Pipeline, key string, t time.Time) {
+	if lb.key != "" || lb.opts.Period == LeaderboardAll {
+		return
+	}
+	start, end, _ := lb.bucket(t)
+	retention := lb.opts.Retention
+	if retention <= 0 {
+		retention = end.Sub(start)
+	}
+	p.Do("EXPIREAT", key, end.Add(retention).Unix())
+}
+
+// Set 设置分数
+func (lb *Leaderboard) Set(member interface{}, score float64) error {
+	t := lb.now()
+	encoded, err := lb.encode(score, t)
+	if err != nil {
+		return err
+	}
+	data, err := jsonplus.Dump(member)
+	if err != nil {
+		return err
+	}
+
+	conn := lb.pool.Get()
+	defer conn.Close()
+
+	key := lb.Key()
+	p := NewPipeline(conn)
+	p.Do("ZADD", key, formatScore(encoded), data)
+	lb.expire(p, key, t)
+	return p.Exec()
+}
+
+// 新分数更好时写入 ARGV[3] 为 1 时分数低的更好, 返回 1 已写入 0 未写入
+var leaderboardBetterScript = NewScript(1, `local old = redis.call("ZSCORE", KEYS[1], ARGV[1])
+	if old
+	then
+		local new = tonumber(ARGV[2])
+		old = tonumber(old)
+		if (ARGV[3] == "1" and new >= old) or (ARGV[3] ~= "1" and new <= old)
+		then
+			return 0
+		end
+	end
+	redis.call("ZADD", KEYS[1], ARGV[2], ARGV[1])
+	return 1`)
+
+// SetIfBetter 新分数比现有的更好时写入 如最高分, 返回是否写入; 同分时保留先达到的
+func (lb *Leaderboard) SetIfBetter(member interface{}, score float64) (bool, error) {
+	t := lb.now()
+	encoded, err := lb.encode(score, t)
+	if err != nil {
+		return false, err
+	}
+	data, err := jsonplus.Dump(member)
+	if err != nil {
+		return false, err
+	}
+	ascending := 0
+	if lb.opts.Ascending {
+		ascending = 1
+	}
+
+	conn := lb.pool.Get()
+	defer conn.Close()
+
+	key := lb.Key()
+	p := NewPipeline(conn)
+	f := p.Script(leaderboardBetterScript, key, data, formatScore(encoded), ascending)
+	lb.expire(p, key, t)
+	if err := p.Exec(); err != nil {
+		return false, err
+	}
+	n, err := redigo.Int(f.Reply(), f.Err())
+	return n == 1, err
+}
+
+// 增加分数并更新同分排序值 ARGV 依次为 member delta factor tie, 返回增加后的分数
+var leaderboardIncrScript = NewScript(1, `local old = redis.call("ZSCORE", KEYS[1], ARGV[1])
+	local factor = tonumber(ARGV[3])
+	local score = tonumber(ARGV[2])
+	if old
+	then
+		score = score + math.floor(tonumber(old) / factor)
+	end
+	redis.call("ZADD", KEYS[1], string.format("%.17g", score * factor + tonumber(ARGV[4])), ARGV[1])
+	return string.format("%.17g", score)`)
+
+// Incr 增加分数 返回增加后的分数
+func (lb *Leaderboard) Incr(member interface{}, delta float64) (float64, error) {
+	t := lb.now()
+	if _, err := lb.encode(delta, t); err != nil {
+		return 0, err
+	}
+	data, err := jsonplus.Dump(member)
+	if err != nil {
+		return 0, err
+	}
+
+	conn := lb.pool.Get()
+	defer conn.Close()
+
+	key := lb.Key()
+	p := NewPipeline(conn)
+	var f *Future
+	if lb.opts.TieBreak {
+		f = p.Script(leaderboardIncrScript, key, data, formatScore(delta), formatScore(lb.tieFactor()), lb.tieValue(t))
+	} else {
+		f = p.Do("ZINCRBY", key, formatScore(delta), data)
+	}
+	lb.expire(p, key, t)
+	if err := p.Exec(); err != nil {
+		return 0, err
+	}
+	return redigo.Float64(f.Reply(), f.Err())
+}
+
+// Remove 移除成员
+func (lb *Leaderboard) Remove(members ...interface{}) error {
+	args := []interface{}{lb.Key()}
+	for _, member := range members {
+		data, err := jsonplus.Dump(member)
+		if err != nil {
+			return err
+		}
+		args = append(args, data)
+	}
+
+	conn := lb.pool.Get()
+	defer conn.Close()
+
+	_, err := conn.Do("ZREM", args...)
+	return err
+}
+
+// Get 成员的排名和分数 不存在返回 false
+func (lb *Leaderboard) Get(member interface{}) (bool, LeaderboardEntry, error) {
+	conn := lb.pool.Get()
+	defer conn.Close()
+
+	ok, rank, score, err := ZFind(conn, lb.Key(), member, !lb.opts.Ascending)
+	if err != nil || !ok {
+		return false, LeaderboardEntry{}, err
+	}
+	data, err := jsonplus.Dump(member)
+	if err != nil {
+		return false, LeaderboardEntry{}, err
+	}
+	return true, LeaderboardEntry{Member: data, Rank: rank + 1, Score: lb.decode(score)}, nil
+}
+
+// Count 成员数量
+func (lb *Leaderboard) Count() (int, error) {
+	conn := lb.pool.Get()
+	defer conn.Close()
+
+	return redigo.Int(conn.Do("ZCARD", lb.Key()))
+}
+
+// Range 按排名读取 start stop 从 0 开始 含 stop, -1 为最后一名
+func (lb *Leaderboard) Range(start, stop int) ([]LeaderboardEntry, error) {
+	conn := lb.pool.Get()
+	defer conn.Close()
+
+	commandName := "ZREVRANGE"
+	if lb.opts.Ascending {
+		commandName = "ZRANGE"
+	}
+	values, err := redigo.Values(conn.Do(commandName, lb.Key(), start, stop, "WITHSCORES"))
+	if err != nil {
+		return nil, err
+	}
+	if len(values)%2 != 0 {
+		return nil, fmt.Errorf("invalid %v reply: %v values", commandName, len(values))
+	}
+
+	if start < 0 { // 负数排名 换算为正数
+		count, err := redigo.Int(conn.Do("ZCARD", lb.Key()))
+		if err != nil {
+			return nil, err
+		}
+		start += count
+		if start < 0 {
+			start = 0
+		}
+	}
+	entries := []LeaderboardEntry{}
+	for i := 0; i < len(values); i += 2 {
+		member, err := redigo.String(values[i], nil)
+		if err != nil {
+			return nil, err
+		}
+		score, err := redigo.Float64(values[i+1], nil)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, LeaderboardEntry{Member: member, Rank: start + i/2 + 1, Score: lb.decode(score)})
+	}
+	return entries, nil
+}
+
+// Top 前 n 名
+func (lb *Leaderboard) Top(n int) ([]LeaderboardEntry, error) {
+	if n <= 0 {
+		return []LeaderboardEntry{}, nil
+	}
+	return lb.Range(0, n-1)
+}
+
+// Page 分页 page 从 1 开始
+func (lb *Leaderboard) Page(page, size int) ([]LeaderboardEntry, error) {
+	if page < 1 || size <= 0 {
+		return []LeaderboardEntry{}, nil
+	}
+	start := (page - 1) * size
+	return lb.Range(start, start+size-1)
+}
+
+// Around 成员前后各 n 名 含成员自身, 成员不存在返回 false
+func (lb *Leaderboard) Around(member interface{}, n int) (bool, []LeaderboardEntry, error) {
+	ok, entry, err := lb.Get(member)
+	if err != nil || !ok {
+		return false, nil, err
+	}
+	start := entry.Rank - 1 - n
+	if start < 0 {
+		start = 0
+	}
+	entries, err := lb.Range(start, entry.Rank-1+n)
+	if err != nil {
+		return false, nil, err
+	}
+	return true, entries, nil
+}
+
+// Merge ZUNIONSTORE 合并多个榜单到 dest 并返回 dest 的榜单, expire 大于 0 时设置过期
+// aggregate 为 SUM MIN MAX, 默认 SUM; 同分排序的榜单不能 SUM, 默认为更好的分数
+//
+//	lb.Merge("rank:level:week", lb.Keys(monday, sunday), "", 7*24*time.Hour)
+func (lb *Leaderboard) Merge(dest string, keys []string, aggregate string, expire time.Duration) (*Leaderboard, error) {
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("keys is empty")
+	}
+	if aggregate == "" {
+		aggregate = "SUM"
+		if lb.opts.TieBreak && lb.opts.Ascending {
+			aggregate = "MIN"
+		} else if lb.opts.TieBreak {
+			aggregate = "MAX"
+		}
+	}
+	if lb.opts.TieBreak && aggregate == "SUM" {
+		return nil, fmt.Errorf("can not SUM boards with tie break")
+	}
+
+	args := []interface{}{dest, len(keys)}
+	for _, key := range keys {
+		args = append(args, key)
+	}
+	args = append(args, "AGGREGATE", aggregate)
+
+	conn := lb.pool.Get()
+	defer conn.Close()
+
+	p := NewPipeline(conn)
+	p.Do("ZUNIONSTORE", args...)
+	if expire > 0 {
+		p.Do("PEXPIRE", dest, int64(expire/time.Millisecond))
+	}
+	if err := p.Exec(); err != nil {
+		return nil, err
+	}
+
+	merged := *lb
+	merged.key, merged.at = dest, time.Time{}
+	return &merged, nil
+}
@@ -0,0 +1,127 @@
+package redigo
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLeaderboardBucket(t *testing.T) {
+	at := time.Date(2020, 3, 5, 13, 0, 0, 0, time.UTC) // 周四
+	tests := []struct {
+		name      string
+		period    LeaderboardPeriod
+		wantStart time.Time
+		wantEnd   time.Time
+		wantKey   string
+	}{
+		{"all", LeaderboardAll, leaderboardEpoch, time.Time{}, "lb"},
+		{"daily", LeaderboardDaily, time.Date(2020, 3, 5, 0, 0, 0, 0, time.UTC), time.Date(2020, 3, 6, 0, 0, 0, 0, time.UTC), "lb:20200305"},
+		{"weekly", LeaderboardWeekly, time.Date(2020, 3, 2, 0, 0, 0, 0, time.UTC), time.Date(2020, 3, 9, 0, 0, 0, 0, time.UTC), "lb:20200302"},
+		{"monthly", LeaderboardMonthly, time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2020, 4, 1, 0, 0, 0, 0, time.UTC), "lb:202003"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lb := NewLeaderboard(nil, "lb", &LeaderboardOptions{Period: tt.period, Location: time.UTC})
+			start, end, key := lb.bucket(at)
+			if !start.Equal(tt.wantStart) || !end.Equal(tt.wantEnd) || key != tt.wantKey {
+				t.Errorf("bucket() = %v, %v, %v, want %v, %v, %v", start, end, key, tt.wantStart, tt.wantEnd, tt.wantKey)
+			}
+			if got := lb.At(at).Key(); got != tt.wantKey {
+				t.Errorf("At().Key() = %v, want %v", got, tt.wantKey)
+			}
+		})
+	}
+
+	// 周日属于周一开始的那一周
+	lb := NewLeaderboard(nil, "lb", &LeaderboardOptions{Period: LeaderboardWeekly, Location: time.UTC})
+	if _, _, key := lb.bucket(time.Date(2020, 3, 8, 23, 0, 0, 0, time.UTC)); key != "lb:20200302" {
+		t.Errorf("bucket() sunday key = %v", key)
+	}
+	// 按时区划分
+	shanghai := time.FixedZone("CST", 8*3600)
+	lb = NewLeaderboard(nil, "lb", &LeaderboardOptions{Period: LeaderboardDaily, Location: shanghai})
+	if _, _, key := lb.bucket(time.Date(2020, 3, 5, 20, 0, 0, 0, time.UTC)); key != "lb:20200306" {
+		t.Errorf("bucket() location key = %v", key)
+	}
+}
+
+func TestLeaderboardKeys(t *testing.T) {
+	from := time.Date(2020, 1, 30, 10, 0, 0, 0, time.UTC)
+	to := time.Date(2020, 2, 2, 1, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		period LeaderboardPeriod
+		want   []string
+	}{
+		{"all", LeaderboardAll, []string{"lb"}},
+		{"daily", LeaderboardDaily, []string{"lb:20200130", "lb:20200131", "lb:20200201", "lb:20200202"}},
+		{"weekly", LeaderboardWeekly, []string{"lb:20200127"}},
+		{"monthly", LeaderboardMonthly, []string{"lb:202001", "lb:202002"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lb := NewLeaderboard(nil, "lb", &LeaderboardOptions{Period: tt.period, Location: time.UTC})
+			if got := lb.Keys(from, to); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Keys() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLeaderboardTieBreak(t *testing.T) {
+	early := time.Date(2020, 3, 5, 1, 0, 0, 0, time.UTC)
+	late := time.Date(2020, 3, 5, 23, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		period    LeaderboardPeriod
+		ascending bool
+		score     float64
+		wantErr   bool
+	}{
+		{"daily", LeaderboardDaily, false, 100, false},
+		{"daily negative", LeaderboardDaily, false, -100, false},
+		{"daily ascending", LeaderboardDaily, true, 100, false},
+		{"weekly max", LeaderboardWeekly, false, 1<<33 - 1, false},
+		{"monthly", LeaderboardMonthly, true, 0, false},
+		{"all max", LeaderboardAll, false, 1<<21 - 1, false},
+		{"not integer", LeaderboardDaily, false, 1.5, true},
+		{"daily out of range", LeaderboardDaily, false, 1 << 36, true},
+		{"all out of range", LeaderboardAll, false, -(1 << 21), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lb := NewLeaderboard(nil, "lb", &LeaderboardOptions{
+				Period: tt.period, Location: time.UTC, TieBreak: true, Ascending: tt.ascending,
+			})
+			earlyScore, err := lb.encode(tt.score, early)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("encode() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			lateScore, _ := lb.encode(tt.score, late)
+			if got := lb.decode(earlyScore); got != tt.score {
+				t.Errorf("decode() early = %v, want %v", got, tt.score)
+			}
+			if got := lb.decode(lateScore); got != tt.score {
+				t.Errorf("decode() late = %v, want %v", got, tt.score)
+			}
+			// 同分先达到者靠前: 降序时分数更大 升序时分数更小
+			if earlier := earlyScore > lateScore; earlier == tt.ascending {
+				t.Errorf("encode() early = %v, late = %v, ascending %v", earlyScore, lateScore, tt.ascending)
+			}
+			// 不同分数的顺序不受时间影响
+			lower, _ := lb.encode(tt.score-1, early)
+			if lower >= lateScore {
+				t.Errorf("encode() score-1 = %v, not below %v", lower, lateScore)
+			}
+		})
+	}
+
+	lb := NewLeaderboard(nil, "lb", &LeaderboardOptions{Period: LeaderboardDaily})
+	if got, err := lb.encode(1.5, early); err != nil || got != 1.5 || lb.decode(got) != 1.5 {
+		t.Errorf("encode() without tie break = %v, %v", got, err)
+	}
+}
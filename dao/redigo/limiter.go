@@ -0,0 +1,276 @@
+package redigo
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"sync"
+	"time"
+
+	redigo "github.com/gomodule/redigo/redis"
+)
+
+// 限流脚本统一返回 {allowed 1/0, remaining, retry after 毫秒}
+
+// 固定窗口 ARGV 依次为 n limit period(毫秒)
+var fixedWindowScript = NewScript(1, `local current = tonumber(redis.call("GET", KEYS[1]) or "0")
+	local n = tonumber(ARGV[1])
+	local limit = tonumber(ARGV[2])
+	if current + n > limit
+	then
+		local ttl = redis.call("PTTL", KEYS[1])
+		if ttl < 0
+		then
+			ttl = tonumber(ARGV[3])
+		end
+		return {0, math.max(limit - current, 0), ttl}
+	end
+	current = redis.call("INCRBY", KEYS[1], n)
+	if redis.call("PTTL", KEYS[1]) < 0
+	then
+		redis.call("PEXPIRE", KEYS[1], ARGV[3])
+	end
+	return {1, limit - current, 0}`)
+
+// 滑动窗口 有序集合记录每次请求的时间, ARGV 依次为 n limit period now(毫秒) nonce
+var slidingWindowScript = NewScript(1, `local n = tonumber(ARGV[1])
+	local limit = tonumber(ARGV[2])
+	local period = tonumber(ARGV[3])
+	local now = tonumber(ARGV[4])
+	redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", now - period)
+	local count = redis.call("ZCARD", KEYS[1])
+	if count + n > limit
+	then
+		local retry = period
+		local index = count + n - limit - 1
+		local oldest = redis.call("ZRANGE", KEYS[1], index, index, "WITHSCORES")
+		if oldest[2]
+		then
+			retry = math.max(tonumber(oldest[2]) + period - now, 1)
+		end
+		return {0, math.max(limit - count, 0), retry}
+	end
+	for i = 1, n do
+		redis.call("ZADD", KEYS[1], now, ARGV[5] .. "-" .. i)
+	end
+	redis.call("PEXPIRE", KEYS[1], period)
+	return {1, limit - count - n, 0}`)
+
+// 令牌桶 哈希记录令牌数和时间, ARGV 依次为 n capacity rate(每毫秒) now(毫秒)
+var tokenBucketScript = NewScript(1, `local n = tonumber(ARGV[1])
+	local capacity = tonumber(ARGV[2])
+	local rate = tonumber(ARGV[3])
+	local now = tonumber(ARGV[4])
+	local data = redis.call("HMGET", KEYS[1], "tokens", "ts")
+	local tokens = tonumber(data[1])
+	local ts = tonumber(data[2])
+	if tokens == nil or ts == nil
+	then
+		tokens = capacity
+		ts = now
+	end
+	if now > ts
+	then
+		tokens = math.min(capacity, tokens + (now - ts) * rate)
+		ts = now
+	end
+	local allowed = 0
+	local retry = 0
+	if tokens >= n
+	then
+		tokens = tokens - n
+		allowed = 1
+	else
+		retry = math.ceil((n - tokens) / rate)
+	end
+	redis.call("HMSET", KEYS[1], "tokens", string.format("%.17g", tokens), "ts", ts)
+	redis.call("PEXPIRE", KEYS[1], math.ceil(capacity / rate) + 1000)
+	return {allowed, math.floor(tokens), retry}`)
+
+// Limit 限制 Period 内最多 Rate 次; 令牌桶为每 Period 补充 Rate 个令牌, 容量 Burst
+type Limit struct {
+	Rate   int
+	Period time.Duration
+	Burst  int // 令牌桶容量 默认 Rate
+}
+
+// LimitResult 限流结果
+type LimitResult struct {
+	Allowed    bool
+	Remaining  int           // 剩余次数
+	RetryAfter time.Duration // 不允许时 距离允许的时间
+}
+
+// LimiterOptions 限流器参数
+type LimiterOptions struct {
+	Prefix    string                 // key 前缀 默认 limiter:类型:
+	LimitFunc func(key string) Limit // 按 key 指定限制 返回 Rate 为 0 时使用默认限制
+	Local     bool                   // 本地预检 被拒绝的 key 在 RetryAfter 内直接拒绝, 不访问 redis
+}
+
+// RateLimiter 基于 redis 脚本的限流器 时间以调用方的时钟为准
+//
+//	limiter := redigoplus.NewFixedWindowLimiter(pool, redigoplus.Limit{Rate: 10, Period: time.Second}, nil)
+//	result, err := limiter.Allow(uid)
+type RateLimiter struct {
+	pool     *redigo.Pool
+	limit    Limit
+	opts     LimiterOptions
+	script   *Script
+	args     func(limit Limit, n int, now time.Time) []interface{}
+	capacity func(limit Limit) int // 单次请求的上限
+	mutex    sync.Mutex
+	denied   map[string]time.Time // 本地预检 key 被拒绝到的时间
+}
+
+func newRateLimiter(pool *redigo.Pool, limit Limit, opts *LimiterOptions, prefix string, script *Script,
+	args func(limit Limit, n int, now time.Time) []interface{}, capacity func(limit Limit) int) *RateLimiter {
+	limiter := &RateLimiter{
+		pool:     pool,
+		limit:    limit,
+		script:   script,
+		args:     args,
+		capacity: capacity,
+		denied:   map[string]time.Time{},
+	}
+	if opts != nil {
+		limiter.opts = *opts
+	}
+	if limiter.opts.Prefix == "" {
+		limiter.opts.Prefix = prefix
+	}
+	return limiter
+}
+
+func milliseconds(d time.Duration) int64 {
+	return int64(d / time.Millisecond)
+}
+
+// windowCapacity 窗口内最多 Rate 次
+func windowCapacity(limit Limit) int {
+	return limit.Rate
+}
+
+// bucketCapacity 令牌桶容量 Burst 未设置时为 Rate
+func bucketCapacity(limit Limit) int {
+	if limit.Burst > 0 {
+		return limit.Burst
+	}
+	return limit.Rate
+}
+
+// NewFixedWindowLimiter 固定窗口 窗口从第一次请求开始计时
+func NewFixedWindowLimiter(pool *redigo.Pool, limit Limit, opts *LimiterOptions) *RateLimiter {
+	return newRateLimiter(pool, limit, opts, "limiter:fixed:", fixedWindowScript,
+		func(limit Limit, n int, now time.Time) []interface{} {
+			return []interface{}{n, limit.Rate, milliseconds(limit.Period)}
+		}, windowCapacity)
+}
+
+// NewSlidingWindowLimiter 滑动窗口 记录窗口内每次请求 精确但占用较多内存, 适合较小的 Rate
+func NewSlidingWindowLimiter(pool *redigo.Pool, limit Limit, opts *LimiterOptions) *RateLimiter {
+	return newRateLimiter(pool, limit, opts, "limiter:sliding:", slidingWindowScript,
+		func(limit Limit, n int, now time.Time) []interface{} {
+			nonce := strconv.FormatInt(now.UnixNano(), 36) + strconv.FormatInt(rand.Int63(), 36)
+			return []interface{}{n, limit.Rate, milliseconds(limit.Period), milliseconds(time.Duration(now.UnixNano())), nonce}
+		}, windowCapacity)
+}
+
+// NewTokenBucketLimiter 令牌桶 允许 Burst 的突发
+func NewTokenBucketLimiter(pool *redigo.Pool, limit Limit, opts *LimiterOptions) *RateLimiter {
+	return newRateLimiter(pool, limit, opts, "limiter:bucket:", tokenBucketScript,
+		func(limit Limit, n int, now time.Time) []interface{} {
+			rate := float64(limit.Rate) / float64(milliseconds(limit.Period))
+			return []interface{}{n, bucketCapacity(limit), strconv.FormatFloat(rate, 'g', -1, 64), milliseconds(time.Duration(now.UnixNano()))}
+		}, bucketCapacity)
+}
+
+// Limit key 的限制
+func (limiter *RateLimiter) Limit(key string) Limit {
+	if limiter.opts.LimitFunc != nil {
+		if limit := limiter.opts.LimitFunc(key); limit.Rate > 0 {
+			return limit
+		}
+	}
+	return limiter.limit
+}
+
+// Allow 请求一次
+func (limiter *RateLimiter) Allow(key string) (*LimitResult, error) {
+	return limiter.AllowN(key, 1)
+}
+
+// AllowN 请求 n 次 不允许时不计数, n 须在 1 与容量之间
+func (limiter *RateLimiter) AllowN(key string, n int) (*LimitResult, error) {
+	limit := limiter.Limit(key)
+	if limit.Rate <= 0 || milliseconds(limit.Period) <= 0 {
+		return nil, fmt.Errorf("invalid limit for key %v: %+v", key, limit)
+	}
+	if n < 1 {
+		return nil, fmt.Errorf("n %v must be positive", n)
+	}
+	if capacity := limiter.capacity(limit); n > capacity {
+		return nil, fmt.Errorf("n %v exceeds limit %v", n, capacity)
+	}
+
+	now := time.Now()
+	if result, ok := limiter.localCheck(key, now); ok {
+		return result, nil
+	}
+
+	conn := limiter.pool.Get()
+	defer conn.Close()
+
+	values, err := redigo.Int64s(limiter.script.Do(conn, append([]interface{}{limiter.opts.Prefix + key}, limiter.args(limit, n, now)...)...).Reply())
+	if err != nil {
+		return nil, err
+	}
+	if len(values) != 3 {
+		return nil, fmt.Errorf("invalid limiter reply: %v", values)
+	}
+	result := &LimitResult{
+		Allowed:    values[0] == 1,
+		Remaining:  int(values[1]),
+		RetryAfter: time.Duration(values[2]) * time.Millisecond,
+	}
+	if !result.Allowed && limiter.opts.Local && n == 1 {
+		limiter.deny(key, now.Add(result.RetryAfter))
+	}
+	return result, nil
+}
+
+// localCheck 本地预检 key 仍在拒绝期内时返回拒绝结果
+func (limiter *RateLimiter) localCheck(key string, now time.Time) (*LimitResult, bool) {
+	if !limiter.opts.Local {
+		return nil, false
+	}
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	until, ok := limiter.denied[key]
+	if !ok {
+		return nil, false
+	}
+	if !now.Before(until) {
+		delete(limiter.denied, key)
+		return nil, false
+	}
+	return &LimitResult{RetryAfter: until.Sub(now)}, true
+}
+
+const limiterDeniedMax = 10000 // 本地预检记录超过后 清理已过期的
+
+func (limiter *RateLimiter) deny(key string, until time.Time) {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	if len(limiter.denied) >= limiterDeniedMax {
+		now := time.Now()
+		for k, v := range limiter.denied {
+			if !now.Before(v) {
+				delete(limiter.denied, k)
+			}
+		}
+	}
+	limiter.denied[key] = until
+}
@@ -0,0 +1,178 @@
+package redigo
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	redigo "github.com/gomodule/redigo/redis"
+)
+
+func TestLimiterArgs(t *testing.T) {
+	now := time.Unix(1, 500*int64(time.Millisecond))
+	limit := Limit{Rate: 10, Period: 2 * time.Second}
+	tests := []struct {
+		name    string
+		limiter *RateLimiter
+		limit   Limit
+		want    []interface{}
+	}{
+		{"fixed window", NewFixedWindowLimiter(nil, limit, nil), limit, []interface{}{3, 10, int64(2000)}},
+		{"token bucket", NewTokenBucketLimiter(nil, limit, nil), limit, []interface{}{3, 10, "0.005", int64(1500)}},
+		{"token bucket burst", NewTokenBucketLimiter(nil, limit, nil), Limit{Rate: 10, Period: 2 * time.Second, Burst: 20},
+			[]interface{}{3, 20, "0.005", int64(1500)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.limiter.args(tt.limit, 3, now); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("args() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+
+	// 滑动窗口的 nonce 每次不同
+	sliding := NewSlidingWindowLimiter(nil, limit, nil)
+	a, b := sliding.args(limit, 3, now), sliding.args(limit, 3, now)
+	if !reflect.DeepEqual(a[:4], []interface{}{3, 10, int64(2000), int64(1500)}) || a[4] == b[4] {
+		t.Errorf("args() sliding = %#v, %#v", a, b)
+	}
+}
+
+func TestLimiterOptions(t *testing.T) {
+	limit := Limit{Rate: 10, Period: time.Second}
+	vip := Limit{Rate: 100, Period: time.Second}
+	limiter := NewFixedWindowLimiter(nil, limit, &LimiterOptions{LimitFunc: func(key string) Limit {
+		if key == "vip" {
+			return vip
+		}
+		return Limit{}
+	}})
+	if limiter.opts.Prefix != "limiter:fixed:" {
+		t.Errorf("Prefix = %v", limiter.opts.Prefix)
+	}
+	if got := limiter.Limit("vip"); got != vip {
+		t.Errorf("Limit() vip = %v", got)
+	}
+	if got := limiter.Limit("user"); got != limit {
+		t.Errorf("Limit() default = %v", got)
+	}
+}
+
+func TestLimiterAllowNValidate(t *testing.T) {
+	tests := []struct {
+		name       string
+		newLimiter func(pool *redigo.Pool, limit Limit, opts *LimiterOptions) *RateLimiter
+		limit      Limit
+		n          int
+	}{
+		{"zero rate", NewTokenBucketLimiter, Limit{Period: time.Second}, 1},
+		{"zero period", NewTokenBucketLimiter, Limit{Rate: 10}, 1},
+		{"sub millisecond period", NewTokenBucketLimiter, Limit{Rate: 10, Period: time.Microsecond}, 1},
+		{"zero n", NewTokenBucketLimiter, Limit{Rate: 10, Period: time.Second}, 0},
+		{"negative n", NewFixedWindowLimiter, Limit{Rate: 10, Period: time.Second}, -1},
+		{"n over rate", NewTokenBucketLimiter, Limit{Rate: 10, Period: time.Second}, 11},
+		{"n over burst", NewTokenBucketLimiter, Limit{Rate: 10, Period: time.Second, Burst: 20}, 21},
+		{"n over burst below rate", NewTokenBucketLimiter, Limit{Rate: 10, Period: time.Second, Burst: 5}, 6},
+		{"fixed window ignores burst", NewFixedWindowLimiter, Limit{Rate: 10, Period: time.Second, Burst: 20}, 11},
+		{"sliding window ignores burst", NewSlidingWindowLimiter, Limit{Rate: 10, Period: time.Second, Burst: 20}, 11},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 校验失败时不使用连接池
+			limiter := tt.newLimiter(nil, tt.limit, nil)
+			if _, err := limiter.AllowN("k", tt.n); err == nil {
+				t.Errorf("AllowN() want error")
+			}
+		})
+	}
+}
+
+func TestLimiterAllowNCapacity(t *testing.T) {
+	tests := []struct {
+		name       string
+		newLimiter func(pool *redigo.Pool, limit Limit, opts *LimiterOptions) *RateLimiter
+		limit      Limit
+		n          int
+	}{
+		{"rate", NewFixedWindowLimiter, Limit{Rate: 10, Period: time.Second}, 10},
+		{"burst over rate", NewTokenBucketLimiter, Limit{Rate: 10, Period: time.Second, Burst: 20}, 20},
+		{"burst below rate", NewTokenBucketLimiter, Limit{Rate: 10, Period: time.Second, Burst: 5}, 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := newFakeConn(func(args []string) (interface{}, error) {
+				if args[0] == "SCRIPT" {
+					return []interface{}{int64(1)}, nil
+				}
+				return []interface{}{int64(1), int64(0), int64(0)}, nil
+			})
+			limiter := tt.newLimiter(fakePool(conn), tt.limit, nil)
+			result, err := limiter.AllowN("k", tt.n)
+			if err != nil || !result.Allowed {
+				t.Errorf("AllowN() = %+v, %v", result, err)
+			}
+		})
+	}
+}
+
+func TestLimiterLocal(t *testing.T) {
+	now := time.Now()
+	limiter := NewFixedWindowLimiter(nil, Limit{Rate: 1, Period: time.Second}, &LimiterOptions{Local: true})
+	if _, ok := limiter.localCheck("k", now); ok {
+		t.Errorf("localCheck() not denied key")
+	}
+
+	limiter.deny("k", now.Add(time.Second))
+	result, ok := limiter.localCheck("k", now.Add(400*time.Millisecond))
+	if !ok || result.Allowed || result.RetryAfter != 600*time.Millisecond {
+		t.Errorf("localCheck() = %+v, %v", result, ok)
+	}
+	if _, ok := limiter.localCheck("k", now.Add(time.Second)); ok {
+		t.Errorf("localCheck() expired key still denied")
+	}
+	if _, ok := limiter.denied["k"]; ok {
+		t.Errorf("localCheck() expired key not removed")
+	}
+
+	// 超过上限时清理已过期的记录
+	for i := 0; i < limiterDeniedMax; i++ {
+		limiter.denied[strconv.Itoa(i)] = now.Add(-time.Second)
+	}
+	limiter.deny("k", now.Add(time.Second))
+	if len(limiter.denied) != 1 {
+		t.Errorf("deny() denied = %v, want 1", len(limiter.denied))
+	}
+
+	remote := NewFixedWindowLimiter(nil, Limit{Rate: 1, Period: time.Second}, nil)
+	remote.deny("k", now.Add(time.Second))
+	if _, ok := remote.localCheck("k", now); ok {
+		t.Errorf("localCheck() without Local option")
+	}
+}
+
+func TestLimiterAllowN(t *testing.T) {
+	replies := [][]interface{}{{int64(1), int64(0), int64(0)}, {int64(0), int64(0), int64(800)}}
+	conn := newFakeConn(func(args []string) (interface{}, error) {
+		switch args[0] {
+		case "EVALSHA":
+			reply := replies[0]
+			replies = replies[1:]
+			return reply, nil
+		}
+		return nil, nil
+	})
+	limiter := NewFixedWindowLimiter(fakePool(conn), Limit{Rate: 1, Period: time.Second}, &LimiterOptions{Local: true})
+
+	if result, err := limiter.Allow("k"); err != nil || !result.Allowed {
+		t.Fatalf("Allow() = %+v, %v", result, err)
+	}
+	if result, err := limiter.Allow("k"); err != nil || result.Allowed || result.RetryAfter != 800*time.Millisecond {
+		t.Fatalf("Allow() = %+v, %v", result, err)
+	}
+	// 本地预检拒绝 不再访问 redis
+	conn.commands = nil
+	if result, err := limiter.Allow("k"); err != nil || result.Allowed || len(conn.commands) != 0 {
+		t.Errorf("Allow() local = %+v, %v, commands %q", result, err, conn.commands)
+	}
+}
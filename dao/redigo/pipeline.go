@@ -0,0 +1,299 @@
+package redigo
+
+import (
+	"fmt"
+
+	redigo "github.com/gomodule/redigo/redis"
+)
+
+// Future 管道中一条命令的结果 Pipeline.Exec 之后可用
+type Future struct {
+	commandName string
+	args        []interface{}
+	script      *Script // 脚本命令 args 首个参数为 sha1
+	decode      func(res *Res) (bool, error)
+	res         *Res
+	ok          bool
+	err         error
+	done        bool
+}
+
+// Res 原始结果 未执行时为 nil
+func (f *Future) Res() *Res {
+	return f.res
+}
+
+// Reply 原始返回
+func (f *Future) Reply() interface{} {
+	if f.res == nil {
+		return nil
+	}
+	return f.res.reply
+}
+
+// OK 目标是否已赋值 返回为 nil 时为 false
+func (f *Future) OK() bool {
+	return f.ok
+}
+
+// Err 该命令自身的错误 含参数转换、命令执行和解码的错误
+func (f *Future) Err() error {
+	if !f.done && f.err == nil {
+		return fmt.Errorf("pipeline not executed")
+	}
+	return f.err
+}
+
+// command 发送的命令 eval 为 true 时脚本使用 EVAL: 事务中 NOSCRIPT 无法重试, 或服务端未加载该脚本
+func (f *Future) command(eval bool) (string, []interface{}) {
+	if f.script != nil && eval {
+		args := append([]interface{}{f.script.src}, f.args[1:]...)
+		return "EVAL", args
+	}
+	return f.commandName, f.args
+}
+
+func (f *Future) resolve(reply interface{}, err error) {
+	f.done = true
+	f.res = Result(reply, err)
+	if err != nil && err != redigo.ErrNil {
+		f.err = err
+		return
+	}
+	if f.decode != nil {
+		f.ok, f.err = f.decode(f.res)
+	} else {
+		f.ok = reply != nil
+	}
+}
+
+// Pipeline 管道 命令先缓存, Exec 时一次发送并按顺序解析每条命令的结果
+//
+//	p := NewPipeline(conn)
+//	name := p.Value(&nameDest, "GET", "name")
+//	ttl := p.Do("TTL", "name")
+//	err := p.Exec()
+type Pipeline struct {
+	conn    redigo.Conn
+	futures []*Future
+	multi   bool
+}
+
+// NewPipeline ...
+func NewPipeline(conn redigo.Conn) *Pipeline {
+	return &Pipeline{conn: conn}
+}
+
+// Multi 使用 MULTI/EXEC 包裹 事务内命令一起执行
+func (p *Pipeline) Multi() *Pipeline {
+	p.multi = true
+	return p
+}
+
+// Len 已缓存的命令数量
+func (p *Pipeline) Len() int {
+	return len(p.futures)
+}
+
+func (p *Pipeline) add(decode func(res *Res) (bool, error), commandName string, args ...interface{}) *Future {
+	f := &Future{commandName: commandName, args: args, decode: decode}
+	if err := converArgs(args...); err != nil {
+		f.err, f.done = err, true
+	}
+	p.futures = append(p.futures, f)
+	return f
+}
+
+// Do 缓存一条命令 参数默认使用json格式, 结果通过 Future.Res 获取
+func (p *Pipeline) Do(commandName string, args ...interface{}) *Future {
+	return p.add(nil, commandName, args...)
+}
+
+// Value 缓存一条命令 结果通过 Res.Value 解析进 dest
+func (p *Pipeline) Value(dest interface{}, commandName string, args ...interface{}) *Future {
+	return p.add(func(res *Res) (bool, error) {
+		return res.Value(dest)
+	}, commandName, args...)
+}
+
+// JSON 缓存一条命令 结果通过 Res.StringToJSON 解析进 dest
+func (p *Pipeline) JSON(dest interface{}, commandName string, args ...interface{}) *Future {
+	return p.add(func(res *Res) (bool, error) {
+		return res.StringToJSON(dest)
+	}, commandName, args...)
+}
+
+// Script 缓存一条脚本命令 使用 EVALSHA, Exec 前检查未知是否已加载的脚本 未加载的原地使用 EVAL; 事务中使用 EVAL
+func (p *Pipeline) Script(s *Script, keysAndArgs ...interface{}) *Future {
+	f := p.add(nil, "EVALSHA", s.evalArgs(s.Hash(), keysAndArgs)...)
+	f.script = s
+	return f
+}
+
+// Exec 发送所有命令并解析结果 每个 Future 有各自的错误, 返回第一个错误
+// 连接出错时 之后的 Future 均为该错误; 执行后管道清空 可继续使用
+func (p *Pipeline) Exec() error {
+	futures := []*Future{}
+	for _, f := range p.futures {
+		if !f.done {
+			futures = append(futures, f)
+		}
+	}
+	all := p.futures
+	p.futures = nil
+
+	if len(futures) > 0 {
+		var err error
+		if p.multi {
+			err = p.execMulti(futures)
+		} else {
+			err = p.exec(futures)
+		}
+		if err != nil {
+			for _, f := range futures {
+				if !f.done {
+					f.done, f.err = true, err
+				}
+			}
+		}
+	}
+
+	for _, f := range all {
+		if f.err != nil {
+			return f.err
+		}
+	}
+	return nil
+}
+
+func (p *Pipeline) exec(futures []*Future) error {
+	missing, err := p.missingScripts(futures)
+	if err != nil {
+		return err
+	}
+	retry, err := p.send(futures, missing)
+	if err != nil || len(retry) == 0 {
+		return err
+	}
+
+	// 已知加载的脚本服务端已没有(SCRIPT FLUSH/重启/连接了其他服务端), 在末尾使用 EVAL 重试 此时与其他命令的顺序不能保证
+	eval := map[*Script]bool{}
+	for _, f := range retry {
+		eval[f.script] = true
+	}
+	_, err = p.send(retry, eval)
+	return err
+}
+
+// send 发送并解析结果 eval 中的脚本使用 EVAL; 返回 EVALSHA 遇到 NOSCRIPT 未解析的命令
+func (p *Pipeline) send(futures []*Future, eval map[*Script]bool) ([]*Future, error) {
+	for _, f := range futures {
+		commandName, args := f.command(eval[f.script])
+		if err := p.conn.Send(commandName, args...); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.conn.Flush(); err != nil {
+		return nil, err
+	}
+
+	retry := []*Future{}
+	for _, f := range futures {
+		reply, err := p.conn.Receive()
+		if _, ok := err.(redigo.Error); err != nil && !ok { // 非命令错误 连接已不可用
+			return nil, err
+		}
+		if f.script != nil {
+			if !eval[f.script] && IsNoScript(err) {
+				setScriptLoaded(f.script.Hash(), false)
+				retry = append(retry, f)
+				continue
+			}
+			if err == nil {
+				setScriptLoaded(f.script.Hash(), true)
+			}
+		}
+		f.resolve(reply, err)
+	}
+	return retry, nil
+}
+
+// missingScripts SCRIPT EXISTS 检查管道中未知是否加载的脚本 服务端没有的原地使用 EVAL, 保持命令顺序;
+// 都已知加载时不检查
+func (p *Pipeline) missingScripts(futures []*Future) (map[*Script]bool, error) {
+	missing := map[*Script]bool{}
+	list := []*Script{}
+	args := []interface{}{"EXISTS"}
+	for _, f := range futures {
+		if f.script != nil && !missing[f.script] && !scriptLoaded(f.script.Hash()) {
+			missing[f.script] = true
+			list = append(list, f.script)
+			args = append(args, f.script.Hash())
+		}
+	}
+	if len(list) == 0 {
+		return missing, nil
+	}
+
+	exists, err := redigo.Ints(p.conn.Do("SCRIPT", args...))
+	if err != nil {
+		return nil, err
+	}
+	for i, s := range list {
+		if i < len(exists) && exists[i] == 1 {
+			delete(missing, s)
+			setScriptLoaded(s.Hash(), true)
+		}
+	}
+	return missing, nil
+}
+
+func (p *Pipeline) execMulti(futures []*Future) error {
+	if err := p.conn.Send("MULTI"); err != nil {
+		return err
+	}
+	for _, f := range futures {
+		commandName, args := f.command(true)
+		if err := p.conn.Send(commandName, args...); err != nil {
+			return err
+		}
+	}
+	if err := p.conn.Send("EXEC"); err != nil {
+		return err
+	}
+	if err := p.conn.Flush(); err != nil {
+		return err
+	}
+
+	if _, err := p.conn.Receive(); err != nil { // MULTI
+		return err
+	}
+	for _, f := range futures { // QUEUED 或 入队失败的错误, 入队失败时 EXEC 返回 EXECABORT
+		_, err := p.conn.Receive()
+		if _, ok := err.(redigo.Error); err != nil && !ok {
+			return err
+		}
+		if err != nil {
+			f.done, f.err = true, err
+		}
+	}
+
+	replies, err := redigo.Values(p.conn.Receive())
+	if err == redigo.ErrNil {
+		return fmt.Errorf("transaction aborted")
+	}
+	if err != nil {
+		return err
+	}
+	if len(replies) != len(futures) {
+		return fmt.Errorf("exec returned %v replies, want %v", len(replies), len(futures))
+	}
+	for i, f := range futures {
+		if e, ok := replies[i].(redigo.Error); ok {
+			f.resolve(nil, e)
+		} else {
+			f.resolve(replies[i], nil)
+		}
+	}
+	return nil
+}
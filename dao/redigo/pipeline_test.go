@@ -0,0 +1,190 @@
+package redigo
+
+import (
+	"reflect"
+	"testing"
+
+	redigo "github.com/gomodule/redigo/redis"
+)
+
+var (
+	testLoadedScript  = NewScript(1, `return redis.call("GET", KEYS[1])`)
+	testMissingScript = NewScript(1, `return redis.call("TTL", KEYS[1])`)
+)
+
+func TestPipelineExec(t *testing.T) {
+	resetLoadedScripts()
+	loaded := map[string]bool{testLoadedScript.Hash(): true}
+	conn := newFakeConn(func(args []string) (interface{}, error) {
+		switch args[0] {
+		case "SCRIPT":
+			exists := []interface{}{}
+			for _, hash := range args[2:] {
+				if loaded[hash] {
+					exists = append(exists, int64(1))
+				} else {
+					exists = append(exists, int64(0))
+				}
+			}
+			return exists, nil
+		case "GET":
+			return []byte(args[1] + "-value"), nil
+		case "INCR":
+			return nil, redigo.Error("ERR value is not an integer")
+		case "EVAL", "EVALSHA":
+			return int64(len(args)), nil
+		}
+		return nil, nil
+	})
+
+	p := NewPipeline(conn)
+	var a string
+	fa := p.Value(&a, "GET", "a")
+	fmissing := p.Script(testMissingScript, "k")
+	fincr := p.Do("INCR", "a")
+	floaded := p.Script(testLoadedScript, "k")
+	fbad := p.Do("SET", "a", make(chan int))
+	fb := p.Do("GET", "b")
+
+	if err := p.Exec(); err == nil {
+		t.Errorf("Exec() want first error")
+	}
+	want := []string{
+		"SCRIPT EXISTS " + testMissingScript.Hash() + " " + testLoadedScript.Hash(),
+		"GET a",
+		"EVAL " + testMissingScript.src + " 1 k",
+		"INCR a",
+		"EVALSHA " + testLoadedScript.Hash() + " 1 k",
+		"GET b",
+	}
+	if !reflect.DeepEqual(conn.commands, want) {
+		t.Errorf("Exec() commands = %q, want %q", conn.commands, want)
+	}
+
+	if fa.Err() != nil || !fa.OK() || a != "a-value" {
+		t.Errorf("GET a = %v, %v, %v", a, fa.OK(), fa.Err())
+	}
+	if fmissing.Err() != nil || fmissing.Reply() != int64(4) {
+		t.Errorf("missing script = %v, %v", fmissing.Reply(), fmissing.Err())
+	}
+	if fincr.Err() == nil {
+		t.Errorf("INCR want error")
+	}
+	if floaded.Err() != nil || floaded.Reply() != int64(4) {
+		t.Errorf("loaded script = %v, %v", floaded.Reply(), floaded.Err())
+	}
+	if fbad.Err() == nil {
+		t.Errorf("SET with bad arg want error")
+	}
+	if v, err := redigo.String(fb.Reply(), fb.Err()); err != nil || v != "b-value" {
+		t.Errorf("GET b = %v, %v", v, err)
+	}
+	if p.Len() != 0 {
+		t.Errorf("Exec() Len = %v, want 0", p.Len())
+	}
+}
+
+func TestPipelineScriptCache(t *testing.T) {
+	resetLoadedScripts()
+	loaded := map[string]bool{}
+	conn := newFakeConn(func(args []string) (interface{}, error) {
+		switch args[0] {
+		case "SCRIPT":
+			exists := []interface{}{}
+			for _, hash := range args[2:] {
+				if loaded[hash] {
+					exists = append(exists, int64(1))
+				} else {
+					exists = append(exists, int64(0))
+				}
+			}
+			return exists, nil
+		case "EVALSHA":
+			if !loaded[args[1]] {
+				return nil, redigo.Error("NOSCRIPT No matching script. Please use EVAL.")
+			}
+			return []byte("evalsha"), nil
+		case "EVAL":
+			loaded[testMissingScript.Hash()] = true
+			return []byte("eval"), nil
+		}
+		return []byte(args[0]), nil
+	})
+	evalsha := "EVALSHA " + testMissingScript.Hash() + " 1 k"
+	eval := "EVAL " + testMissingScript.src + " 1 k"
+
+	tests := []struct {
+		name      string
+		flush     bool // 执行前服务端清空脚本
+		want      []string
+		wantReply string
+	}{
+		{"unknown checks exists", false, []string{"SCRIPT EXISTS " + testMissingScript.Hash(), eval, "GET a"}, "eval"},
+		{"loaded by eval skips exists", false, []string{evalsha, "GET a"}, "evalsha"},
+		{"flushed retries eval at end", true, []string{evalsha, "GET a", eval}, "eval"},
+		{"reloaded skips exists", false, []string{evalsha, "GET a"}, "evalsha"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.flush {
+				loaded = map[string]bool{}
+			}
+			conn.commands = nil
+			p := NewPipeline(conn)
+			f := p.Script(testMissingScript, "k")
+			fget := p.Do("GET", "a")
+			if err := p.Exec(); err != nil {
+				t.Fatalf("Exec() error = %v", err)
+			}
+			if !reflect.DeepEqual(conn.commands, tt.want) {
+				t.Errorf("Exec() commands = %q, want %q", conn.commands, tt.want)
+			}
+			if v, err := redigo.String(f.Reply(), f.Err()); err != nil || v != tt.wantReply {
+				t.Errorf("script = %v, %v, want %v", v, err, tt.wantReply)
+			}
+			if v, err := redigo.String(fget.Reply(), fget.Err()); err != nil || v != "GET" {
+				t.Errorf("GET = %v, %v", v, err)
+			}
+		})
+	}
+}
+
+func TestPipelineMulti(t *testing.T) {
+	queued := []interface{}{}
+	conn := newFakeConn(func(args []string) (interface{}, error) {
+		switch args[0] {
+		case "MULTI":
+			return "OK", nil
+		case "EXEC":
+			replies := queued
+			queued = nil
+			return replies, nil
+		case "INCR":
+			queued = append(queued, redigo.Error("ERR value is not an integer"))
+		default:
+			queued = append(queued, []byte(args[0]))
+		}
+		return "QUEUED", nil
+	})
+
+	p := NewPipeline(conn).Multi()
+	fset := p.Do("SET", "a", 1)
+	fscript := p.Script(testLoadedScript, "k")
+	fincr := p.Do("INCR", "a")
+	if err := p.Exec(); err == nil {
+		t.Errorf("Exec() want error")
+	}
+	want := []string{"MULTI", "SET a 1", "EVAL " + testLoadedScript.src + " 1 k", "INCR a", "EXEC"}
+	if !reflect.DeepEqual(conn.commands, want) {
+		t.Errorf("Exec() commands = %q, want %q", conn.commands, want)
+	}
+	if v, err := redigo.String(fset.Reply(), fset.Err()); err != nil || v != "SET" {
+		t.Errorf("SET = %v, %v", v, err)
+	}
+	if v, err := redigo.String(fscript.Reply(), fscript.Err()); err != nil || v != "EVAL" {
+		t.Errorf("script = %v, %v", v, err)
+	}
+	if fincr.Err() == nil {
+		t.Errorf("INCR want error")
+	}
+}
@@ -55,6 +55,11 @@ type Res struct {
 	err   error
 }
 
+// Reply 原始返回和错误 可配合 redigo 的转换函数使用 如 redigo.Ints(res.Reply())
+func (res *Res) Reply() (interface{}, error) {
+	return res.reply, res.err
+}
+
 // StringToJSON ...
 func (res *Res) StringToJSON(dest interface{}) (bool, error) {
 	data, err := redigo.String(res.reply, res.err)
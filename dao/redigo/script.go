@@ -0,0 +1,138 @@
+package redigo
+
+import (
+	"strings"
+	"sync"
+
+	redigo "github.com/gomodule/redigo/redis"
+)
+
+// Script lua 脚本 使用 NewScript 声明为包级变量, 调用时使用 EVALSHA, 服务端没有时回退 EVAL
+//
+//	var getScript = redigoplus.NewScript(1, `return redis.call("GET", KEYS[1])`)
+//	found, err := getScript.Do(conn, key).Value(&dest)
+type Script struct {
+	keyCount int
+	src      string
+	script   *redigo.Script
+}
+
+var scripts = struct {
+	sync.Mutex
+	list []*Script
+}{}
+
+// loadedScripts 已知服务端已加载的脚本 sha1, 管道中这些脚本直接 EVALSHA 不再 SCRIPT EXISTS; 返回 NOSCRIPT 时移除
+var loadedScripts = struct {
+	sync.RWMutex
+	m map[string]bool
+}{m: map[string]bool{}}
+
+func scriptLoaded(hash string) bool {
+	loadedScripts.RLock()
+	defer loadedScripts.RUnlock()
+	return loadedScripts.m[hash]
+}
+
+func setScriptLoaded(hash string, loaded bool) {
+	loadedScripts.Lock()
+	defer loadedScripts.Unlock()
+	if loaded {
+		loadedScripts.m[hash] = true
+	} else {
+		delete(loadedScripts.m, hash)
+	}
+}
+
+// NewScript 声明并注册脚本 keyCount 为 -1 时第一个参数为 key 的数量
+func NewScript(keyCount int, src string) *Script {
+	s := &Script{
+		keyCount: keyCount,
+		src:      src,
+		script:   redigo.NewScript(keyCount, src),
+	}
+	scripts.Lock()
+	scripts.list = append(scripts.list, s)
+	scripts.Unlock()
+	return s
+}
+
+// Hash 脚本的 sha1
+func (s *Script) Hash() string {
+	return s.script.Hash()
+}
+
+// Load SCRIPT LOAD 加载脚本
+func (s *Script) Load(conn redigo.Conn) error {
+	if err := s.script.Load(conn); err != nil {
+		return err
+	}
+	setScriptLoaded(s.Hash(), true)
+	return nil
+}
+
+// Do EVALSHA 执行脚本 返回 NOSCRIPT 时使用 EVAL 重试, 参数默认使用json格式
+func (s *Script) Do(conn redigo.Conn, keysAndArgs ...interface{}) *Res {
+	if err := converArgs(keysAndArgs...); err != nil {
+		return Result(nil, err)
+	}
+	reply, err := s.script.Do(conn, keysAndArgs...)
+	if err == nil {
+		setScriptLoaded(s.Hash(), true) // EVAL 回退后服务端也已加载
+	}
+	return Result(reply, err)
+}
+
+// Send 发送 EVALSHA 不处理 NOSCRIPT, 管道中请使用 Pipeline.Script
+func (s *Script) Send(conn redigo.Conn, keysAndArgs ...interface{}) error {
+	if err := converArgs(keysAndArgs...); err != nil {
+		return err
+	}
+	return s.script.SendHash(conn, keysAndArgs...)
+}
+
+// evalArgs EVAL/EVALSHA 的参数 首个参数为脚本或 sha1
+func (s *Script) evalArgs(spec string, keysAndArgs []interface{}) []interface{} {
+	args := make([]interface{}, 0, len(keysAndArgs)+2)
+	args = append(args, spec)
+	if s.keyCount >= 0 {
+		args = append(args, s.keyCount)
+	}
+	return append(args, keysAndArgs...)
+}
+
+// LoadScripts SCRIPT LOAD 所有已注册的脚本 一般在连接池创建后调用, multiredigopool 注册连接池时会自动加载
+func LoadScripts(conn redigo.Conn) error {
+	scripts.Lock()
+	list := append([]*Script{}, scripts.list...)
+	scripts.Unlock()
+
+	for _, s := range list {
+		if err := conn.Send("SCRIPT", "LOAD", s.src); err != nil {
+			return err
+		}
+	}
+	if err := conn.Flush(); err != nil {
+		return err
+	}
+	for _, s := range list {
+		if _, err := conn.Receive(); err != nil {
+			return err
+		}
+		setScriptLoaded(s.Hash(), true)
+	}
+	return nil
+}
+
+// PreloadScripts 从连接池取连接 加载所有已注册的脚本
+func PreloadScripts(pool *redigo.Pool) error {
+	conn := pool.Get()
+	defer conn.Close()
+	return LoadScripts(conn)
+}
+
+// IsNoScript 是否为脚本不存在的错误
+func IsNoScript(err error) bool {
+	e, ok := err.(redigo.Error)
+	return ok && strings.HasPrefix(string(e), "NOSCRIPT ")
+}
@@ -0,0 +1,69 @@
+package redigo
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	redigo "github.com/gomodule/redigo/redis"
+)
+
+func TestIsNoScript(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"noscript", redigo.Error("NOSCRIPT No matching script. Please use EVAL."), true},
+		{"other redis error", redigo.Error("ERR unknown command"), false},
+		{"other error", errors.New("NOSCRIPT"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsNoScript(tt.err); got != tt.want {
+				t.Errorf("IsNoScript() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPreloadScripts(t *testing.T) {
+	resetLoadedScripts()
+	loaded := map[string]bool{}
+	conn := newFakeConn(func(args []string) (interface{}, error) {
+		switch args[0] {
+		case "SCRIPT":
+			loaded[args[2]] = true
+			return []byte("sha1"), nil
+		case "EVALSHA":
+			return nil, redigo.Error("NOSCRIPT No matching script. Please use EVAL.")
+		case "EVAL":
+			return []byte("value"), nil
+		}
+		return nil, nil
+	})
+
+	if err := PreloadScripts(fakePool(conn)); err != nil {
+		t.Fatalf("PreloadScripts() error = %v", err)
+	}
+	for _, s := range []*Script{testLoadedScript, testMissingScript, delayPromoteScript, fixedWindowScript} {
+		if !loaded[s.src] || !scriptLoaded(s.Hash()) {
+			t.Errorf("PreloadScripts() not loaded: %v", s.src)
+		}
+	}
+
+	// 服务端没有脚本时 Do 回退 EVAL 成功后仍视为已加载
+	resetLoadedScripts()
+	conn.commands = nil
+	var v string
+	if ok, err := testLoadedScript.Do(conn, "k").Value(&v); err != nil || !ok || v != "value" {
+		t.Errorf("Do() = %v, %v, %v", v, ok, err)
+	}
+	if len(conn.commands) != 2 || !strings.HasPrefix(conn.commands[0], "EVALSHA ") || !strings.HasPrefix(conn.commands[1], "EVAL ") {
+		t.Errorf("Do() commands = %q", conn.commands)
+	}
+	if !scriptLoaded(testLoadedScript.Hash()) {
+		t.Errorf("Do() script not marked loaded")
+	}
+}
@@ -1,6 +1,9 @@
 package redigo
 
 import (
+	"fmt"
+	"reflect"
+
 	"github.com/fatih/structs"
 	redigo "github.com/gomodule/redigo/redis"
 )
@@ -21,19 +24,87 @@ import (
 //             5) "user-id"
 //             6) "388234"
 
-// 一个 key 一条 消息
-func decodeReply(reply interface{}, v interface{}) (string, error) {
-	streamReply := reply.([]interface{})[0] // 第一个 stream key 的返回
-	// streamName := string(streamReply.([]interface{})[0].([]byte))
-	streamData := streamReply.([]interface{})[1]
-	msg := streamData.([]interface{})[0] // 第一条消息
-	msgID := string(msg.([]interface{})[0].([]byte))
-	msgBody := msg.([]interface{})
-	err := redigo.ScanStruct(msgBody, v)
+// StreamMessage stream 中的一条消息
+type StreamMessage struct {
+	Stream string
+	ID     string
+	Fields map[string]string // 消息已被删除时为 nil
+}
+
+// Scan 按 redis tag 将字段解析进结构体指针
+func (msg *StreamMessage) Scan(v interface{}) error {
+	src := make([]interface{}, 0, 2*len(msg.Fields))
+	for key, val := range msg.Fields {
+		src = append(src, []byte(key), []byte(val))
+	}
+	return redigo.ScanStruct(src, v)
+}
+
+// decodeMessage 解析一条消息 [id, [field, value, ...]]
+func decodeMessage(stream string, reply interface{}) (StreamMessage, error) {
+	msg := StreamMessage{Stream: stream}
+	entry, err := redigo.Values(reply, nil)
 	if err != nil {
-		return "", err
+		return msg, fmt.Errorf("invalid stream message: %v", err)
+	}
+	if len(entry) != 2 {
+		return msg, fmt.Errorf("invalid stream message: got %v elements, want 2", len(entry))
+	}
+	if msg.ID, err = redigo.String(entry[0], nil); err != nil {
+		return msg, fmt.Errorf("invalid stream message id: %v", err)
+	}
+	if entry[1] == nil {
+		return msg, nil
+	}
+	if msg.Fields, err = redigo.StringMap(entry[1], nil); err != nil {
+		return msg, fmt.Errorf("invalid stream message %v: %v", msg.ID, err)
+	}
+	return msg, nil
+}
+
+// decodeMessages 解析消息列表 如 XRANGE/XCLAIM 的返回, 为 nil 的条目跳过
+func decodeMessages(stream string, reply interface{}) ([]StreamMessage, error) {
+	entries, err := redigo.Values(reply, nil)
+	if err != nil && err != redigo.ErrNil {
+		return nil, fmt.Errorf("invalid stream messages: %v", err)
+	}
+	msgs := []StreamMessage{}
+	for _, entry := range entries {
+		if entry == nil {
+			continue
+		}
+		msg, err := decodeMessage(stream, entry)
+		if err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, msg)
 	}
-	return msgID, nil
+	return msgs, nil
+}
+
+// decodeStreams 解析 XREAD/XREADGROUP 的返回 [[stream, [message, ...]], ...]
+func decodeStreams(reply interface{}) ([]StreamMessage, error) {
+	streams, err := redigo.Values(reply, nil)
+	if err != nil && err != redigo.ErrNil {
+		return nil, fmt.Errorf("invalid streams reply: %v", err)
+	}
+	msgs := []StreamMessage{}
+	for _, stream := range streams {
+		pair, err := redigo.Values(stream, nil)
+		if err != nil || len(pair) != 2 {
+			return nil, fmt.Errorf("invalid streams reply: %v", stream)
+		}
+		name, err := redigo.String(pair[0], nil)
+		if err != nil {
+			return nil, fmt.Errorf("invalid stream name: %v", err)
+		}
+		streamMsgs, err := decodeMessages(name, pair[1])
+		if err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, streamMsgs...)
+	}
+	return msgs, nil
 }
 
 // XAdd xadd  data:struct
@@ -49,87 +120,181 @@ func XAdd(conn redigo.Conn, key string, maxlen int, v interface{}) (string, erro
 	return redigo.String(conn.Do("XADD", args...))
 }
 
-// XAddPipeline xadd的pipeline模式
+// XAddPipeline xadd的pipeline模式 返回每条消息的 id, 出错时返回第一个错误
 func XAddPipeline(conn redigo.Conn, key string, maxlen int, v ...interface{}) ([]string, error) {
 	if maxlen == 0 {
 		maxlen = 10000
 	}
 
-	for _, vv := range v {
+	p := NewPipeline(conn)
+	ids := make([]string, len(v))
+	for i, vv := range v {
 		args := []interface{}{key, "MAXLEN", "~", maxlen, "*"}
 		for key, val := range structs.Map(vv) {
 			args = append(args, key, val)
 		}
-		err := conn.Send("XADD", args...)
-		if err != nil {
-			return nil, err
-		}
+		p.Value(&ids[i], "XADD", args...)
 	}
-	err := conn.Flush()
-	if err != nil {
+	if err := p.Exec(); err != nil {
 		return nil, err
 	}
-	r := []string{}
-	for i := 0; i < len(v); i++ {
-		reply, _ := redigo.String(conn.Receive())
-		r = append(r, reply)
+	return ids, nil
+}
+
+// blockArgs block 为 0 时一直阻塞, 小于 0 不阻塞
+func blockArgs(block int64) []interface{} {
+	if block < 0 {
+		return nil
 	}
-	return r, nil
+	return []interface{}{"BLOCK", block}
 }
 
-// XRead v 结构体指针, id 有2个特殊值: 0-0 从头开始读, $ 从加入时开始读
-func XRead(conn redigo.Conn, key, id string, v interface{}, block int64) (bool, string, error) {
-	if block == 0 {
-		block = 1000
+func streamsArgs(keys, ids []string) ([]interface{}, error) {
+	if len(keys) == 0 || len(keys) != len(ids) {
+		return nil, fmt.Errorf("keys and ids must be the same non-zero length")
 	}
-	if id == "" {
-		id = "$"
+	args := []interface{}{"STREAMS"}
+	for _, key := range keys {
+		args = append(args, key)
+	}
+	for _, id := range ids {
+		args = append(args, id)
 	}
+	return args, nil
+}
 
-	args := []interface{}{"COUNT", 1, "BLOCK", block, "STREAMS", key, id}
-	reply, err := conn.Do("XREAD", args...)
+// XReadMessages 从多个 stream 读取消息 keys 与 ids 一一对应, count 为每个 stream 的最大数量 0 不限制
+// block 毫秒 0 一直阻塞直到有消息, 小于 0 不阻塞; 超时无消息时返回空
+func XReadMessages(conn redigo.Conn, keys, ids []string, count int, block int64) ([]StreamMessage, error) {
+	streams, err := streamsArgs(keys, ids)
 	if err != nil {
-		return false, "", err
+		return nil, err
 	}
-	if reply == nil {
-		return false, "", nil
+	args := []interface{}{}
+	if count > 0 {
+		args = append(args, "COUNT", count)
 	}
-	id, err = decodeReply(reply, v)
+	args = append(args, blockArgs(block)...)
+	reply, err := conn.Do("XREAD", append(args, streams...)...)
 	if err != nil {
-		return false, "", err
+		return nil, err
 	}
-	return false, id, err
+	return decodeStreams(reply)
 }
 
-// XReadGroup v 结构体指针
-func XReadGroup(conn redigo.Conn, key, groupName, consumerName, id string, v interface{}, block int, isAck bool) (bool, string, error) {
-	if block == 0 {
-		block = 1000
+// XReadGroupMessages 以消费者组从多个 stream 读取消息 id 为 > 读取新消息, 其他 id 读取本消费者已投递未确认的消息
+// 其他参数同 XReadMessages, isAck 为 false 时 NOACK
+func XReadGroupMessages(conn redigo.Conn, groupName, consumerName string, keys, ids []string, count int, block int64, isAck bool) ([]StreamMessage, error) {
+	streams, err := streamsArgs(keys, ids)
+	if err != nil {
+		return nil, err
 	}
 	if consumerName == "" {
 		consumerName = groupName
 	}
-	if id == "" {
-		id = ">"
+	args := []interface{}{"GROUP", groupName, consumerName}
+	if count > 0 {
+		args = append(args, "COUNT", count)
 	}
-
-	args := []interface{}{"GROUP", groupName, consumerName, "COUNT", 1, "BLOCK", block}
+	args = append(args, blockArgs(block)...)
 	if !isAck {
 		args = append(args, "NOACK")
 	}
-	args = append(args, "STREAMS", key, ">")
-	reply, err := conn.Do("XREADGROUP", args...)
+	reply, err := conn.Do("XREADGROUP", append(args, streams...)...)
 	if err != nil {
-		return false, "", err
+		return nil, err
 	}
-	if reply == nil {
-		return false, "", nil
+	return decodeStreams(reply)
+}
+
+// XRange 按 id 正序读取 start end 可用 - +, count 0 不限制
+func XRange(conn redigo.Conn, key, start, end string, count int) ([]StreamMessage, error) {
+	args := []interface{}{key, start, end}
+	if count > 0 {
+		args = append(args, "COUNT", count)
 	}
-	id, err = decodeReply(reply, v)
+	reply, err := conn.Do("XRANGE", args...)
 	if err != nil {
+		return nil, err
+	}
+	return decodeMessages(key, reply)
+}
+
+// XRevRange 按 id 倒序读取 end start 可用 + -, count 0 不限制
+func XRevRange(conn redigo.Conn, key, end, start string, count int) ([]StreamMessage, error) {
+	args := []interface{}{key, end, start}
+	if count > 0 {
+		args = append(args, "COUNT", count)
+	}
+	reply, err := conn.Do("XREVRANGE", args...)
+	if err != nil {
+		return nil, err
+	}
+	return decodeMessages(key, reply)
+}
+
+// ScanMessages 将消息解析进结构体切片 dest 为 *[]T 或 *[]*T, 已删除的消息为零值
+func ScanMessages(msgs []StreamMessage, dest interface{}) error {
+	rv := reflect.ValueOf(dest)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("dest must be a pointer of slice")
+	}
+	slice := rv.Elem()
+	elemType := slice.Type().Elem()
+	isPtr := elemType.Kind() == reflect.Ptr
+	if isPtr {
+		elemType = elemType.Elem()
+	}
+	if elemType.Kind() != reflect.Struct {
+		return fmt.Errorf("slice element must be struct or pointer of struct")
+	}
+
+	result := reflect.MakeSlice(slice.Type(), 0, len(msgs))
+	for i := range msgs {
+		elem := reflect.New(elemType)
+		if err := msgs[i].Scan(elem.Interface()); err != nil {
+			return fmt.Errorf("message %v: %v", msgs[i].ID, err)
+		}
+		if isPtr {
+			result = reflect.Append(result, elem)
+		} else {
+			result = reflect.Append(result, elem.Elem())
+		}
+	}
+	slice.Set(result)
+	return nil
+}
+
+// XRead v 结构体指针, id 有2个特殊值: 0-0 从头开始读, $ 从加入时开始读
+// 读到消息返回 true 和消息 id, 多条消息请使用 XReadMessages
+func XRead(conn redigo.Conn, key, id string, v interface{}, block int64) (bool, string, error) {
+	if id == "" {
+		id = "$"
+	}
+	msgs, err := XReadMessages(conn, []string{key}, []string{id}, 1, block)
+	if err != nil || len(msgs) == 0 {
+		return false, "", err
+	}
+	if err := msgs[0].Scan(v); err != nil {
+		return false, "", err
+	}
+	return true, msgs[0].ID, nil
+}
+
+// XReadGroup v 结构体指针, id 默认 > 读取新消息, 其他 id 读取本消费者已投递未确认的消息
+// 读到消息返回 true 和消息 id, 多条消息请使用 XReadGroupMessages
+func XReadGroup(conn redigo.Conn, key, groupName, consumerName, id string, v interface{}, block int, isAck bool) (bool, string, error) {
+	if id == "" {
+		id = ">"
+	}
+	msgs, err := XReadGroupMessages(conn, groupName, consumerName, []string{key}, []string{id}, 1, int64(block), isAck)
+	if err != nil || len(msgs) == 0 {
+		return false, "", err
+	}
+	if err := msgs[0].Scan(v); err != nil {
 		return false, "", err
 	}
-	return true, id, err
+	return true, msgs[0].ID, nil
 }
 
 // XGroupCreate 创建消费者组
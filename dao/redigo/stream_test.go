@@ -0,0 +1,150 @@
+package redigo
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func streamEntry(id string, fields ...string) interface{} {
+	if fields == nil {
+		return []interface{}{[]byte(id), nil}
+	}
+	values := []interface{}{}
+	for _, field := range fields {
+		values = append(values, []byte(field))
+	}
+	return []interface{}{[]byte(id), values}
+}
+
+func TestDecodeMessages(t *testing.T) {
+	tests := []struct {
+		name    string
+		reply   interface{}
+		want    []StreamMessage
+		wantErr bool
+	}{
+		{"nil reply", nil, []StreamMessage{}, false},
+		{"empty", []interface{}{}, []StreamMessage{}, false},
+		{
+			name:  "messages",
+			reply: []interface{}{streamEntry("1-0", "a", "1"), streamEntry("2-0", "b", "2", "c", "3")},
+			want: []StreamMessage{
+				{Stream: "s", ID: "1-0", Fields: map[string]string{"a": "1"}},
+				{Stream: "s", ID: "2-0", Fields: map[string]string{"b": "2", "c": "3"}},
+			},
+		},
+		{"nil entry skipped", []interface{}{nil, streamEntry("1-0", "a", "1")},
+			[]StreamMessage{{Stream: "s", ID: "1-0", Fields: map[string]string{"a": "1"}}}, false},
+		{"deleted message", []interface{}{streamEntry("1-0")}, []StreamMessage{{Stream: "s", ID: "1-0"}}, false},
+		{"not array", []byte("x"), nil, true},
+		{"entry not array", []interface{}{int64(1)}, nil, true},
+		{"entry too short", []interface{}{[]interface{}{[]byte("1-0")}}, nil, true},
+		{"entry too long", []interface{}{[]interface{}{[]byte("1-0"), []interface{}{}, []byte("x")}}, nil, true},
+		{"bad id", []interface{}{[]interface{}{[]interface{}{}, []interface{}{}}}, nil, true},
+		{"odd fields", []interface{}{streamEntry("1-0", "a")}, nil, true},
+		{"nested fields", []interface{}{[]interface{}{[]byte("1-0"), []interface{}{[]byte("a"), []interface{}{[]byte("1")}}}}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeMessages("s", tt.reply)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("decodeMessages() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decodeMessages() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeStreams(t *testing.T) {
+	tests := []struct {
+		name    string
+		reply   interface{}
+		want    []StreamMessage
+		wantErr bool
+	}{
+		{"timeout", nil, []StreamMessage{}, false},
+		{
+			name: "streams",
+			reply: []interface{}{
+				[]interface{}{[]byte("s1"), []interface{}{streamEntry("1-0", "a", "1")}},
+				[]interface{}{[]byte("s2"), []interface{}{nil, streamEntry("2-0", "b", "2")}},
+				[]interface{}{[]byte("s3"), []interface{}{}},
+			},
+			want: []StreamMessage{
+				{Stream: "s1", ID: "1-0", Fields: map[string]string{"a": "1"}},
+				{Stream: "s2", ID: "2-0", Fields: map[string]string{"b": "2"}},
+			},
+		},
+		{"not array", []byte("x"), nil, true},
+		{"nil stream", []interface{}{nil}, nil, true},
+		{"stream not pair", []interface{}{[]interface{}{[]byte("s1")}}, nil, true},
+		{"bad stream name", []interface{}{[]interface{}{[]interface{}{}, []interface{}{}}}, nil, true},
+		{"bad messages", []interface{}{[]interface{}{[]byte("s1"), []byte("x")}}, nil, true},
+		{"bad message", []interface{}{[]interface{}{[]byte("s1"), []interface{}{[]interface{}{[]byte("1-0")}}}}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeStreams(tt.reply)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("decodeStreams() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decodeStreams() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestXReadBlock(t *testing.T) {
+	tests := []struct {
+		name  string
+		block int64
+		want  string
+	}{
+		{"forever", 0, "XREAD COUNT 1 BLOCK 0 STREAMS s $"},
+		{"timeout", 500, "XREAD COUNT 1 BLOCK 500 STREAMS s $"},
+		{"no block", -1, "XREAD COUNT 1 STREAMS s $"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := newFakeConn(func(args []string) (interface{}, error) {
+				return nil, nil
+			})
+			ok, _, err := XRead(conn, "s", "", &struct{}{}, tt.block)
+			if ok || err != nil {
+				t.Fatalf("XRead() = %v, %v", ok, err)
+			}
+			if got := strings.Join(conn.commands, "; "); got != tt.want {
+				t.Errorf("XRead() command = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestXReadGroupBlock(t *testing.T) {
+	tests := []struct {
+		name  string
+		block int64
+		want  string
+	}{
+		{"forever", 0, "XREADGROUP GROUP g c COUNT 2 BLOCK 0 STREAMS s >"},
+		{"timeout", 500, "XREADGROUP GROUP g c COUNT 2 BLOCK 500 STREAMS s >"},
+		{"no block", -1, "XREADGROUP GROUP g c COUNT 2 STREAMS s >"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := newFakeConn(func(args []string) (interface{}, error) {
+				return nil, nil
+			})
+			if _, err := XReadGroupMessages(conn, "g", "c", []string{"s"}, []string{">"}, 2, tt.block, true); err != nil {
+				t.Fatalf("XReadGroupMessages() error = %v", err)
+			}
+			if got := strings.Join(conn.commands, "; "); got != tt.want {
+				t.Errorf("XReadGroupMessages() command = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,398 @@
+package redigo
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	mlogger "github.com/cheetah-fun-gs/goplus/multier/multilogger"
+	redigo "github.com/gomodule/redigo/redis"
+)
+
+// StreamHandler 消息处理函数 返回 nil 时 XACK, 否则消息留在 pending 中 空闲超过 MinIdle 后重新投递
+// ctx 在 worker 停止时取消
+type StreamHandler func(ctx context.Context, msg *StreamMessage) error
+
+// StreamWorkerOptions 消费者组 worker 参数
+type StreamWorkerOptions struct {
+	Consumer      string        // 消费者名称 默认 主机名-进程号
+	Count         int           // 单次读取的消息数 默认 10
+	Block         time.Duration // 读取阻塞时间 默认 1s, 连接池的读超时须大于该值
+	Concurrency   int           // 并发处理数 默认 1
+	MinIdle       time.Duration // pending 消息空闲超过该时间 被认领重新处理, 须大于处理耗时 默认 30s
+	ClaimInterval time.Duration // 认领检查间隔 默认 MinIdle
+	MaxDeliveries int           // 投递次数达到后 移入死信 stream, 0 不限制
+	DeadLetter    string        // 死信 stream 默认 key + ":dead"
+	MLogName      string        // 错误日志 默认 default
+}
+
+// StreamWorker 消费者组 worker: 批量读取, 并发处理, 成功后确认, 认领其他消费者遗留的消息
+//
+//	w := redigoplus.NewStreamWorker(pool, "events", "group", handler, nil)
+//	go w.Run(context.Background())
+//	...
+//	w.Stop()
+type StreamWorker struct {
+	pool    *redigo.Pool
+	key     string
+	group   string
+	handler StreamHandler
+	opts    StreamWorkerOptions
+	mutex   sync.Mutex
+	cancel  context.CancelFunc
+	done    chan struct{}
+}
+
+// NewStreamWorker ...
+func NewStreamWorker(pool *redigo.Pool, key, group string, handler StreamHandler, opts *StreamWorkerOptions) *StreamWorker {
+	w := &StreamWorker{
+		pool:    pool,
+		key:     key,
+		group:   group,
+		handler: handler,
+	}
+	if opts != nil {
+		w.opts = *opts
+	}
+	if w.opts.Consumer == "" {
+		hostname, _ := os.Hostname()
+		w.opts.Consumer = fmt.Sprintf("%v-%v", hostname, os.Getpid())
+	}
+	if w.opts.Count <= 0 {
+		w.opts.Count = 10
+	}
+	if w.opts.Block <= 0 {
+		w.opts.Block = time.Second
+	}
+	if w.opts.Concurrency <= 0 {
+		w.opts.Concurrency = 1
+	}
+	if w.opts.MinIdle <= 0 {
+		w.opts.MinIdle = 30 * time.Second
+	}
+	if w.opts.ClaimInterval <= 0 {
+		w.opts.ClaimInterval = w.opts.MinIdle
+	}
+	if w.opts.DeadLetter == "" {
+		w.opts.DeadLetter = key + ":dead"
+	}
+	if w.opts.MLogName == "" {
+		w.opts.MLogName = "default"
+	}
+	return w
+}
+
+// Run 运行直到 ctx 取消或 Stop, 停止时不再读取新消息, 等待处理中的消息完成后返回
+// 启动时先处理本消费者未确认的消息
+func (w *StreamWorker) Run(ctx context.Context) error {
+	w.mutex.Lock()
+	if w.cancel != nil {
+		w.mutex.Unlock()
+		return fmt.Errorf("stream worker is running")
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	w.cancel, w.done = cancel, done
+	w.mutex.Unlock()
+
+	defer func() {
+		cancel()
+		w.mutex.Lock()
+		w.cancel, w.done = nil, nil
+		w.mutex.Unlock()
+		close(done)
+	}()
+
+	if err := w.createGroup(); err != nil {
+		return err
+	}
+
+	jobs := make(chan StreamMessage, w.opts.Count)
+	wg := &sync.WaitGroup{}
+	for i := 0; i < w.opts.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for msg := range jobs {
+				w.handle(ctx, msg)
+			}
+		}()
+	}
+
+	w.readPending(ctx, jobs)
+	lastClaim := time.Now()
+	for ctx.Err() == nil {
+		if time.Since(lastClaim) >= w.opts.ClaimInterval {
+			w.claim(ctx, jobs)
+			lastClaim = time.Now()
+		}
+		msgs, err := w.read(">")
+		if err != nil {
+			mlogger.WarnN(w.opts.MLogName, "stream worker %v/%v read err: %v", w.key, w.group, err)
+			w.sleep(ctx)
+			continue
+		}
+		for _, msg := range msgs {
+			jobs <- msg
+		}
+	}
+
+	close(jobs)
+	wg.Wait()
+	return nil
+}
+
+// Stop 停止 并等待 Run 返回
+func (w *StreamWorker) Stop() {
+	w.mutex.Lock()
+	cancel, done := w.cancel, w.done
+	w.mutex.Unlock()
+	if cancel == nil {
+		return
+	}
+	cancel()
+	<-done
+}
+
+func (w *StreamWorker) sleep(ctx context.Context) {
+	select {
+	case <-ctx.Done():
+	case <-time.After(w.opts.Block):
+	}
+}
+
+func (w *StreamWorker) createGroup() error {
+	conn := w.pool.Get()
+	defer conn.Close()
+
+	err := XGroupCreate(conn, w.key, w.group)
+	if e, ok := err.(redigo.Error); ok && strings.HasPrefix(string(e), "BUSYGROUP ") {
+		return nil
+	}
+	return err
+}
+
+func (w *StreamWorker) read(id string) ([]StreamMessage, error) {
+	conn := w.pool.Get()
+	defer conn.Close()
+
+	block := int64(-1) // 读取 pending 不阻塞
+	if id == ">" {
+		block = int64(w.opts.Block / time.Millisecond)
+	}
+	return XReadGroupMessages(conn, w.group, w.opts.Consumer, []string{w.key}, []string{id}, w.opts.Count, block, true)
+}
+
+// readPending 读取本消费者已投递未确认的消息 已删除的消息直接确认
+func (w *StreamWorker) readPending(ctx context.Context, jobs chan<- StreamMessage) {
+	id := "0"
+	for ctx.Err() == nil {
+		msgs, err := w.read(id)
+		if err != nil {
+			mlogger.WarnN(w.opts.MLogName, "stream worker %v/%v read pending err: %v", w.key, w.group, err)
+			return
+		}
+		if len(msgs) == 0 {
+			return
+		}
+		for _, msg := range msgs {
+			if msg.Fields == nil {
+				w.ack(msg.ID)
+			} else {
+				jobs <- msg
+			}
+		}
+		id = msgs[len(msgs)-1].ID
+	}
+}
+
+func (w *StreamWorker) handle(ctx context.Context, msg StreamMessage) {
+	err := func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("panic: %v", r)
+			}
+		}()
+		return w.handler(ctx, &msg)
+	}()
+	if err != nil {
+		mlogger.WarnN(w.opts.MLogName, "stream worker %v/%v handle %v err: %v", w.key, w.group, msg.ID, err)
+		return
+	}
+	w.ack(msg.ID)
+}
+
+func (w *StreamWorker) ack(ids ...string) {
+	conn := w.pool.Get()
+	defer conn.Close()
+
+	args := []interface{}{w.key, w.group}
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	if _, err := conn.Do("XACK", args...); err != nil {
+		mlogger.WarnN(w.opts.MLogName, "stream worker %v/%v ack %v err: %v", w.key, w.group, ids, err)
+	}
+}
+
+// claim 遍历消费者组的 pending 消息, 空闲超过 MinIdle 的 XCLAIM 到本消费者处理, 投递次数过多的移入死信
+func (w *StreamWorker) claim(ctx context.Context, jobs chan<- StreamMessage) {
+	start := "-"
+	for ctx.Err() == nil {
+		entries, err := w.pending(start)
+		if err != nil {
+			mlogger.WarnN(w.opts.MLogName, "stream worker %v/%v pending err: %v", w.key, w.group, err)
+			return
+		}
+
+		retries, deads := []string{}, []string{}
+		deliveries := map[string]int64{}
+		for _, entry := range entries {
+			if entry.idle < w.opts.MinIdle {
+				continue
+			}
+			if w.opts.MaxDeliveries > 0 && entry.deliveries >= int64(w.opts.MaxDeliveries) {
+				deads = append(deads, entry.id)
+				deliveries[entry.id] = entry.deliveries
+			} else {
+				retries = append(retries, entry.id)
+			}
+		}
+
+		if len(retries) > 0 {
+			msgs, err := w.xclaim(retries)
+			if err != nil {
+				mlogger.WarnN(w.opts.MLogName, "stream worker %v/%v claim err: %v", w.key, w.group, err)
+				return
+			}
+			for _, msg := range msgs {
+				if msg.Fields == nil {
+					w.ack(msg.ID)
+				} else {
+					jobs <- msg
+				}
+			}
+		}
+		if len(deads) > 0 {
+			if err := w.deadLetter(deads, deliveries); err != nil {
+				mlogger.WarnN(w.opts.MLogName, "stream worker %v/%v dead letter err: %v", w.key, w.group, err)
+				return
+			}
+		}
+
+		if len(entries) < w.opts.Count {
+			return
+		}
+		next, err := nextStreamID(entries[len(entries)-1].id)
+		if err != nil {
+			mlogger.WarnN(w.opts.MLogName, "stream worker %v/%v pending err: %v", w.key, w.group, err)
+			return
+		}
+		start = next
+	}
+}
+
+type pendingEntry struct {
+	id         string
+	consumer   string
+	idle       time.Duration
+	deliveries int64
+}
+
+// pending XPENDING 扩展格式 [[id, consumer, idle, deliveries], ...]
+func (w *StreamWorker) pending(start string) ([]pendingEntry, error) {
+	conn := w.pool.Get()
+	defer conn.Close()
+
+	replies, err := redigo.Values(conn.Do("XPENDING", w.key, w.group, start, "+", w.opts.Count))
+	if err != nil {
+		return nil, err
+	}
+	entries := []pendingEntry{}
+	for _, reply := range replies {
+		values, err := redigo.Values(reply, nil)
+		if err != nil || len(values) != 4 {
+			return nil, fmt.Errorf("invalid pending entry: %v", reply)
+		}
+		entry := pendingEntry{}
+		if entry.id, err = redigo.String(values[0], nil); err != nil {
+			return nil, err
+		}
+		if entry.consumer, err = redigo.String(values[1], nil); err != nil {
+			return nil, err
+		}
+		idle, err := redigo.Int64(values[2], nil)
+		if err != nil {
+			return nil, err
+		}
+		entry.idle = time.Duration(idle) * time.Millisecond
+		if entry.deliveries, err = redigo.Int64(values[3], nil); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// xclaim 认领仍空闲超过 MinIdle 的消息 已被其他消费者认领的不返回
+func (w *StreamWorker) xclaim(ids []string) ([]StreamMessage, error) {
+	conn := w.pool.Get()
+	defer conn.Close()
+
+	args := []interface{}{w.key, w.group, w.opts.Consumer, int64(w.opts.MinIdle / time.Millisecond)}
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	reply, err := conn.Do("XCLAIM", args...)
+	if err != nil {
+		return nil, err
+	}
+	return decodeMessages(w.key, reply)
+}
+
+// 写入死信并确认 同一脚本内执行, XADD 失败时不会 XACK; KEYS 为死信 stream 和来源 stream, ARGV 为 group id field value...
+var deadLetterScript = NewScript(2, `redis.call("XADD", KEYS[1], "*", unpack(ARGV, 3))
+	return redis.call("XACK", KEYS[2], ARGV[1], ARGV[2])`)
+
+// deadLetter 认领后写入死信 stream 并确认, 附带来源信息 _stream _group _id _deliveries
+func (w *StreamWorker) deadLetter(ids []string, deliveries map[string]int64) error {
+	msgs, err := w.xclaim(ids)
+	if err != nil {
+		return err
+	}
+
+	conn := w.pool.Get()
+	defer conn.Close()
+
+	p := NewPipeline(conn)
+	for _, msg := range msgs {
+		if msg.Fields == nil { // 已删除
+			p.Do("XACK", w.key, w.group, msg.ID)
+			continue
+		}
+		args := []interface{}{w.opts.DeadLetter, w.key, w.group, msg.ID,
+			"_stream", w.key, "_group", w.group, "_id", msg.ID, "_deliveries", deliveries[msg.ID]}
+		for field, value := range msg.Fields {
+			args = append(args, field, value)
+		}
+		p.Script(deadLetterScript, args...)
+	}
+	return p.Exec()
+}
+
+// nextStreamID 紧随 id 之后的 id, 用于分页
+func nextStreamID(id string) (string, error) {
+	splits := strings.SplitN(id, "-", 2)
+	if len(splits) != 2 {
+		return "", fmt.Errorf("invalid stream id: %v", id)
+	}
+	seq, err := strconv.ParseUint(splits[1], 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("invalid stream id: %v", id)
+	}
+	return fmt.Sprintf("%v-%v", splits[0], seq+1), nil
+}
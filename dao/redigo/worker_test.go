@@ -0,0 +1,330 @@
+package redigo
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	redigo "github.com/gomodule/redigo/redis"
+)
+
+// fakeServer 并发安全的假服务端 每次取连接都是新的 fakeConn, 命令记录在一起
+type fakeServer struct {
+	mutex    sync.Mutex
+	handler  func(args []string) (interface{}, error)
+	commands []string
+}
+
+func (s *fakeServer) pool() *redigo.Pool {
+	return &redigo.Pool{
+		Dial: func() (redigo.Conn, error) {
+			return newFakeConn(s.handle), nil
+		},
+	}
+}
+
+func (s *fakeServer) handle(args []string) (interface{}, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.commands = append(s.commands, strings.Join(args, " "))
+	return s.handler(args)
+}
+
+// acked 已确认的消息 id
+func (s *fakeServer) acked() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	ids := []string{}
+	for _, command := range s.commands {
+		if strings.HasPrefix(command, "XACK ") {
+			ids = append(ids, strings.Fields(command)[3:]...)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// streamsReply XREADGROUP 的回复 没有消息时为 nil
+func streamsReply(key string, entries ...interface{}) interface{} {
+	if len(entries) == 0 {
+		return nil
+	}
+	return []interface{}{[]interface{}{[]byte(key), entries}}
+}
+
+func messageIDs(msgs []StreamMessage) []string {
+	ids := []string{}
+	for _, msg := range msgs {
+		ids = append(ids, msg.ID)
+	}
+	return ids
+}
+
+func TestNextStreamID(t *testing.T) {
+	tests := []struct {
+		id      string
+		want    string
+		wantErr bool
+	}{
+		{"1-0", "1-1", false},
+		{"1526985054069-9", "1526985054069-10", false},
+		{"1", "", true},
+		{"1-x", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			got, err := nextStreamID(tt.id)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("nextStreamID() = %v, %v, want %v, wantErr %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestStreamWorkerDeadLetter(t *testing.T) {
+	resetLoadedScripts()
+	conn := newFakeConn(func(args []string) (interface{}, error) {
+		switch args[0] {
+		case "XCLAIM":
+			return []interface{}{
+				[]interface{}{[]byte("1-0"), []interface{}{[]byte("a"), []byte("1")}},
+				[]interface{}{[]byte("2-0"), nil},
+				[]interface{}{[]byte("3-0"), []interface{}{[]byte("b"), []byte("2")}},
+			}, nil
+		case "SCRIPT":
+			return []interface{}{int64(1)}, nil
+		case "EVALSHA":
+			if args[6] == "3-0" { // XADD 失败 脚本内不会 XACK
+				return nil, redigo.Error("ERR stream is full")
+			}
+			return int64(1), nil
+		}
+		return int64(1), nil
+	})
+	w := NewStreamWorker(fakePool(conn), "events", "group", nil, &StreamWorkerOptions{Consumer: "c", MinIdle: time.Second})
+
+	err := w.deadLetter([]string{"1-0", "2-0", "3-0"}, map[string]int64{"1-0": 3, "3-0": 4})
+	if err == nil {
+		t.Errorf("deadLetter() want error")
+	}
+	hash := deadLetterScript.Hash()
+	want := []string{
+		"XCLAIM events group c 1000 1-0 2-0 3-0",
+		"SCRIPT EXISTS " + hash,
+		"EVALSHA " + hash + " 2 events:dead events group 1-0 _stream events _group group _id 1-0 _deliveries 3 a 1",
+		"XACK events group 2-0",
+		"EVALSHA " + hash + " 2 events:dead events group 3-0 _stream events _group group _id 3-0 _deliveries 4 b 2",
+	}
+	if !reflect.DeepEqual(conn.commands, want) {
+		t.Errorf("deadLetter() commands = %q, want %q", conn.commands, want)
+	}
+}
+
+func TestStreamWorkerReadPending(t *testing.T) {
+	pages := map[string]interface{}{
+		"0":   streamsReply("events", streamEntry("1-0", "a", "1"), streamEntry("2-0")),
+		"2-0": streamsReply("events", streamEntry("3-0", "a", "3")),
+		"3-0": streamsReply("events"),
+	}
+	conn := newFakeConn(func(args []string) (interface{}, error) {
+		if args[0] == "XREADGROUP" {
+			return pages[args[len(args)-1]], nil
+		}
+		return int64(1), nil
+	})
+	w := NewStreamWorker(fakePool(conn), "events", "group", nil, &StreamWorkerOptions{Consumer: "c", Count: 2})
+
+	jobs := make(chan StreamMessage, 10)
+	w.readPending(context.Background(), jobs)
+	close(jobs)
+	got := []StreamMessage{}
+	for msg := range jobs {
+		got = append(got, msg)
+	}
+	if ids := messageIDs(got); !reflect.DeepEqual(ids, []string{"1-0", "3-0"}) {
+		t.Errorf("readPending() jobs = %v", ids)
+	}
+	want := []string{
+		"XREADGROUP GROUP group c COUNT 2 STREAMS events 0",
+		"XACK events group 2-0",
+		"XREADGROUP GROUP group c COUNT 2 STREAMS events 2-0",
+		"XREADGROUP GROUP group c COUNT 2 STREAMS events 3-0",
+	}
+	if !reflect.DeepEqual(conn.commands, want) {
+		t.Errorf("readPending() commands = %q, want %q", conn.commands, want)
+	}
+}
+
+func TestStreamWorkerClaim(t *testing.T) {
+	resetLoadedScripts()
+	pending := func(id, consumer string, idle, deliveries int64) interface{} {
+		return []interface{}{[]byte(id), []byte(consumer), idle, deliveries}
+	}
+	conn := newFakeConn(func(args []string) (interface{}, error) {
+		switch args[0] {
+		case "XPENDING":
+			switch args[3] {
+			case "-":
+				return []interface{}{
+					pending("1-0", "other", 5000, 1),
+					pending("2-0", "other", 10, 1), // 空闲不足 不认领
+					pending("4-0", "other", 5000, 2),
+				}, nil
+			case "4-1":
+				return []interface{}{pending("5-0", "other", 5000, 3)}, nil
+			}
+		case "XCLAIM":
+			entries := []interface{}{}
+			for _, id := range args[5:] {
+				switch id {
+				case "1-0":
+					entries = append(entries, streamEntry(id, "a", "1"))
+				case "4-0": // 已删除
+					entries = append(entries, streamEntry(id))
+				case "5-0":
+					entries = append(entries, streamEntry(id, "a", "5"))
+				}
+			}
+			return entries, nil
+		case "SCRIPT":
+			return []interface{}{int64(1)}, nil
+		}
+		return int64(1), nil
+	})
+	w := NewStreamWorker(fakePool(conn), "events", "group", nil, &StreamWorkerOptions{
+		Consumer: "c", Count: 3, MinIdle: time.Second, MaxDeliveries: 3,
+	})
+
+	jobs := make(chan StreamMessage, 10)
+	w.claim(context.Background(), jobs)
+	close(jobs)
+	got := []StreamMessage{}
+	for msg := range jobs {
+		got = append(got, msg)
+	}
+	if ids := messageIDs(got); !reflect.DeepEqual(ids, []string{"1-0"}) {
+		t.Errorf("claim() jobs = %v", ids)
+	}
+	hash := deadLetterScript.Hash()
+	want := []string{
+		"XPENDING events group - + 3",
+		"XCLAIM events group c 1000 1-0 4-0",
+		"XACK events group 4-0",
+		"XPENDING events group 4-1 + 3",
+		"XCLAIM events group c 1000 5-0",
+		"SCRIPT EXISTS " + hash,
+		"EVALSHA " + hash + " 2 events:dead events group 5-0 _stream events _group group _id 5-0 _deliveries 3 a 5",
+	}
+	if !reflect.DeepEqual(conn.commands, want) {
+		t.Errorf("claim() commands = %q, want %q", conn.commands, want)
+	}
+}
+
+func TestStreamWorkerRun(t *testing.T) {
+	const concurrency = 3
+	var once sync.Once
+	server := &fakeServer{}
+	server.handler = func(args []string) (interface{}, error) {
+		switch args[0] {
+		case "XGROUP":
+			return nil, redigo.Error("BUSYGROUP Consumer Group name already exists")
+		case "XREADGROUP":
+			switch args[len(args)-1] {
+			case "0":
+				return streamsReply("events", streamEntry("1-0", "a", "1")), nil
+			case "1-0":
+				return nil, nil
+			}
+			var reply interface{}
+			once.Do(func() {
+				reply = streamsReply("events", streamEntry("2-0", "a", "2"), streamEntry("3-0", "a", "3"), streamEntry("4-0", "a", "4"))
+			})
+			return reply, nil
+		}
+		return int64(1), nil
+	}
+
+	release := make(chan struct{})
+	var mutex sync.Mutex
+	active, maxActive := 0, 0
+	handled := make(chan string, 10)
+	handler := func(ctx context.Context, msg *StreamMessage) error {
+		if msg.ID == "1-0" {
+			handled <- msg.ID
+			return fmt.Errorf("handle failed") // 不确认
+		}
+		mutex.Lock()
+		active++
+		if active > maxActive {
+			maxActive = active
+		}
+		mutex.Unlock()
+		<-release
+		mutex.Lock()
+		active--
+		mutex.Unlock()
+		handled <- msg.ID
+		return nil
+	}
+	w := NewStreamWorker(server.pool(), "events", "group", handler, &StreamWorkerOptions{
+		Consumer: "c", Count: 1, Block: time.Millisecond, Concurrency: concurrency, ClaimInterval: time.Hour,
+	})
+
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- w.Run(context.Background())
+	}()
+	// 等待 pending 消息处理完 新消息全部并发处理中
+	if id := <-handled; id != "1-0" {
+		t.Fatalf("first handled = %v, want pending 1-0", id)
+	}
+	deadline := time.Now().Add(time.Second)
+	for {
+		mutex.Lock()
+		n := active
+		mutex.Unlock()
+		if n == concurrency {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Run() active handlers = %v, want %v", n, concurrency)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if err := w.Run(context.Background()); err == nil {
+		t.Errorf("Run() twice want error")
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		w.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		t.Fatalf("Stop() returned before handlers finished")
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(release)
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatalf("Stop() not returned")
+	}
+	if err := <-runErr; err != nil {
+		t.Errorf("Run() error = %v", err)
+	}
+
+	if maxActive != concurrency {
+		t.Errorf("Run() max active = %v, want %v", maxActive, concurrency)
+	}
+	if got := server.acked(); !reflect.DeepEqual(got, []string{"2-0", "3-0", "4-0"}) {
+		t.Errorf("Run() acked = %v", got)
+	}
+	w.Stop() // 已停止 直接返回
+}
@@ -1,37 +1,35 @@
 package redigo
 
 import (
-	"fmt"
-	"strconv"
-
 	jsonplus "github.com/cheetah-fun-gs/goplus/encoding/json"
 	redigo "github.com/gomodule/redigo/redis"
 )
 
+// 查找 member 的排名和分数 ARGV[2] 为 1 时倒序, 不存在返回 nil
+var zfindScript = NewScript(1, `local rank
+	if ARGV[2] == "1"
+	then
+		rank = redis.call("ZREVRANK", KEYS[1], ARGV[1])
+	else
+		rank = redis.call("ZRANK", KEYS[1], ARGV[1])
+	end
+	if (rank == nil or (type(rank) == 'boolean' and rank == false))
+	then
+		return nil
+	end
+	return {rank, redis.call("ZSCORE", KEYS[1], ARGV[1])}`)
+
 // ZFind 查找 member
 func ZFind(conn redigo.Conn, key, v interface{}, isReverse bool) (ok bool, rank int, score float64, err error) {
 	member, err := jsonplus.Dump(v)
 	if err != nil {
 		return
 	}
-	var commandName1, commandName2 string
-	if !isReverse {
-		commandName1 = "ZRANK"
-		commandName2 = "ZRANGE"
-	} else {
-		commandName1 = "ZREVRANK"
-		commandName2 = "ZREVRANGE"
+	reverse := 0
+	if isReverse {
+		reverse = 1
 	}
-	scriptContext := fmt.Sprintf(`local v = redis.call("%s", KEYS[1], ARGV[1])
-	if (v == nil or (type(v) == 'boolean' and v == false))
-	then
-		return nil
-	else
-		local vv = redis.call("%s", KEYS[1], v, v, "WITHSCORES")
-		return {v, vv[2]}
-	end`, commandName1, commandName2)
-	script := redigo.NewScript(1, scriptContext)
-	r, err := redigo.Values(script.Do(conn, key, member))
+	r, err := redigo.Values(zfindScript.Do(conn, key, member, reverse).Reply())
 	if err != nil && err != redigo.ErrNil {
 		return
 	}
@@ -39,8 +37,11 @@ func ZFind(conn redigo.Conn, key, v interface{}, isReverse bool) (ok bool, rank
 		return false, 0, 0, nil
 	}
 
-	rank = int(r[0].(int64))
-	score, err = strconv.ParseFloat(string(r[1].([]byte)), 64)
+	rank, err = redigo.Int(r[0], nil)
+	if err != nil {
+		return
+	}
+	score, err = redigo.Float64(r[1], nil)
 	if err != nil {
 		return
 	}
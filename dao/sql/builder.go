@@ -0,0 +1,264 @@
+package sql
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Builder 语句构造器
+type Builder interface {
+	Build() (string, []interface{}, error)
+}
+
+// 是否为需要展开的切片参数
+func isExpandArg(arg interface{}) (reflect.Value, bool) {
+	if arg == nil {
+		return reflect.Value{}, false
+	}
+	if _, ok := arg.(driver.Valuer); ok {
+		return reflect.Value{}, false
+	}
+	v := reflect.ValueOf(arg)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return reflect.Value{}, false
+	}
+	if v.Type().Elem().Kind() == reflect.Uint8 { // []byte 作为单个参数
+		return reflect.Value{}, false
+	}
+	return v, true
+}
+
+// ExpandIn 展开切片参数 "id IN ?", []int{1, 2, 3} => "id IN (?, ?, ?)", 1, 2, 3
+// 引号内的 ? 不作为占位符, 占位符与参数数量必须一致, 切片不能为空
+func ExpandIn(query string, args ...interface{}) (string, []interface{}, error) {
+	var builder strings.Builder
+	var quote rune
+	newArgs := []interface{}{}
+	n := 0
+	for _, c := range query {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '?':
+			if n >= len(args) {
+				return "", nil, fmt.Errorf("placeholders more than args: %v", query)
+			}
+			arg := args[n]
+			n++
+			v, ok := isExpandArg(arg)
+			if !ok {
+				newArgs = append(newArgs, arg)
+				break
+			}
+			if v.Len() == 0 {
+				return "", nil, fmt.Errorf("empty slice in args: %v", query)
+			}
+			for i := 0; i < v.Len(); i++ {
+				newArgs = append(newArgs, v.Index(i).Interface())
+			}
+			builder.WriteString(fmt.Sprintf("(%s)", marks(v.Len())))
+			continue
+		}
+		builder.WriteRune(c)
+	}
+	if n != len(args) {
+		return "", nil, fmt.Errorf("args more than placeholders: %v", query)
+	}
+	return builder.String(), newArgs, nil
+}
+
+type condition struct {
+	conj  string // AND / OR
+	query string
+	args  []interface{}
+}
+
+// SelectBuilder select 语句构造器 参数中的切片会展开为 (?, ?, ?)
+type SelectBuilder struct {
+	columns []string
+	table   string
+	joins   []*condition
+	wheres  []*condition
+	groupBy []string
+	having  *condition
+	orderBy []string
+	limit   int
+	offset  int
+
+	model       interface{} // 结构体 用于列名和软删除条件
+	withDeleted bool
+}
+
+// NewSelect 新建 select 构造器
+func NewSelect(columns ...string) *SelectBuilder {
+	return &SelectBuilder{
+		columns: columns,
+		limit:   -1,
+		offset:  -1,
+	}
+}
+
+// Columns 追加列
+func (b *SelectBuilder) Columns(columns ...string) *SelectBuilder {
+	b.columns = append(b.columns, columns...)
+	return b
+}
+
+// Model 以结构体的全部列为查询列(已指定列时不覆盖), 结构体有 deleted 字段时排除软删除的行
+func (b *SelectBuilder) Model(v interface{}) *SelectBuilder {
+	b.model = v
+	return b
+}
+
+// WithDeleted 包含软删除的行
+func (b *SelectBuilder) WithDeleted() *SelectBuilder {
+	b.withDeleted = true
+	return b
+}
+
+// From 表名
+func (b *SelectBuilder) From(table string) *SelectBuilder {
+	b.table = table
+	return b
+}
+
+// Join 如 "LEFT JOIN b ON a.id = b.a_id"
+func (b *SelectBuilder) Join(join string, args ...interface{}) *SelectBuilder {
+	b.joins = append(b.joins, &condition{query: join, args: args})
+	return b
+}
+
+// Where 条件 多个条件之间为 AND
+func (b *SelectBuilder) Where(query string, args ...interface{}) *SelectBuilder {
+	return b.And(query, args...)
+}
+
+// And AND 条件
+func (b *SelectBuilder) And(query string, args ...interface{}) *SelectBuilder {
+	b.wheres = append(b.wheres, &condition{conj: "AND", query: query, args: args})
+	return b
+}
+
+// Or OR 条件 与之前的条件按顺序拼接
+func (b *SelectBuilder) Or(query string, args ...interface{}) *SelectBuilder {
+	b.wheres = append(b.wheres, &condition{conj: "OR", query: query, args: args})
+	return b
+}
+
+// In AND column IN (?, ?, ?)
+func (b *SelectBuilder) In(column string, values interface{}) *SelectBuilder {
+	return b.And(fmt.Sprintf("%s IN ?", column), values)
+}
+
+// GroupBy ...
+func (b *SelectBuilder) GroupBy(columns ...string) *SelectBuilder {
+	b.groupBy = append(b.groupBy, columns...)
+	return b
+}
+
+// Having ...
+func (b *SelectBuilder) Having(query string, args ...interface{}) *SelectBuilder {
+	b.having = &condition{query: query, args: args}
+	return b
+}
+
+// OrderBy 如 "id DESC"
+func (b *SelectBuilder) OrderBy(columns ...string) *SelectBuilder {
+	b.orderBy = append(b.orderBy, columns...)
+	return b
+}
+
+// Limit ...
+func (b *SelectBuilder) Limit(limit int) *SelectBuilder {
+	b.limit = limit
+	return b
+}
+
+// Offset ...
+func (b *SelectBuilder) Offset(offset int) *SelectBuilder {
+	b.offset = offset
+	return b
+}
+
+// Build 生成 sql 和参数
+func (b *SelectBuilder) Build() (string, []interface{}, error) {
+	columns, softDelete := b.columns, ""
+	if b.model != nil {
+		typ := reflect.TypeOf(b.model)
+		for typ.Kind() == reflect.Ptr {
+			typ = typ.Elem()
+		}
+		if typ.Kind() != reflect.Struct {
+			return "", nil, fmt.Errorf("model must be struct or pointer of struct")
+		}
+		if len(columns) == 0 {
+			for _, f := range getStructMap(typ).fields {
+				columns = append(columns, f.name)
+			}
+		}
+		if !b.withDeleted {
+			var err error
+			if softDelete, err = SoftDeleteWhere(b.model); err != nil {
+				return "", nil, err
+			}
+		}
+	}
+
+	if len(columns) == 0 {
+		return "", nil, fmt.Errorf("select must have column name")
+	}
+	if b.table == "" {
+		return "", nil, fmt.Errorf("select must have table name")
+	}
+
+	splits := []string{fmt.Sprintf("SELECT %s FROM %s", strings.Join(columns, ", "), b.table)}
+	args := []interface{}{}
+
+	for _, join := range b.joins {
+		splits = append(splits, join.query)
+		args = append(args, join.args...)
+	}
+
+	wheres := []string{}
+	for i, where := range b.wheres {
+		if i == 0 {
+			wheres = append(wheres, fmt.Sprintf("(%s)", where.query))
+		} else {
+			wheres = append(wheres, fmt.Sprintf("%s (%s)", where.conj, where.query))
+		}
+		args = append(args, where.args...)
+	}
+	switch {
+	case softDelete != "" && len(wheres) > 1: // 整体加括号 避免 OR 的优先级问题
+		splits = append(splits, fmt.Sprintf("WHERE (%s) AND (%s)", strings.Join(wheres, " "), softDelete))
+	case softDelete != "":
+		splits = append(splits, "WHERE "+strings.Join(append(wheres, fmt.Sprintf("(%s)", softDelete)), " AND "))
+	case len(wheres) > 0:
+		splits = append(splits, "WHERE "+strings.Join(wheres, " "))
+	}
+
+	if len(b.groupBy) > 0 {
+		splits = append(splits, "GROUP BY "+strings.Join(b.groupBy, ", "))
+	}
+	if b.having != nil {
+		splits = append(splits, "HAVING "+b.having.query)
+		args = append(args, b.having.args...)
+	}
+	if len(b.orderBy) > 0 {
+		splits = append(splits, "ORDER BY "+strings.Join(b.orderBy, ", "))
+	}
+	if b.limit >= 0 {
+		splits = append(splits, fmt.Sprintf("LIMIT %d", b.limit))
+	}
+	if b.offset >= 0 {
+		splits = append(splits, fmt.Sprintf("OFFSET %d", b.offset))
+	}
+
+	return ExpandIn(strings.Join(splits, " ")+";", args...)
+}
@@ -0,0 +1,84 @@
+package sql
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExpandIn(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     string
+		args      []interface{}
+		wantQuery string
+		wantArgs  []interface{}
+		wantErr   bool
+	}{
+		{
+			name:      "slice",
+			query:     "SELECT a FROM t WHERE b IN ? AND c = ?",
+			args:      []interface{}{[]int{1, 2, 3}, "x"},
+			wantQuery: "SELECT a FROM t WHERE b IN (?, ?, ?) AND c = ?",
+			wantArgs:  []interface{}{1, 2, 3, "x"},
+		},
+		{
+			name:      "bytes",
+			query:     "SELECT a FROM t WHERE b = ? AND c = '?'",
+			args:      []interface{}{[]byte("x")},
+			wantQuery: "SELECT a FROM t WHERE b = ? AND c = '?'",
+			wantArgs:  []interface{}{[]byte("x")},
+		},
+		{
+			name:    "empty",
+			query:   "SELECT a FROM t WHERE b IN ?",
+			args:    []interface{}{[]int{}},
+			wantErr: true,
+		},
+		{
+			name:    "mismatch",
+			query:   "SELECT a FROM t WHERE b = ?",
+			args:    []interface{}{1, 2},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, args, err := ExpandIn(tt.query, tt.args...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ExpandIn() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if query != tt.wantQuery || !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("ExpandIn() = %v %v, want %v %v", query, args, tt.wantQuery, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestSelectBuilder(t *testing.T) {
+	query, args, err := NewSelect("a.id", "COUNT(b.id)").
+		From("a").
+		Join("LEFT JOIN b ON a.id = b.a_id AND b.type = ?", 1).
+		Where("a.level > ?", 10).
+		In("a.id", []int{1, 2}).
+		Or("a.vip = ?", true).
+		GroupBy("a.id").
+		Having("COUNT(b.id) > ?", 2).
+		OrderBy("a.id DESC").
+		Limit(10).
+		Offset(20).
+		Build()
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	wantQuery := "SELECT a.id, COUNT(b.id) FROM a LEFT JOIN b ON a.id = b.a_id AND b.type = ? " +
+		"WHERE (a.level > ?) AND (a.id IN (?, ?)) OR (a.vip = ?) " +
+		"GROUP BY a.id HAVING COUNT(b.id) > ? ORDER BY a.id DESC LIMIT 10 OFFSET 20;"
+	wantArgs := []interface{}{1, 10, 1, 2, true, 2}
+	if query != wantQuery || !reflect.DeepEqual(args, wantArgs) {
+		t.Errorf("Build() = %v %v, want %v %v", query, args, wantQuery, wantArgs)
+	}
+
+	if _, _, err := NewSelect().From("a").Build(); err == nil {
+		t.Errorf("Build() without columns want error")
+	}
+}
@@ -0,0 +1,132 @@
+package sql
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// ErrStop Each 的回调返回 ErrStop 时提前结束遍历, Each 返回 nil
+var ErrStop = errors.New("stop iteration")
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// Cursor 游标 逐行读取 rows 不缓存结果, 读完或出错时自动关闭 rows
+type Cursor struct {
+	rows    *sql.Rows
+	scanner *rowScanner
+	err     error
+	closed  bool
+}
+
+// NewCursor ...
+func NewCursor(rows *sql.Rows) *Cursor {
+	cursor := &Cursor{rows: rows}
+	columns, err := rows.Columns()
+	if err != nil {
+		cursor.err = err
+		cursor.Close()
+		return cursor
+	}
+	cursor.scanner = &rowScanner{columns: columns}
+	return cursor
+}
+
+// Next 移动到下一行 没有下一行或出错时返回 false 并关闭 rows
+func (cursor *Cursor) Next() bool {
+	if cursor.closed {
+		return false
+	}
+	if cursor.rows.Next() {
+		return true
+	}
+	cursor.err = cursor.rows.Err()
+	cursor.Close()
+	return false
+}
+
+// Scan 解码当前行 v 结构体/map/单列基础类型 的指针, 或非 nil 的 map
+func (cursor *Cursor) Scan(v interface{}) error {
+	if cursor.closed {
+		return fmt.Errorf("cursor is closed")
+	}
+	dest := reflect.ValueOf(v)
+	if dest.Kind() != reflect.Ptr && dest.Kind() != reflect.Map || dest.IsNil() {
+		return fmt.Errorf("v must be a non-nil pointer or map")
+	}
+	if err := cursor.scanner.scan(cursor.rows, dest); err != nil {
+		cursor.err = err
+		cursor.Close()
+		return err
+	}
+	return nil
+}
+
+// Err 遍历过程中的错误
+func (cursor *Cursor) Err() error {
+	return cursor.err
+}
+
+// Close 关闭 rows 可重复调用, 提前结束遍历时须调用
+func (cursor *Cursor) Close() error {
+	if cursor.closed {
+		return nil
+	}
+	cursor.closed = true
+	return cursor.rows.Close()
+}
+
+// eachFunc 检查回调 fn 须为 func(T) error, T 为结构体/map/单列基础类型 或其指针
+func eachFunc(fn interface{}) (reflect.Value, reflect.Type, error) {
+	f := reflect.ValueOf(fn)
+	if f.Kind() != reflect.Func || f.IsNil() {
+		return f, nil, fmt.Errorf("fn must be a func(T) error")
+	}
+	typ := f.Type()
+	if typ.NumIn() != 1 || typ.NumOut() != 1 || typ.Out(0) != errorType {
+		return f, nil, fmt.Errorf("fn must be a func(T) error, got %v", typ)
+	}
+	return f, typ.In(0), nil
+}
+
+// Each 逐行解码并调用 fn, 结束后关闭 rows, 返回遍历的行数
+// fn 为 func(T) error, T 为结构体/map/单列基础类型 或其指针, 每行都是新的 T;
+// fn 返回 ErrStop 提前结束, 返回其他错误则中断并返回该错误
+func Each(rows *sql.Rows, fn interface{}) (int, error) {
+	f, itemType, err := eachFunc(fn)
+	if err != nil {
+		rows.Close()
+		return 0, err
+	}
+	isPtr := itemType.Kind() == reflect.Ptr
+	if isPtr {
+		itemType = itemType.Elem()
+	}
+
+	cursor := NewCursor(rows)
+	defer cursor.Close()
+
+	count := 0
+	for cursor.Next() {
+		item := reflect.New(itemType)
+		if itemType.Kind() == reflect.Map {
+			item.Elem().Set(reflect.MakeMap(itemType))
+		}
+		if err := cursor.Scan(item.Interface()); err != nil {
+			return count, err
+		}
+		count++
+
+		if !isPtr {
+			item = item.Elem()
+		}
+		if out := f.Call([]reflect.Value{item})[0]; !out.IsNil() {
+			if err := out.Interface().(error); err != ErrStop {
+				return count, err
+			}
+			return count, nil
+		}
+	}
+	return count, cursor.Err()
+}
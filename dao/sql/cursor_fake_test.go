@@ -0,0 +1,157 @@
+package sql_test
+
+import (
+	"database/sql"
+	"errors"
+	"reflect"
+	"testing"
+
+	sqlplus "github.com/cheetah-fun-gs/goplus/dao/sql"
+	"github.com/cheetah-fun-gs/goplus/dao/sql/sqlfake"
+)
+
+type cursorUser struct {
+	ID   int    `db:"id"`
+	Name string `db:"name"`
+}
+
+const cursorQuery = "SELECT id, name FROM user"
+
+// queryRows 执行 cursorQuery 返回预设的行
+func queryRows(t *testing.T, db *sql.DB, fake *sqlfake.Fake, rows *sqlfake.Rows) *sql.Rows {
+	fake.ExpectQuery(cursorQuery).WillReturnRows(rows)
+	result, err := db.Query(cursorQuery)
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	return result
+}
+
+// checkReleased rows 关闭后连接才会归还
+func checkReleased(t *testing.T, db *sql.DB) {
+	if inUse := db.Stats().InUse; inUse != 0 {
+		t.Errorf("rows not closed, connections in use = %v", inUse)
+	}
+}
+
+func TestEach(t *testing.T) {
+	errHandler := errors.New("handler failed")
+	errRows := errors.New("connection reset")
+	users := func() *sqlfake.Rows {
+		return sqlfake.NewRows("id", "name").AddRow(1, "a").AddRow(2, "b").AddRow(3, "c")
+	}
+
+	tests := []struct {
+		name      string
+		rows      *sqlfake.Rows
+		stopAt    int   // 第几行返回 handleErr, 0 不返回
+		handleErr error // stopAt 行返回的错误
+		fn        interface{}
+		wantCount int
+		wantNames []string
+		wantErr   error
+		anyErr    bool
+	}{
+		{name: "all rows", rows: users(), wantCount: 3, wantNames: []string{"a", "b", "c"}},
+		{name: "empty", rows: sqlfake.NewRows("id", "name"), wantCount: 0},
+		{name: "stop", rows: users(), stopAt: 2, handleErr: sqlplus.ErrStop, wantCount: 2, wantNames: []string{"a", "b"}},
+		{name: "handler error", rows: users(), stopAt: 1, handleErr: errHandler, wantCount: 1, wantNames: []string{"a"}, wantErr: errHandler},
+		{name: "scan error", rows: sqlfake.NewRows("id", "name").AddRow(1, "a").AddRow("x", "b"), wantCount: 1, wantNames: []string{"a"}, anyErr: true},
+		{name: "rows error", rows: users().RowError(errRows), wantCount: 3, wantNames: []string{"a", "b", "c"}, wantErr: errRows},
+		{name: "bad fn", rows: users(), fn: func(cursorUser) {}, anyErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := sqlfake.New()
+			defer db.Close()
+			rows := queryRows(t, db, fake, tt.rows)
+
+			var names []string
+			fn := tt.fn
+			if fn == nil {
+				fn = func(user *cursorUser) error {
+					names = append(names, user.Name)
+					if len(names) == tt.stopAt {
+						return tt.handleErr
+					}
+					return nil
+				}
+			}
+			count, err := sqlplus.Each(rows, fn)
+			switch {
+			case tt.anyErr:
+				if err == nil {
+					t.Errorf("Each() want error")
+				}
+			case err != tt.wantErr:
+				t.Errorf("Each() error = %v, want %v", err, tt.wantErr)
+			}
+			if count != tt.wantCount {
+				t.Errorf("Each() count = %v, want %v", count, tt.wantCount)
+			}
+			if tt.fn == nil && !reflect.DeepEqual(names, tt.wantNames) {
+				t.Errorf("Each() names = %v, want %v", names, tt.wantNames)
+			}
+			checkReleased(t, db)
+		})
+	}
+}
+
+func TestCursor(t *testing.T) {
+	errRows := errors.New("connection reset")
+	tests := []struct {
+		name    string
+		rows    *sqlfake.Rows
+		limit   int // 读取的行数 读到后调用 Close
+		wantIDs []int
+		wantErr error
+		anyErr  bool
+	}{
+		{name: "read all", rows: sqlfake.NewRows("id", "name").AddRow(1, "a").AddRow(2, "b"), wantIDs: []int{1, 2}},
+		{name: "close early", rows: sqlfake.NewRows("id", "name").AddRow(1, "a").AddRow(2, "b"), limit: 1, wantIDs: []int{1}},
+		{name: "rows error", rows: sqlfake.NewRows("id", "name").AddRow(1, "a").RowError(errRows), wantIDs: []int{1}, wantErr: errRows},
+		{name: "scan error", rows: sqlfake.NewRows("id", "name").AddRow("x", "a").AddRow(2, "b"), anyErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := sqlfake.New()
+			defer db.Close()
+			cursor := sqlplus.NewCursor(queryRows(t, db, fake, tt.rows))
+
+			var ids []int
+			for cursor.Next() {
+				user := &cursorUser{}
+				if err := cursor.Scan(user); err != nil {
+					break
+				}
+				ids = append(ids, user.ID)
+				if len(ids) == tt.limit {
+					if err := cursor.Close(); err != nil {
+						t.Fatalf("Close() error = %v", err)
+					}
+				}
+			}
+
+			if tt.anyErr {
+				if cursor.Err() == nil {
+					t.Errorf("Err() want error")
+				}
+			} else if cursor.Err() != tt.wantErr {
+				t.Errorf("Err() = %v, want %v", cursor.Err(), tt.wantErr)
+			}
+			if !reflect.DeepEqual(ids, tt.wantIDs) {
+				t.Errorf("cursor ids = %v, want %v", ids, tt.wantIDs)
+			}
+			if cursor.Next() {
+				t.Errorf("Next() after end want false")
+			}
+			if err := cursor.Scan(&cursorUser{}); err == nil {
+				t.Errorf("Scan() after close want error")
+			}
+			if err := cursor.Close(); err != nil {
+				t.Errorf("Close() again error = %v", err)
+			}
+			checkReleased(t, db)
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package sql
+
+import (
+	"testing"
+)
+
+func TestEachFunc(t *testing.T) {
+	tests := []struct {
+		name    string
+		fn      interface{}
+		want    string
+		wantErr bool
+	}{
+		{name: "struct", fn: func(testRow) error { return nil }, want: "sql.testRow"},
+		{name: "ptr", fn: func(*testRow) error { return nil }, want: "*sql.testRow"},
+		{name: "map", fn: func(map[string]interface{}) error { return nil }, want: "map[string]interface {}"},
+		{name: "not func", fn: 1, wantErr: true},
+		{name: "nil func", fn: (func(int) error)(nil), wantErr: true},
+		{name: "no error", fn: func(int) {}, wantErr: true},
+		{name: "two args", fn: func(int, int) error { return nil }, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, typ, err := eachFunc(tt.fn)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("eachFunc() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && typ.String() != tt.want {
+				t.Errorf("eachFunc() = %v, want %v", typ, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,420 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// 建表 tag 选项 db:"name,opt1,opt2=val":
+// type=X 指定列类型; size=N 字符串/二进制长度 默认 255; null 可为空, 指针和 sql.NullXxx 默认可为空;
+// default=X 默认值 原样输出, 字符串须带单引号且不能含逗号; pk 主键; auto 自增;
+// index 或 index=name 索引, unique 或 unique=name 唯一索引, 同名的列组成联合索引 按字段顺序
+
+var (
+	nullTypes = map[reflect.Type]string{
+		reflect.TypeOf(sql.NullString{}):  "VARCHAR",
+		reflect.TypeOf(sql.NullInt64{}):   "BIGINT",
+		reflect.TypeOf(sql.NullInt32{}):   "INT",
+		reflect.TypeOf(sql.NullFloat64{}): "DOUBLE",
+		reflect.TypeOf(sql.NullBool{}):    "TINYINT(1)",
+		reflect.TypeOf(sql.NullTime{}):    "DATETIME",
+	}
+	intWidthRegexp = regexp.MustCompile(`^(tinyint|smallint|mediumint|int|bigint)\(\d+\)`)
+)
+
+// columnDef 列定义
+type columnDef struct {
+	name  string
+	typ   string
+	null  bool
+	def   string // 默认值 空为无
+	auto  bool
+	after string // 前一列 用于 ADD COLUMN
+}
+
+func (c *columnDef) String() string {
+	splits := []string{c.name, c.typ}
+	if c.null {
+		splits = append(splits, "NULL")
+	} else {
+		splits = append(splits, "NOT NULL")
+	}
+	if c.def != "" {
+		splits = append(splits, "DEFAULT "+c.def)
+	}
+	if c.auto {
+		splits = append(splits, "AUTO_INCREMENT")
+	}
+	return strings.Join(splits, " ")
+}
+
+// indexDef 索引定义
+type indexDef struct {
+	name    string
+	unique  bool
+	columns []string
+}
+
+func (index *indexDef) String() string {
+	if index.unique {
+		return fmt.Sprintf("UNIQUE KEY %s (%s)", index.name, strings.Join(index.columns, ", "))
+	}
+	return fmt.Sprintf("KEY %s (%s)", index.name, strings.Join(index.columns, ", "))
+}
+
+// tableDef 表定义
+type tableDef struct {
+	columns []*columnDef
+	pks     []string
+	indexes []*indexDef
+}
+
+// columnType 字段的列类型和是否可为空
+func columnType(f *structField) (string, bool, error) {
+	typ, null := f.typ, false
+	if typ.Kind() == reflect.Ptr {
+		typ, null = typ.Elem(), true
+	}
+	if _, ok := f.options["null"]; ok {
+		null = true
+	}
+	size := "255"
+	if s, ok := f.options["size"]; ok {
+		size = s
+	}
+	if t, ok := f.options["type"]; ok {
+		return t, null, nil
+	}
+
+	if t, ok := nullTypes[typ]; ok {
+		if t == "VARCHAR" {
+			t = fmt.Sprintf("VARCHAR(%s)", size)
+		}
+		return t, true, nil
+	}
+	if typ == timeType {
+		return "DATETIME", null, nil
+	}
+
+	switch typ.Kind() {
+	case reflect.Bool:
+		return "TINYINT(1)", null, nil
+	case reflect.Int8:
+		return "TINYINT", null, nil
+	case reflect.Int16:
+		return "SMALLINT", null, nil
+	case reflect.Int32:
+		return "INT", null, nil
+	case reflect.Int, reflect.Int64:
+		return "BIGINT", null, nil
+	case reflect.Uint8:
+		return "TINYINT UNSIGNED", null, nil
+	case reflect.Uint16:
+		return "SMALLINT UNSIGNED", null, nil
+	case reflect.Uint32:
+		return "INT UNSIGNED", null, nil
+	case reflect.Uint, reflect.Uint64:
+		return "BIGINT UNSIGNED", null, nil
+	case reflect.Float32:
+		return "FLOAT", null, nil
+	case reflect.Float64:
+		return "DOUBLE", null, nil
+	case reflect.String:
+		return fmt.Sprintf("VARCHAR(%s)", size), null, nil
+	case reflect.Slice:
+		if typ.Elem().Kind() == reflect.Uint8 {
+			if _, ok := f.options["size"]; ok {
+				return fmt.Sprintf("VARBINARY(%s)", size), null, nil
+			}
+			return "BLOB", null, nil
+		}
+	}
+	return "", false, fmt.Errorf("column %v: unsupported type %v, use type=X", f.name, f.typ)
+}
+
+// structTableDef 由结构体生成表定义
+func structTableDef(v interface{}) (*tableDef, error) {
+	typ := reflect.TypeOf(v)
+	for typ != nil && typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ == nil || typ.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("v must be struct or pointer of struct")
+	}
+	m := getStructMap(typ)
+
+	def := &tableDef{}
+	indexes := map[string]*indexDef{}
+	addIndex := func(name string, unique bool, column string) {
+		index, ok := indexes[name]
+		if !ok {
+			index = &indexDef{name: name, unique: unique}
+			indexes[name] = index
+			def.indexes = append(def.indexes, index)
+		}
+		index.columns = append(index.columns, column)
+	}
+
+	for i, f := range m.fields {
+		typ, null, err := columnType(f)
+		if err != nil {
+			return nil, err
+		}
+		column := &columnDef{name: f.name, typ: typ, null: null, def: f.options["default"]}
+		if _, ok := f.options["auto"]; ok {
+			column.auto = true
+		}
+		if i > 0 {
+			column.after = m.fields[i-1].name
+		}
+		def.columns = append(def.columns, column)
+
+		if name, ok := f.options["index"]; ok {
+			if name == "" {
+				name = "idx_" + f.name
+			}
+			addIndex(name, false, f.name)
+		}
+		if name, ok := f.options["unique"]; ok {
+			if name == "" {
+				name = "uk_" + f.name
+			}
+			addIndex(name, true, f.name)
+		}
+	}
+	for _, pk := range m.pks {
+		def.pks = append(def.pks, pk.name)
+	}
+	for i, f := range m.fields {
+		if isPrimary(m, f) {
+			def.columns[i].null = false
+		}
+	}
+	return def, nil
+}
+
+// GenCreateTable 由结构体生成 mysql 建表语句 列名规则同 GenInsert, 类型见建表 tag 选项
+func GenCreateTable(tableName string, v interface{}) (string, error) {
+	def, err := structTableDef(v)
+	if err != nil {
+		return "", err
+	}
+
+	lines := []string{}
+	for _, column := range def.columns {
+		lines = append(lines, column.String())
+	}
+	if len(def.pks) > 0 {
+		lines = append(lines, fmt.Sprintf("PRIMARY KEY (%s)", strings.Join(def.pks, ", ")))
+	}
+	for _, index := range def.indexes {
+		lines = append(lines, index.String())
+	}
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (\n  %s\n) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;",
+		tableName, strings.Join(lines, ",\n  ")), nil
+}
+
+// ColumnSchema information_schema.COLUMNS 中的列
+type ColumnSchema struct {
+	Name     string
+	Type     string // COLUMN_TYPE 如 bigint(20) unsigned
+	Nullable bool
+	Default  sql.NullString
+	Extra    string // 如 auto_increment
+}
+
+// IndexSchema information_schema.STATISTICS 中的索引
+type IndexSchema struct {
+	Name    string // 主键为 PRIMARY
+	Unique  bool
+	Columns []string
+}
+
+// TableSchema 线上的表结构
+type TableSchema struct {
+	Columns []*ColumnSchema
+	Indexes []*IndexSchema
+}
+
+// LoadTableSchema 从 information_schema 读取当前库的表结构 表不存在时 Columns 为空
+func LoadTableSchema(ctx context.Context, db *sql.DB, tableName string) (*TableSchema, error) {
+	schema := &TableSchema{}
+
+	rows, err := db.QueryContext(ctx, "SELECT COLUMN_NAME, COLUMN_TYPE, IS_NULLABLE, COLUMN_DEFAULT, EXTRA "+
+		"FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? ORDER BY ORDINAL_POSITION;", tableName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		column := &ColumnSchema{}
+		var nullable string
+		if err := rows.Scan(&column.Name, &column.Type, &nullable, &column.Default, &column.Extra); err != nil {
+			return nil, err
+		}
+		column.Nullable = nullable == "YES"
+		schema.Columns = append(schema.Columns, column)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = db.QueryContext(ctx, "SELECT INDEX_NAME, NON_UNIQUE, COLUMN_NAME "+
+		"FROM information_schema.STATISTICS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? ORDER BY INDEX_NAME, SEQ_IN_INDEX;", tableName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	indexes := map[string]*IndexSchema{}
+	for rows.Next() {
+		var name, column string
+		var nonUnique int
+		if err := rows.Scan(&name, &nonUnique, &column); err != nil {
+			return nil, err
+		}
+		index, ok := indexes[name]
+		if !ok {
+			index = &IndexSchema{Name: name, Unique: nonUnique == 0}
+			indexes[name] = index
+			schema.Indexes = append(schema.Indexes, index)
+		}
+		index.Columns = append(index.Columns, column)
+	}
+	return schema, rows.Err()
+}
+
+// normalizeType 统一大小写 去掉整数的显示宽度(tinyint(1) 除外)
+func normalizeType(typ string) string {
+	typ = strings.ToLower(strings.TrimSpace(typ))
+	if strings.HasPrefix(typ, "tinyint(1)") {
+		return typ
+	}
+	return intWidthRegexp.ReplaceAllString(typ, "$1")
+}
+
+// normalizeDefault 去掉引号和函数括号
+func normalizeDefault(def string) string {
+	def = strings.TrimSpace(def)
+	if len(def) >= 2 && def[0] == '\'' && def[len(def)-1] == '\'' {
+		return def[1 : len(def)-1]
+	}
+	if strings.EqualFold(def, "NULL") {
+		return ""
+	}
+	return strings.TrimSuffix(def, "()")
+}
+
+func columnChanged(column *columnDef, schema *ColumnSchema) bool {
+	if normalizeType(column.typ) != normalizeType(schema.Type) || column.null != schema.Nullable {
+		return true
+	}
+	if column.auto != strings.Contains(strings.ToLower(schema.Extra), "auto_increment") {
+		return true
+	}
+	def := ""
+	if schema.Default.Valid {
+		def = normalizeDefault(schema.Default.String)
+	}
+	return !strings.EqualFold(normalizeDefault(column.def), def)
+}
+
+func sameColumns(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !strings.EqualFold(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// GenAlterTable 对比结构体与线上表结构 生成 mysql 变更语句, 表不存在时生成建表语句
+// drop 为 true 时删除结构体中没有的列和索引
+func GenAlterTable(tableName string, v interface{}, schema *TableSchema, drop bool) ([]string, error) {
+	if schema == nil || len(schema.Columns) == 0 {
+		query, err := GenCreateTable(tableName, v)
+		if err != nil {
+			return nil, err
+		}
+		return []string{query}, nil
+	}
+	def, err := structTableDef(v)
+	if err != nil {
+		return nil, err
+	}
+
+	queries := []string{}
+	alter := func(format string, a ...interface{}) {
+		queries = append(queries, fmt.Sprintf("ALTER TABLE %s %s;", tableName, fmt.Sprintf(format, a...)))
+	}
+
+	columns := map[string]*ColumnSchema{}
+	for _, column := range schema.Columns {
+		columns[strings.ToLower(column.Name)] = column
+	}
+	names := map[string]bool{}
+	for _, column := range def.columns {
+		names[strings.ToLower(column.name)] = true
+		exists, ok := columns[strings.ToLower(column.name)]
+		switch {
+		case !ok && column.after == "":
+			alter("ADD COLUMN %s FIRST", column)
+		case !ok:
+			alter("ADD COLUMN %s AFTER %s", column, column.after)
+		case columnChanged(column, exists):
+			alter("MODIFY COLUMN %s", column)
+		}
+	}
+	if drop {
+		for _, column := range schema.Columns {
+			if !names[strings.ToLower(column.Name)] {
+				alter("DROP COLUMN %s", column.Name)
+			}
+		}
+	}
+
+	indexes := map[string]*IndexSchema{}
+	for _, index := range schema.Indexes {
+		indexes[strings.ToLower(index.Name)] = index
+	}
+	if primary, ok := indexes["primary"]; !ok && len(def.pks) > 0 {
+		alter("ADD PRIMARY KEY (%s)", strings.Join(def.pks, ", "))
+	} else if ok && len(def.pks) > 0 && !sameColumns(primary.Columns, def.pks) {
+		alter("DROP PRIMARY KEY, ADD PRIMARY KEY (%s)", strings.Join(def.pks, ", "))
+	}
+
+	names = map[string]bool{"primary": true}
+	for _, index := range def.indexes {
+		names[strings.ToLower(index.name)] = true
+		exists, ok := indexes[strings.ToLower(index.name)]
+		switch {
+		case !ok:
+			alter("ADD %s", index)
+		case exists.Unique != index.unique || !sameColumns(exists.Columns, index.columns):
+			alter("DROP INDEX %s, ADD %s", index.name, index)
+		}
+	}
+	if drop {
+		for _, index := range schema.Indexes {
+			if !names[strings.ToLower(index.Name)] {
+				alter("DROP INDEX %s", index.Name)
+			}
+		}
+	}
+	return queries, nil
+}
+
+// DiffTable 读取线上表结构并生成变更语句 见 GenAlterTable
+func DiffTable(ctx context.Context, db *sql.DB, tableName string, v interface{}, drop bool) ([]string, error) {
+	schema, err := LoadTableSchema(ctx, db, tableName)
+	if err != nil {
+		return nil, err
+	}
+	return GenAlterTable(tableName, v, schema, drop)
+}
@@ -0,0 +1,140 @@
+package sql
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type testPlayer struct {
+	ID        int64          `db:"id,pk,auto"`
+	Name      string         `db:"name,size=32,unique"`
+	Zone      int32          `db:"zone,index=idx_zone_level"`
+	Level     uint16         `db:"level,index=idx_zone_level,default=1"`
+	Memo      sql.NullString `db:"memo"`
+	Bio       string         `db:"bio,type=TEXT"`
+	Avatar    *string        `db:"avatar,size=128"`
+	Online    bool           `db:"online"`
+	CreatedAt time.Time      `db:"created_at,default=CURRENT_TIMESTAMP"`
+}
+
+func TestGenCreateTable(t *testing.T) {
+	got, err := GenCreateTable("player", &testPlayer{})
+	if err != nil {
+		t.Fatalf("GenCreateTable() error = %v", err)
+	}
+	want := "CREATE TABLE IF NOT EXISTS player (\n" +
+		"  id BIGINT NOT NULL AUTO_INCREMENT,\n" +
+		"  name VARCHAR(32) NOT NULL,\n" +
+		"  zone INT NOT NULL,\n" +
+		"  level SMALLINT UNSIGNED NOT NULL DEFAULT 1,\n" +
+		"  memo VARCHAR(255) NULL,\n" +
+		"  bio TEXT NOT NULL,\n" +
+		"  avatar VARCHAR(128) NULL,\n" +
+		"  online TINYINT(1) NOT NULL,\n" +
+		"  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,\n" +
+		"  PRIMARY KEY (id),\n" +
+		"  UNIQUE KEY uk_name (name),\n" +
+		"  KEY idx_zone_level (zone, level)\n" +
+		") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;"
+	if got != want {
+		t.Errorf("GenCreateTable() = %v, want %v", got, want)
+	}
+
+	if _, err := GenCreateTable("t", struct {
+		M map[string]int `db:"m"`
+	}{}); err == nil {
+		t.Errorf("GenCreateTable() unsupported type want error")
+	}
+}
+
+func TestGenCreateTableTypeOptions(t *testing.T) {
+	type testGoods struct {
+		ID     int64   `db:"id,pk,auto"`
+		Price  float64 `db:"price,type=DECIMAL(10,2),default=0.00"`
+		Status string  `db:"status,type=ENUM('on','off'),default='on',index"`
+		Tags   string  `db:"tags,size=64,default='a,b'"`
+		Rate   float64 `db:"rate,type=NUMERIC( 5 , 4 ),null"`
+	}
+	got, err := GenCreateTable("goods", &testGoods{})
+	if err != nil {
+		t.Fatalf("GenCreateTable() error = %v", err)
+	}
+	want := "CREATE TABLE IF NOT EXISTS goods (\n" +
+		"  id BIGINT NOT NULL AUTO_INCREMENT,\n" +
+		"  price DECIMAL(10,2) NOT NULL DEFAULT 0.00,\n" +
+		"  status ENUM('on','off') NOT NULL DEFAULT 'on',\n" +
+		"  tags VARCHAR(64) NOT NULL DEFAULT 'a,b',\n" +
+		"  rate NUMERIC( 5 , 4 ) NULL,\n" +
+		"  PRIMARY KEY (id),\n" +
+		"  KEY idx_status (status)\n" +
+		") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;"
+	if got != want {
+		t.Errorf("GenCreateTable() = %v, want %v", got, want)
+	}
+
+	tests := []struct {
+		tag  string
+		want []string
+	}{
+		{"price,type=DECIMAL(10,2),null", []string{"price", "type=DECIMAL(10,2)", "null"}},
+		{"status,type=ENUM('a,b','c'),default='a,b'", []string{"status", "type=ENUM('a,b','c')", "default='a,b'"}},
+		{"note,default='(',size=8", []string{"note", "default='('", "size=8"}},
+		{"name", []string{"name"}},
+		{",pk", []string{"", "pk"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.tag, func(t *testing.T) {
+			if got := splitTag(tt.tag); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitTag() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenAlterTable(t *testing.T) {
+	schema := &TableSchema{
+		Columns: []*ColumnSchema{
+			{Name: "id", Type: "bigint(20)", Extra: "auto_increment"},
+			{Name: "name", Type: "varchar(16)"},
+			{Name: "zone", Type: "int(11)"},
+			{Name: "level", Type: "smallint(5) unsigned", Default: sql.NullString{String: "1", Valid: true}},
+			{Name: "memo", Type: "varchar(255)", Nullable: true},
+			{Name: "bio", Type: "text"},
+			{Name: "online", Type: "tinyint(1)"},
+			{Name: "created_at", Type: "datetime", Default: sql.NullString{String: "current_timestamp()", Valid: true}},
+			{Name: "legacy", Type: "int(11)"},
+		},
+		Indexes: []*IndexSchema{
+			{Name: "PRIMARY", Unique: true, Columns: []string{"id"}},
+			{Name: "uk_name", Unique: true, Columns: []string{"name"}},
+			{Name: "idx_zone_level", Columns: []string{"zone"}},
+			{Name: "idx_legacy", Columns: []string{"legacy"}},
+		},
+	}
+
+	got, err := GenAlterTable("player", testPlayer{}, schema, false)
+	if err != nil {
+		t.Fatalf("GenAlterTable() error = %v", err)
+	}
+	want := []string{
+		"ALTER TABLE player MODIFY COLUMN name VARCHAR(32) NOT NULL;",
+		"ALTER TABLE player ADD COLUMN avatar VARCHAR(128) NULL AFTER bio;",
+		"ALTER TABLE player DROP INDEX idx_zone_level, ADD KEY idx_zone_level (zone, level);",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GenAlterTable() = %q, want %q", got, want)
+	}
+
+	got, _ = GenAlterTable("player", testPlayer{}, schema, true)
+	want = append(want[:2:2], "ALTER TABLE player DROP COLUMN legacy;", want[2], "ALTER TABLE player DROP INDEX idx_legacy;")
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GenAlterTable() drop = %q, want %q", got, want)
+	}
+
+	got, _ = GenAlterTable("player", testPlayer{}, &TableSchema{}, false)
+	if len(got) != 1 || got[0][:12] != "CREATE TABLE" {
+		t.Errorf("GenAlterTable() empty schema = %q, want create table", got)
+	}
+}
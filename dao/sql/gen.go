@@ -0,0 +1,371 @@
+package sql
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Dialect sql 方言
+type Dialect int
+
+// 方言 定义
+const (
+	DialectMySQL Dialect = iota
+	DialectPostgres
+	DialectSQLite
+)
+
+// MaxPlaceholders 单条语句的最大占位符数量 批量插入据此分片
+var MaxPlaceholders = 65535
+
+// Rebind 将 ? 占位符转换为方言的占位符 引号内的 ? 不转换
+func Rebind(dialect Dialect, query string) string {
+	if dialect != DialectPostgres {
+		return query
+	}
+
+	var builder strings.Builder
+	var quote rune
+	n := 0
+	for _, c := range query {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '?':
+			n++
+			builder.WriteString(fmt.Sprintf("$%d", n))
+			continue
+		}
+		builder.WriteRune(c)
+	}
+	return builder.String()
+}
+
+func marks(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// map 按 key 排序
+func mapValues(fields map[string]interface{}) ([]string, []interface{}) {
+	columns := []string{}
+	for key := range fields {
+		columns = append(columns, key)
+	}
+	sort.Strings(columns)
+
+	args := []interface{}{}
+	for _, column := range columns {
+		args = append(args, fields[column])
+	}
+	return columns, args
+}
+
+// 结构体及其主键
+func primaryStruct(v interface{}) (reflect.Value, *structMap, error) {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Struct {
+		return rv, nil, fmt.Errorf("v must be struct or pointer of struct")
+	}
+	m := getStructMap(rv.Type())
+	if len(m.pks) == 0 {
+		return rv, nil, fmt.Errorf("primary key not found in %v", rv.Type())
+	}
+	return rv, m, nil
+}
+
+func isPrimary(m *structMap, f *structField) bool {
+	for _, pk := range m.pks {
+		if pk == f {
+			return true
+		}
+	}
+	return false
+}
+
+// 需要更新的字段 fields 为空则为全部非主键列 不含 version/created/deleted; updated 总是更新
+func updateFields(m *structMap, fields []string) ([]*structField, error) {
+	result := []*structField{}
+	if len(fields) == 0 {
+		for _, f := range m.fields {
+			if !isPrimary(m, f) && f != m.version && f != m.created && f != m.deleted {
+				result = append(result, f)
+			}
+		}
+		return result, nil
+	}
+	hasUpdated := false
+	for _, field := range fields {
+		f, ok := m.lookup(field)
+		if !ok {
+			return nil, fmt.Errorf("column not in fields: %v", field)
+		}
+		hasUpdated = hasUpdated || f == m.updated
+		result = append(result, f)
+	}
+	if m.updated != nil && !hasUpdated {
+		result = append(result, m.updated)
+	}
+	return result, nil
+}
+
+func primaryWhere(rv reflect.Value, m *structMap) (string, []interface{}) {
+	splits := []string{}
+	args := []interface{}{}
+	for _, pk := range m.pks {
+		splits = append(splits, fmt.Sprintf("%s = ?", pk.name))
+		args = append(args, structValue(rv, pk))
+	}
+	return strings.Join(splits, " AND "), args
+}
+
+// GenInsert 生成insert sql v map[string]interface{} or struct
+// map 的列按 key 排序, 结构体的列按字段定义顺序, 保证相同结构生成相同语句
+func GenInsert(tableName string, v interface{}) (string, []interface{}) {
+	var coloums []string
+	var args []interface{}
+	if fields, ok := v.(map[string]interface{}); ok {
+		coloums, args = mapValues(fields)
+	} else {
+		coloums, args = structValues(reflect.ValueOf(v))
+	}
+
+	return fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s);", tableName,
+		strings.Join(coloums, ", "), marks(len(coloums))), args
+}
+
+// GenInsertBatch 生成批量insert sql v map[string]interface{} 或 struct 的切片
+// 列以第一个元素为准, 按 MaxPlaceholders 分为多条语句
+func GenInsertBatch(tableName string, v interface{}) ([]*ExecSQL, error) {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, fmt.Errorf("v must be slice")
+	}
+	if rv.Len() == 0 {
+		return []*ExecSQL{}, nil
+	}
+
+	// 每行的值
+	t := now()
+	var columns []string
+	rows := [][]interface{}{}
+	for i := 0; i < rv.Len(); i++ {
+		item := rv.Index(i)
+		for item.Kind() == reflect.Ptr || item.Kind() == reflect.Interface {
+			item = item.Elem()
+		}
+
+		row := []interface{}{}
+		switch item.Kind() {
+		case reflect.Map:
+			fields, ok := item.Interface().(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("item must be map[string]interface{} or struct")
+			}
+			if columns == nil {
+				columns, _ = mapValues(fields)
+			}
+			for _, column := range columns {
+				row = append(row, fields[column])
+			}
+		case reflect.Struct:
+			m := getStructMap(item.Type())
+			if columns == nil {
+				for _, f := range m.fields {
+					columns = append(columns, f.name)
+				}
+			}
+			for _, column := range columns {
+				f, ok := m.lookup(column)
+				if !ok {
+					return nil, fmt.Errorf("column not in fields: %v", column)
+				}
+				row = append(row, insertValue(item, m, f, t))
+			}
+		default:
+			return nil, fmt.Errorf("item must be map[string]interface{} or struct")
+		}
+		rows = append(rows, row)
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("columns is empty")
+	}
+
+	size := MaxPlaceholders / len(columns)
+	if size < 1 {
+		return nil, fmt.Errorf("too many columns: %v", len(columns))
+	}
+
+	rowMarks := fmt.Sprintf("(%s)", marks(len(columns)))
+	result := []*ExecSQL{}
+	for start := 0; start < len(rows); start += size {
+		end := start + size
+		if end > len(rows) {
+			end = len(rows)
+		}
+		values := []string{}
+		args := []interface{}{}
+		for _, row := range rows[start:end] {
+			values = append(values, rowMarks)
+			args = append(args, row...)
+		}
+		result = append(result, &ExecSQL{
+			Query: fmt.Sprintf("INSERT INTO %s (%s) VALUES %s;", tableName,
+				strings.Join(columns, ", "), strings.Join(values, ", ")),
+			Args: args,
+		})
+	}
+	return result, nil
+}
+
+// GenUpdate 按主键生成update sql v struct, 主键为 tag 含 pk 的字段, 没有则为列 id
+// fields 需要更新的列, 为空则更新全部非主键列
+// 有 version 字段时为乐观锁更新: 条件加上 version = 当前值, 并将 version 加 1
+func GenUpdate(tableName string, v interface{}, fields ...string) (string, []interface{}, error) {
+	rv, m, err := primaryStruct(v)
+	if err != nil {
+		return "", nil, err
+	}
+	updates, err := updateFields(m, fields)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(updates) == 0 {
+		return "", nil, fmt.Errorf("no column to update")
+	}
+
+	sets := []string{}
+	args := []interface{}{}
+	for _, f := range updates {
+		if f == m.version {
+			continue
+		}
+		sets = append(sets, fmt.Sprintf("%s = ?", f.name))
+		if f == m.updated {
+			if val, ok := timestampValue(f.typ, now()); ok {
+				args = append(args, val)
+				continue
+			}
+		}
+		args = append(args, structValue(rv, f))
+	}
+	where, whereArgs := primaryWhere(rv, m)
+	if m.version != nil {
+		sets = append(sets, fmt.Sprintf("%s = %s + 1", m.version.name, m.version.name))
+		where = fmt.Sprintf("%s AND %s = ?", where, m.version.name)
+		whereArgs = append(whereArgs, structValue(rv, m.version))
+	}
+	return fmt.Sprintf("UPDATE %s SET %s WHERE %s;", tableName,
+		strings.Join(sets, ", "), where), append(args, whereArgs...), nil
+}
+
+// GenUpsert 生成upsert sql v struct, fields 冲突时需要更新的列, 为空则更新全部非主键列
+// mysql: ON DUPLICATE KEY UPDATE; postgres: ON CONFLICT (主键) DO UPDATE, 占位符为 $n
+func GenUpsert(dialect Dialect, tableName string, v interface{}, fields ...string) (string, []interface{}, error) {
+	_, m, err := primaryStruct(v)
+	if err != nil {
+		return "", nil, err
+	}
+	updates, err := updateFields(m, fields)
+	if err != nil {
+		return "", nil, err
+	}
+
+	query, args := GenInsert(tableName, v)
+	query = strings.TrimSuffix(query, ";")
+
+	sets := []string{}
+	switch dialect {
+	case DialectMySQL:
+		for _, f := range updates {
+			sets = append(sets, fmt.Sprintf("%s = VALUES(%s)", f.name, f.name))
+		}
+		if len(sets) == 0 { // 无需更新 保持原值
+			sets = append(sets, fmt.Sprintf("%s = %s", m.pks[0].name, m.pks[0].name))
+		}
+		query = fmt.Sprintf("%s ON DUPLICATE KEY UPDATE %s;", query, strings.Join(sets, ", "))
+	case DialectPostgres:
+		conflicts := []string{}
+		for _, pk := range m.pks {
+			conflicts = append(conflicts, pk.name)
+		}
+		for _, f := range updates {
+			sets = append(sets, fmt.Sprintf("%s = EXCLUDED.%s", f.name, f.name))
+		}
+		if len(sets) == 0 {
+			query = fmt.Sprintf("%s ON CONFLICT (%s) DO NOTHING;", query, strings.Join(conflicts, ", "))
+		} else {
+			query = fmt.Sprintf("%s ON CONFLICT (%s) DO UPDATE SET %s;", query,
+				strings.Join(conflicts, ", "), strings.Join(sets, ", "))
+		}
+	default:
+		return "", nil, fmt.Errorf("dialect not support: %v", dialect)
+	}
+	return Rebind(dialect, query), args, nil
+}
+
+// GenGet 按主键生成select sql v struct, 列为结构体的全部列, 不含已软删除的行
+func GenGet(tableName string, v interface{}) (string, []interface{}, error) {
+	return genGet(tableName, v, false)
+}
+
+// GenGetWithDeleted 同 GenGet 含已软删除的行
+func GenGetWithDeleted(tableName string, v interface{}) (string, []interface{}, error) {
+	return genGet(tableName, v, true)
+}
+
+func genGet(tableName string, v interface{}, withDeleted bool) (string, []interface{}, error) {
+	rv, m, err := primaryStruct(v)
+	if err != nil {
+		return "", nil, err
+	}
+	columns := []string{}
+	for _, f := range m.fields {
+		columns = append(columns, f.name)
+	}
+	where, args := primaryWhere(rv, m)
+	if m.deleted != nil && !withDeleted {
+		cond, err := notDeleted(m.deleted)
+		if err != nil {
+			return "", nil, err
+		}
+		where = fmt.Sprintf("%s AND %s", where, cond)
+	}
+	return fmt.Sprintf("SELECT %s FROM %s WHERE %s LIMIT 1;", strings.Join(columns, ", "), tableName, where), args, nil
+}
+
+// GenDelete 按主键生成delete sql v struct
+// 有 deleted 字段时为软删除: UPDATE 设置 deleted 为当前时间, 已删除的行不再更新
+func GenDelete(tableName string, v interface{}) (string, []interface{}, error) {
+	rv, m, err := primaryStruct(v)
+	if err != nil {
+		return "", nil, err
+	}
+	if m.deleted == nil {
+		return GenHardDelete(tableName, v)
+	}
+
+	where, args := primaryWhere(rv, m)
+	cond, err := notDeleted(m.deleted)
+	if err != nil {
+		return "", nil, err
+	}
+	val, _ := timestampValue(m.deleted.typ, now())
+	return fmt.Sprintf("UPDATE %s SET %s = ? WHERE %s AND %s;", tableName, m.deleted.name, where, cond),
+		append([]interface{}{val}, args...), nil
+}
+
+// GenHardDelete 按主键生成delete sql 忽略软删除
+func GenHardDelete(tableName string, v interface{}) (string, []interface{}, error) {
+	rv, m, err := primaryStruct(v)
+	if err != nil {
+		return "", nil, err
+	}
+	where, args := primaryWhere(rv, m)
+	return fmt.Sprintf("DELETE FROM %s WHERE %s;", tableName, where), args, nil
+}
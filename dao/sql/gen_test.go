@@ -0,0 +1,137 @@
+package sql
+
+import (
+	"reflect"
+	"testing"
+)
+
+type testUser struct {
+	UID   int    `db:"uid,pk"`
+	Name  string `db:"name"`
+	Level int    `db:"level"`
+}
+
+func TestGenInsertMap(t *testing.T) {
+	query, args := GenInsert("test", map[string]interface{}{"c": 3, "a": 1, "b": 2})
+	if want := "INSERT INTO test (a, b, c) VALUES (?, ?, ?);"; query != want {
+		t.Errorf("GenInsert() query = %v, want %v", query, want)
+	}
+	if want := []interface{}{1, 2, 3}; !reflect.DeepEqual(args, want) {
+		t.Errorf("GenInsert() args = %v, want %v", args, want)
+	}
+}
+
+func TestGenInsertBatch(t *testing.T) {
+	max := MaxPlaceholders
+	MaxPlaceholders = 7
+	defer func() { MaxPlaceholders = max }()
+
+	users := []*testUser{{1, "a", 1}, {2, "b", 2}, {3, "c", 3}}
+	got, err := GenInsertBatch("test", users)
+	if err != nil {
+		t.Fatalf("GenInsertBatch() error = %v", err)
+	}
+	want := []*ExecSQL{
+		{
+			Query: "INSERT INTO test (uid, name, level) VALUES (?, ?, ?), (?, ?, ?);",
+			Args:  []interface{}{1, "a", 1, 2, "b", 2},
+		},
+		{
+			Query: "INSERT INTO test (uid, name, level) VALUES (?, ?, ?);",
+			Args:  []interface{}{3, "c", 3},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GenInsertBatch() = %v, want %v", got, want)
+	}
+}
+
+func TestGenUpdate(t *testing.T) {
+	user := &testUser{UID: 1, Name: "a", Level: 2}
+	tests := []struct {
+		name      string
+		fields    []string
+		wantQuery string
+		wantArgs  []interface{}
+		wantErr   bool
+	}{
+		{
+			name:      "all",
+			wantQuery: "UPDATE test SET name = ?, level = ? WHERE uid = ?;",
+			wantArgs:  []interface{}{"a", 2, 1},
+		},
+		{
+			name:      "mask",
+			fields:    []string{"level"},
+			wantQuery: "UPDATE test SET level = ? WHERE uid = ?;",
+			wantArgs:  []interface{}{2, 1},
+		},
+		{
+			name:    "unknown",
+			fields:  []string{"unknown"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, args, err := GenUpdate("test", user, tt.fields...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GenUpdate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if query != tt.wantQuery || !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("GenUpdate() = %v %v, want %v %v", query, args, tt.wantQuery, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestGenUpsert(t *testing.T) {
+	user := &testUser{UID: 1, Name: "a", Level: 2}
+	tests := []struct {
+		name    string
+		dialect Dialect
+		want    string
+	}{
+		{
+			name:    "mysql",
+			dialect: DialectMySQL,
+			want:    "INSERT INTO test (uid, name, level) VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE level = VALUES(level);",
+		},
+		{
+			name:    "postgres",
+			dialect: DialectPostgres,
+			want:    "INSERT INTO test (uid, name, level) VALUES ($1, $2, $3) ON CONFLICT (uid) DO UPDATE SET level = EXCLUDED.level;",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, _, err := GenUpsert(tt.dialect, "test", user, "level")
+			if err != nil {
+				t.Fatalf("GenUpsert() error = %v", err)
+			}
+			if query != tt.want {
+				t.Errorf("GenUpsert() = %v, want %v", query, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenDelete(t *testing.T) {
+	query, args, err := GenDelete("test", testUser{UID: 1})
+	if err != nil {
+		t.Fatalf("GenDelete() error = %v", err)
+	}
+	if want := "DELETE FROM test WHERE uid = ?;"; query != want || !reflect.DeepEqual(args, []interface{}{1}) {
+		t.Errorf("GenDelete() = %v %v, want %v", query, args, want)
+	}
+	if _, _, err := GenDelete("test", map[string]interface{}{"uid": 1}); err == nil {
+		t.Errorf("GenDelete() map want error")
+	}
+}
+
+func TestRebind(t *testing.T) {
+	got := Rebind(DialectPostgres, "SELECT a FROM t WHERE b = ? AND c = '?' AND d = ?")
+	if want := "SELECT a FROM t WHERE b = $1 AND c = '?' AND d = $2"; got != want {
+		t.Errorf("Rebind() = %v, want %v", got, want)
+	}
+}
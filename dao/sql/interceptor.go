@@ -4,92 +4,172 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"time"
 
 	"github.com/knocknote/vitess-sqlparser/sqlparser"
 )
 
+// ExecKind 执行方式
+type ExecKind int
+
+// 执行方式 定义
+const (
+	ExecKindExec ExecKind = iota
+	ExecKindPrepare
+	ExecKindQuery
+	ExecKindQueryRow
+	ExecKindGet
+	ExecKindSelect
+	ExecKindInsert
+	ExecKindEach // 逐行遍历 耗时含回调
+	ExecKindUpdate
+	ExecKindDelete
+)
+
+func (kind ExecKind) String() string {
+	switch kind {
+	case ExecKindExec:
+		return "exec"
+	case ExecKindPrepare:
+		return "prepare"
+	case ExecKindQuery:
+		return "query"
+	case ExecKindQueryRow:
+		return "query_row"
+	case ExecKindGet:
+		return "get"
+	case ExecKindSelect:
+		return "select"
+	case ExecKindInsert:
+		return "insert"
+	case ExecKindEach:
+		return "each"
+	case ExecKindUpdate:
+		return "update"
+	case ExecKindDelete:
+		return "delete"
+	}
+	return fmt.Sprintf("ExecKind(%d)", int(kind))
+}
+
 // ExecSQL 执行语句
 type ExecSQL struct {
-	Query string
-	Args  []interface{}
-	Parse sqlparser.Statement // Query 的解析 不含 args
+	Kind     ExecKind
+	Query    string
+	Args     []interface{}
+	Parse    sqlparser.Statement // Query 的解析 不含 args, 解析失败为 nil
+	ParseErr error               // 解析失败的原因
 }
 
+// ExecResult 执行结果
+type ExecResult struct {
+	Result   sql.Result    // exec/insert 的结果, 其他为 nil
+	Rows     int           // exec/insert 为影响行数, get/select 为返回行数, 其他未知为 -1
+	Duration time.Duration // 执行耗时 get/select 含扫描
+}
+
+// Handler 执行语句
+type Handler func(ctx context.Context, execSQL *ExecSQL) (*ExecResult, error)
+
+// Middleware 中间件 调用 next 继续执行, 不调用则中断
+type Middleware func(ctx context.Context, execSQL *ExecSQL, next Handler) (*ExecResult, error)
+
 // BeforeExec exec 前调用 发生异常会会中断执行
 type BeforeExec func(ctx context.Context, execSQL *ExecSQL) error
 
 // BehindExec exec 后调用 不会中断 v 可能是 sql.Result
 type BehindExec func(ctx context.Context, execSQL *ExecSQL, result sql.Result)
 
-// Interceptor 拦截器
+// Interceptor 拦截器 按注册顺序由外向内执行中间件
 type Interceptor struct {
-	beforeExeces []BeforeExec
-	behindExeces []BehindExec
+	middlewares []Middleware
 }
 
 // NewInterceptor ...
 func NewInterceptor() *Interceptor {
 	return &Interceptor{
-		beforeExeces: []BeforeExec{},
-		behindExeces: []BehindExec{},
+		middlewares: []Middleware{},
 	}
 }
 
-// NewSafeInterceptor ...
-func NewSafeInterceptor() *Interceptor {
-	in := &Interceptor{
-		beforeExeces: []BeforeExec{},
-		behindExeces: []BehindExec{},
+// NewSafeInterceptor 安全拦截器 不传 policy 使用 DefaultSafePolicy
+func NewSafeInterceptor(policy ...*SafePolicy) *Interceptor {
+	in := NewInterceptor()
+	if len(policy) == 0 {
+		policy = append(policy, DefaultSafePolicy())
+	}
+	for _, p := range policy {
+		in.Use(p.Middleware())
 	}
-	in.RegisterBeforeExec(beforeExecSafe)
 	return in
 }
 
-// RegisterBeforeExec ...
+// Use 注册中间件
+func (in *Interceptor) Use(m ...Middleware) {
+	in.middlewares = append(in.middlewares, m...)
+}
+
+// RegisterBeforeExec Deprecated: Use Use instead.
 func (in *Interceptor) RegisterBeforeExec(f ...BeforeExec) {
-	in.beforeExeces = append(in.beforeExeces, f...)
+	for _, before := range f {
+		before := before
+		in.Use(func(ctx context.Context, execSQL *ExecSQL, next Handler) (*ExecResult, error) {
+			if err := before(ctx, execSQL); err != nil {
+				return nil, err
+			}
+			return next(ctx, execSQL)
+		})
+	}
 }
 
-// RegisterBehindExec ...
+// RegisterBehindExec Deprecated: Use Use instead.
 func (in *Interceptor) RegisterBehindExec(f ...BehindExec) {
-	in.behindExeces = append(in.behindExeces, f...)
+	for _, behind := range f {
+		behind := behind
+		in.Use(func(ctx context.Context, execSQL *ExecSQL, next Handler) (*ExecResult, error) {
+			result, err := next(ctx, execSQL)
+			if err == nil {
+				behind(ctx, execSQL, result.Result)
+			}
+			return result, err
+		})
+	}
 }
 
-// BeforeExec ...
-func (in *Interceptor) BeforeExec(ctx context.Context, execSQL *ExecSQL) error {
-	for _, f := range in.beforeExeces {
-		if err := f(ctx, execSQL); err != nil {
-			return err
+// Do 经过中间件执行 handler, in 为 nil 时直接执行
+func (in *Interceptor) Do(ctx context.Context, execSQL *ExecSQL, handler Handler) (*ExecResult, error) {
+	final := func(ctx context.Context, execSQL *ExecSQL) (*ExecResult, error) {
+		start := time.Now()
+		result, err := handler(ctx, execSQL)
+		if result == nil {
+			result = &ExecResult{Rows: -1}
 		}
+		result.Duration = time.Since(start)
+		return result, err
+	}
+	if in == nil || len(in.middlewares) == 0 {
+		return final(ctx, execSQL)
 	}
-	return nil
-}
 
-// BehindExec ...
-func (in *Interceptor) BehindExec(ctx context.Context, execSQL *ExecSQL, result sql.Result) {
-	for _, f := range in.behindExeces {
-		f(ctx, execSQL, result)
+	if execSQL.Parse == nil && execSQL.ParseErr == nil {
+		execSQL.Parse, execSQL.ParseErr = Parse(execSQL.Query)
 	}
-}
 
-func beforeExecSafe(ctx context.Context, execSQL *ExecSQL) error {
-	switch v := execSQL.Parse.(type) {
-	case *sqlparser.Select:
-		if _, ok := v.SelectExprs[0].(*sqlparser.StarExpr); ok {
-			return fmt.Errorf("select must have column name, not allow *")
-		}
-	case *sqlparser.Insert:
-		if len(v.Columns) == 0 {
-			return fmt.Errorf("insert must have column name")
-		}
-	case *sqlparser.Update:
-		if v.Where == nil {
-			return fmt.Errorf("update must have where")
-		}
-	case *sqlparser.Delete:
-		if v.Where == nil {
-			return fmt.Errorf("delete must have where")
+	next := final
+	for i := len(in.middlewares) - 1; i >= 0; i-- {
+		m, inner := in.middlewares[i], next
+		next = func(ctx context.Context, execSQL *ExecSQL) (*ExecResult, error) {
+			return m(ctx, execSQL, inner)
 		}
 	}
-	return nil
+	return next(ctx, execSQL)
+}
+
+// Observe 观察每条语句的结果和耗时 可用于监控和追踪
+func Observe(f func(ctx context.Context, execSQL *ExecSQL, result *ExecResult, err error)) Middleware {
+	return func(ctx context.Context, execSQL *ExecSQL, next Handler) (*ExecResult, error) {
+		result, err := next(ctx, execSQL)
+		f(ctx, execSQL, result, err)
+		return result, err
+	}
 }
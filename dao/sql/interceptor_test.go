@@ -0,0 +1,95 @@
+package sql
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestInterceptorDo(t *testing.T) {
+	calls := []string{}
+	in := NewInterceptor()
+	in.Use(func(ctx context.Context, execSQL *ExecSQL, next Handler) (*ExecResult, error) {
+		calls = append(calls, "outer before")
+		result, err := next(ctx, execSQL)
+		calls = append(calls, "outer behind")
+		return result, err
+	})
+	in.RegisterBeforeExec(func(ctx context.Context, execSQL *ExecSQL) error {
+		calls = append(calls, "before exec")
+		return nil
+	})
+	in.Use(Observe(func(ctx context.Context, execSQL *ExecSQL, result *ExecResult, err error) {
+		calls = append(calls, fmt.Sprintf("observe %v %v", execSQL.Kind, result.Rows))
+	}))
+
+	execSQL := &ExecSQL{Kind: ExecKindSelect, Query: "SELECT a FROM t WHERE b = ?", Args: []interface{}{1}}
+	result, err := in.Do(context.Background(), execSQL, func(ctx context.Context, execSQL *ExecSQL) (*ExecResult, error) {
+		calls = append(calls, "handler")
+		return &ExecResult{Rows: 3}, nil
+	})
+	if err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	if result.Rows != 3 || result.Duration <= 0 {
+		t.Errorf("Do() result = %+v", result)
+	}
+	if execSQL.Parse == nil {
+		t.Errorf("Do() Parse is nil")
+	}
+	want := []string{"outer before", "before exec", "handler", "observe select 3", "outer behind"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("Do() calls = %v, want %v", calls, want)
+	}
+}
+
+func TestInterceptorDoUnparsed(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{"show processlist", "SHOW FULL PROCESSLIST"},
+		{"show status", "SHOW STATUS LIKE 'Threads%'"},
+		{"syntax error", "SELEC a FRM t"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called := false
+			in := NewInterceptor()
+			in.Use(Observe(func(ctx context.Context, execSQL *ExecSQL, result *ExecResult, err error) {}))
+			execSQL := &ExecSQL{Kind: ExecKindQuery, Query: tt.query}
+			_, err := in.Do(context.Background(), execSQL, func(ctx context.Context, execSQL *ExecSQL) (*ExecResult, error) {
+				called = true
+				return &ExecResult{}, nil
+			})
+			if err != nil || !called {
+				t.Errorf("Do() error = %v, called = %v", err, called)
+			}
+			if execSQL.Parse != nil || execSQL.ParseErr == nil {
+				t.Errorf("Do() Parse = %v, ParseErr = %v", execSQL.Parse, execSQL.ParseErr)
+			}
+		})
+	}
+}
+
+func TestSafeInterceptor(t *testing.T) {
+	in := NewSafeInterceptor()
+	handler := func(ctx context.Context, execSQL *ExecSQL) (*ExecResult, error) {
+		return &ExecResult{}, nil
+	}
+	if _, err := in.Do(context.Background(), &ExecSQL{Query: "DELETE FROM t"}, handler); err == nil {
+		t.Errorf("Do() delete without where want error")
+	}
+
+	// 解析器会 panic 的语句 按无法解析处理
+	execSQL := &ExecSQL{Query: "SHOW FULL PROCESSLIST"}
+	if _, err := in.Do(context.Background(), execSQL, handler); err != nil || execSQL.ParseErr == nil {
+		t.Errorf("Do() show error = %v, ParseErr = %v", err, execSQL.ParseErr)
+	}
+
+	var in2 *Interceptor
+	if _, err := in2.Do(context.Background(), &ExecSQL{Query: "DELETE FROM t"}, handler); err != nil {
+		t.Errorf("Do() nil interceptor error = %v", err)
+	}
+}
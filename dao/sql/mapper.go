@@ -0,0 +1,372 @@
+package sql
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+	valuerType  = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+	timeType    = reflect.TypeOf(time.Time{})
+)
+
+// structField 列与字段的映射
+type structField struct {
+	name    string            // 列名
+	index   []int             // 字段路径 含嵌入结构体
+	typ     reflect.Type      // 字段类型
+	options map[string]string // tag 选项 db:"name,opt1,opt2=val"
+}
+
+// structMap 结构体的列映射 按字段定义顺序
+type structMap struct {
+	fields  []*structField
+	names   map[string]*structField
+	pks     []*structField // 主键 tag 含 pk 的字段, 没有则为列名 id 的字段
+	version *structField   // 乐观锁版本 tag 含 version 的字段
+	created *structField   // 创建时间 tag 含 created 的字段
+	updated *structField   // 更新时间 tag 含 updated 的字段
+	deleted *structField   // 软删除时间 tag 含 deleted 的字段
+}
+
+var structMaps sync.Map // reflect.Type -> *structMap
+
+// 是否作为单列处理 而不是展开为多列
+func isLeafType(typ reflect.Type) bool {
+	if typ == timeType {
+		return true
+	}
+	if typ.Implements(scannerType) || reflect.PtrTo(typ).Implements(scannerType) || typ.Implements(valuerType) {
+		return true
+	}
+	return typ.Kind() != reflect.Struct
+}
+
+// 解析 tag: db 优先, 其次 json, 否则使用字段名
+func parseTag(field reflect.StructField) (name string, options map[string]string, skip bool) {
+	options = map[string]string{}
+	tag, ok := field.Tag.Lookup("db")
+	if !ok {
+		tag = field.Tag.Get("json")
+	}
+	if tag == "-" {
+		return "", nil, true
+	}
+
+	splits := splitTag(tag)
+	name = splits[0]
+	for _, opt := range splits[1:] {
+		if opt == "" {
+			continue
+		}
+		kv := strings.SplitN(opt, "=", 2)
+		if len(kv) == 2 {
+			options[kv[0]] = kv[1]
+		} else {
+			options[kv[0]] = ""
+		}
+	}
+	return name, options, false
+}
+
+// splitTag 按括号和引号外的逗号拆分 tag, 如 type=DECIMAL(10,2) type=ENUM('a','b')
+func splitTag(tag string) []string {
+	splits := []string{}
+	depth, start := 0, 0
+	var quote rune
+	for i, c := range tag {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')' && depth > 0:
+			depth--
+		case c == ',' && depth == 0:
+			splits = append(splits, tag[start:i])
+			start = i + 1
+		}
+	}
+	return append(splits, tag[start:])
+}
+
+// collectStructFields 按定义顺序收集所有候选字段 含嵌入结构体的字段
+func collectStructFields(typ reflect.Type, index []int, fields []*structField) []*structField {
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		name, options, skip := parseTag(field)
+		if skip {
+			continue
+		}
+
+		fieldType := field.Type
+		elemType := fieldType
+		if elemType.Kind() == reflect.Ptr {
+			elemType = elemType.Elem()
+		}
+		fieldIndex := append(append([]int{}, index...), i)
+
+		// 未指定列名的嵌入结构体 展开
+		if field.Anonymous && name == "" && elemType.Kind() == reflect.Struct && !isLeafType(elemType) {
+			fields = collectStructFields(elemType, fieldIndex, fields)
+			continue
+		}
+		if field.PkgPath != "" { // 只处理导出的字段
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields = append(fields, &structField{
+			name:    name,
+			index:   fieldIndex,
+			typ:     fieldType,
+			options: options,
+		})
+	}
+	return fields
+}
+
+// buildStructMap 同名列 浅层优先, 同一深度有多个时都忽略 与 Go 的字段遮蔽一致
+func buildStructMap(typ reflect.Type, m *structMap) {
+	fields := collectStructFields(typ, nil, nil)
+	depths := map[string]int{}
+	counts := map[string]int{}
+	for _, f := range fields {
+		depth, ok := depths[f.name]
+		switch {
+		case !ok || len(f.index) < depth:
+			depths[f.name], counts[f.name] = len(f.index), 1
+		case len(f.index) == depth:
+			counts[f.name]++
+		}
+	}
+	for _, f := range fields {
+		if len(f.index) == depths[f.name] && counts[f.name] == 1 {
+			m.fields = append(m.fields, f)
+			m.names[f.name] = f
+		}
+	}
+}
+
+// getStructMap 获取结构体的列映射 结果会被缓存
+func getStructMap(typ reflect.Type) *structMap {
+	if m, ok := structMaps.Load(typ); ok {
+		return m.(*structMap)
+	}
+	m := &structMap{
+		fields: []*structField{},
+		names:  map[string]*structField{},
+	}
+	buildStructMap(typ, m)
+	for _, f := range m.fields {
+		if _, ok := f.options["pk"]; ok {
+			m.pks = append(m.pks, f)
+		}
+		if _, ok := f.options["version"]; ok && m.version == nil {
+			m.version = f
+		}
+		if _, ok := f.options["created"]; ok && m.created == nil {
+			m.created = f
+		}
+		if _, ok := f.options["updated"]; ok && m.updated == nil {
+			m.updated = f
+		}
+		if _, ok := f.options["deleted"]; ok && m.deleted == nil {
+			m.deleted = f
+		}
+	}
+	if f, ok := m.names["id"]; ok && len(m.pks) == 0 {
+		m.pks = append(m.pks, f)
+	}
+	for _, f := range m.fields {
+		if _, ok := m.names[strings.ToLower(f.name)]; !ok {
+			m.names[strings.ToLower(f.name)] = f
+		}
+	}
+	structMaps.Store(typ, m)
+	return m
+}
+
+func (m *structMap) lookup(column string) (*structField, bool) {
+	if f, ok := m.names[column]; ok {
+		return f, true
+	}
+	f, ok := m.names[strings.ToLower(column)]
+	return f, ok
+}
+
+// fieldByIndex 按路径获取字段 途经的 nil 嵌入指针会被创建
+func fieldByIndex(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+// fieldValueByIndex 按路径获取字段值 途经 nil 嵌入指针时返回 false
+func fieldValueByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+// nullable 非指针非 Scanner 字段 先扫描到 **T, 为 NULL 时置零值
+type nullable struct {
+	ptr   reflect.Value // **T
+	field reflect.Value
+}
+
+func (n *nullable) assign() {
+	if n.ptr.Elem().IsNil() {
+		n.field.Set(reflect.Zero(n.field.Type()))
+	} else {
+		n.field.Set(n.ptr.Elem().Elem())
+	}
+}
+
+// scanDest 根据字段类型 返回 Scan 的目标
+func scanDest(field reflect.Value) (interface{}, *nullable) {
+	typ := field.Type()
+	if typ.Kind() == reflect.Ptr || reflect.PtrTo(typ).Implements(scannerType) || typ.Kind() == reflect.Interface {
+		return field.Addr().Interface(), nil
+	}
+	if typ.Kind() == reflect.Slice && typ.Elem().Kind() == reflect.Uint8 { // []byte NULL 自然为 nil
+		return field.Addr().Interface(), nil
+	}
+	n := &nullable{
+		ptr:   reflect.New(reflect.PtrTo(typ)),
+		field: field,
+	}
+	return n.ptr.Interface(), n
+}
+
+// rowScanner 按列 将一行扫描进目标
+type rowScanner struct {
+	columns []string
+}
+
+func (s *rowScanner) scan(rows *sql.Rows, dest reflect.Value) error {
+	dest = reflect.Indirect(dest)
+
+	switch {
+	case dest.Kind() == reflect.Map:
+		if dest.Type().Key().Kind() != reflect.String {
+			return fmt.Errorf("map key must be string")
+		}
+		vals := make([]interface{}, len(s.columns))
+		ptrs := make([]interface{}, len(s.columns))
+		for i := range vals {
+			ptrs[i] = &vals[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return err
+		}
+		if dest.IsNil() {
+			if !dest.CanSet() {
+				return fmt.Errorf("map is nil")
+			}
+			dest.Set(reflect.MakeMap(dest.Type()))
+		}
+		for i, column := range s.columns {
+			var val reflect.Value
+			switch data := vals[i].(type) {
+			case nil:
+				val = reflect.Zero(dest.Type().Elem())
+			case []byte: // 文本协议的驱动 多数列返回 []byte, 统一转为 string
+				val = reflect.ValueOf(string(data))
+			default:
+				val = reflect.ValueOf(data)
+			}
+			if !val.Type().AssignableTo(dest.Type().Elem()) {
+				return fmt.Errorf("column %v: %v is not assignable to %v", column, val.Type(), dest.Type().Elem())
+			}
+			dest.SetMapIndex(reflect.ValueOf(column).Convert(dest.Type().Key()), val)
+		}
+		return nil
+	case isLeafType(dest.Type()):
+		if len(s.columns) != 1 {
+			return fmt.Errorf("scan into %v need 1 column, got %v", dest.Type(), len(s.columns))
+		}
+		d, n := scanDest(dest)
+		if err := rows.Scan(d); err != nil {
+			return err
+		}
+		if n != nil {
+			n.assign()
+		}
+		return nil
+	}
+
+	m := getStructMap(dest.Type())
+	ptrs := make([]interface{}, len(s.columns))
+	nulls := []*nullable{}
+	for i, column := range s.columns {
+		f, ok := m.lookup(column)
+		if !ok {
+			return fmt.Errorf("column not in fields: %v", column)
+		}
+		d, n := scanDest(fieldByIndex(dest, f.index))
+		ptrs[i] = d
+		if n != nil {
+			nulls = append(nulls, n)
+		}
+	}
+	if err := rows.Scan(ptrs...); err != nil {
+		return err
+	}
+	for _, n := range nulls {
+		n.assign()
+	}
+	return nil
+}
+
+// structValues 按字段定义顺序 返回结构体的列名和值, nil 嵌入指针的字段不返回
+// created/updated 字段为零值时 填充当前时间
+func structValues(v reflect.Value) ([]string, []interface{}) {
+	v = reflect.Indirect(v)
+	m := getStructMap(v.Type())
+	t := now()
+	columns := []string{}
+	args := []interface{}{}
+	for _, f := range m.fields {
+		if _, ok := fieldValueByIndex(v, f.index); !ok {
+			continue
+		}
+		columns = append(columns, f.name)
+		args = append(args, insertValue(v, m, f, t))
+	}
+	return columns, args
+}
+
+// structValue 获取列的值 nil 嵌入指针的字段返回 nil
+func structValue(v reflect.Value, f *structField) interface{} {
+	fv, ok := fieldValueByIndex(reflect.Indirect(v), f.index)
+	if !ok {
+		return nil
+	}
+	return fv.Interface()
+}
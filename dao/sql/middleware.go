@@ -0,0 +1,194 @@
+package sql
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	mlogger "github.com/cheetah-fun-gs/goplus/multier/multilogger"
+)
+
+// FilterOptions 内置中间件的公共参数
+type FilterOptions struct {
+	MLogName      string                                 // 日志器名称 默认 default
+	Redact        func(args []interface{}) []interface{} // 参数脱敏 默认原样输出
+	Tables        []string                               // 仅处理涉及这些表的语句 为空不限制
+	ExcludeTables []string                               // 不处理涉及这些表的语句
+}
+
+func (opts *FilterOptions) mlogName() string {
+	if opts.MLogName == "" {
+		return "default"
+	}
+	return opts.MLogName
+}
+
+func (opts *FilterOptions) args(args []interface{}) []interface{} {
+	if opts.Redact == nil {
+		return args
+	}
+	return opts.Redact(args)
+}
+
+func (opts *FilterOptions) match(tables []string) bool {
+	for _, table := range tables {
+		for _, exclude := range opts.ExcludeTables {
+			if table == exclude {
+				return false
+			}
+		}
+	}
+	if len(opts.Tables) == 0 {
+		return true
+	}
+	for _, table := range tables {
+		for _, include := range opts.Tables {
+			if table == include {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// RedactAll 参数全部脱敏
+func RedactAll(args []interface{}) []interface{} {
+	result := make([]interface{}, len(args))
+	for i := range args {
+		result[i] = "***"
+	}
+	return result
+}
+
+// SlowQueryOptions 慢查询参数
+type SlowQueryOptions struct {
+	FilterOptions
+	Threshold time.Duration // 阈值 默认 200ms
+}
+
+// SlowQuery 慢查询日志 耗时超过阈值的语句以 Warn 级别输出
+func SlowQuery(opts *SlowQueryOptions) Middleware {
+	if opts == nil {
+		opts = &SlowQueryOptions{}
+	}
+	threshold := opts.Threshold
+	if threshold <= 0 {
+		threshold = 200 * time.Millisecond
+	}
+
+	return func(ctx context.Context, execSQL *ExecSQL, next Handler) (*ExecResult, error) {
+		result, err := next(ctx, execSQL)
+		if result == nil || result.Duration < threshold || !opts.match(StatementTables(execSQL.Parse)) {
+			return result, err
+		}
+		mlogger.WarncN(ctx, opts.mlogName(), "slow sql %v, duration: %v, rows: %v, query: %v, args: %v, err: %v",
+			execSQL.Kind, result.Duration, result.Rows, execSQL.Query, opts.args(execSQL.Args), err)
+		return result, err
+	}
+}
+
+// AuditRecord 审计记录
+type AuditRecord struct {
+	Time     time.Time
+	Action   string   // insert/update/delete
+	Tables   []string // 涉及的表
+	Query    string
+	Args     []interface{} // 已脱敏
+	Rows     int           // 影响行数
+	Duration time.Duration
+	Err      error
+}
+
+// AuditSink 审计记录的输出
+type AuditSink interface {
+	Audit(ctx context.Context, record *AuditRecord)
+}
+
+// AuditSinkFunc 函数形式的 AuditSink
+type AuditSinkFunc func(ctx context.Context, record *AuditRecord)
+
+// Audit ...
+func (f AuditSinkFunc) Audit(ctx context.Context, record *AuditRecord) {
+	f(ctx, record)
+}
+
+// MLoggerAuditSink 审计记录以 Info 级别输出到日志器
+func MLoggerAuditSink(mlogName string) AuditSink {
+	return AuditSinkFunc(func(ctx context.Context, record *AuditRecord) {
+		mlogger.InfocN(ctx, mlogName, "audit sql %v, tables: %v, rows: %v, duration: %v, query: %v, args: %v, err: %v",
+			record.Action, record.Tables, record.Rows, record.Duration, record.Query, record.Args, record.Err)
+	})
+}
+
+// AuditOptions 审计参数
+type AuditOptions struct {
+	FilterOptions
+}
+
+// Audit 审计 每条写语句(insert/update/delete)执行后 输出到 sink, 含失败的语句
+func Audit(sink AuditSink, opts *AuditOptions) Middleware {
+	if opts == nil {
+		opts = &AuditOptions{}
+	}
+
+	return func(ctx context.Context, execSQL *ExecSQL, next Handler) (*ExecResult, error) {
+		result, err := next(ctx, execSQL)
+
+		action := StatementType(execSQL.Parse)
+		if action != StatementInsert && action != StatementUpdate && action != StatementDelete {
+			return result, err
+		}
+		tables := StatementTables(execSQL.Parse)
+		if !opts.match(tables) {
+			return result, err
+		}
+
+		record := &AuditRecord{
+			Time:   time.Now(),
+			Action: action,
+			Tables: tables,
+			Query:  execSQL.Query,
+			Args:   opts.args(execSQL.Args),
+			Rows:   -1,
+			Err:    err,
+		}
+		if result != nil {
+			record.Rows = result.Rows
+			record.Duration = result.Duration
+		}
+		sink.Audit(ctx, record)
+		return result, err
+	}
+}
+
+// SampleReadOptions 读语句采样参数
+type SampleReadOptions struct {
+	FilterOptions
+	Rate float64 // 采样率 0~1
+}
+
+// SampleRead 按采样率 以 Debug 级别输出读语句(select)
+func SampleRead(opts *SampleReadOptions) Middleware {
+	if opts == nil {
+		opts = &SampleReadOptions{}
+	}
+
+	return func(ctx context.Context, execSQL *ExecSQL, next Handler) (*ExecResult, error) {
+		result, err := next(ctx, execSQL)
+		if opts.Rate <= 0 || rand.Float64() >= opts.Rate {
+			return result, err
+		}
+		if StatementType(execSQL.Parse) != StatementSelect || !opts.match(StatementTables(execSQL.Parse)) {
+			return result, err
+		}
+
+		var duration time.Duration
+		rows := -1
+		if result != nil {
+			duration, rows = result.Duration, result.Rows
+		}
+		mlogger.DebugcN(ctx, opts.mlogName(), "sample sql %v, duration: %v, rows: %v, query: %v, args: %v, err: %v",
+			execSQL.Kind, duration, rows, execSQL.Query, opts.args(execSQL.Args), err)
+		return result, err
+	}
+}
@@ -0,0 +1,203 @@
+package sql
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	mlogger "github.com/cheetah-fun-gs/goplus/multier/multilogger"
+	"github.com/knocknote/vitess-sqlparser/sqlparser"
+)
+
+// testLogger 记录输出的日志 格式为 level: message
+type testLogger struct {
+	logs []string
+}
+
+func (l *testLogger) log(level, format string, v ...interface{}) {
+	l.logs = append(l.logs, level+": "+fmt.Sprintf(format, v...))
+}
+
+func (l *testLogger) Debug(format string, v ...interface{}) { l.log("debug", format, v...) }
+func (l *testLogger) Info(format string, v ...interface{})  { l.log("info", format, v...) }
+func (l *testLogger) Warn(format string, v ...interface{})  { l.log("warn", format, v...) }
+func (l *testLogger) Error(format string, v ...interface{}) { l.log("error", format, v...) }
+func (l *testLogger) Debugc(ctx context.Context, format string, v ...interface{}) {
+	l.log("debug", format, v...)
+}
+func (l *testLogger) Infoc(ctx context.Context, format string, v ...interface{}) {
+	l.log("info", format, v...)
+}
+func (l *testLogger) Warnc(ctx context.Context, format string, v ...interface{}) {
+	l.log("warn", format, v...)
+}
+func (l *testLogger) Errorc(ctx context.Context, format string, v ...interface{}) {
+	l.log("error", format, v...)
+}
+
+var middlewareLogger = &testLogger{}
+
+func init() {
+	if err := mlogger.Register("middleware_test", middlewareLogger); err != nil {
+		panic(err)
+	}
+}
+
+func TestStatementTables(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"SELECT a.id FROM a LEFT JOIN b ON a.id = b.a_id WHERE a.id IN (SELECT id FROM c)", []string{"a", "b", "c"}},
+		{"INSERT INTO a (id) VALUES (1)", []string{"a"}},
+		{"UPDATE a SET b = 1 WHERE id = 1", []string{"a"}},
+		{"DELETE FROM a WHERE id = 1", []string{"a"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			stmt, err := sqlparser.Parse(tt.query)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if got := StatementTables(stmt); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("StatementTables() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAudit(t *testing.T) {
+	records := []*AuditRecord{}
+	sink := AuditSinkFunc(func(ctx context.Context, record *AuditRecord) {
+		records = append(records, record)
+	})
+
+	in := NewInterceptor()
+	in.Use(Audit(sink, &AuditOptions{
+		FilterOptions: FilterOptions{
+			Redact:        RedactAll,
+			ExcludeTables: []string{"log"},
+		},
+	}))
+
+	handler := func(ctx context.Context, execSQL *ExecSQL) (*ExecResult, error) {
+		return &ExecResult{Rows: 2}, nil
+	}
+	for _, query := range []string{
+		"UPDATE user SET name = ? WHERE id = ?",
+		"SELECT name FROM user WHERE id = ?",
+		"INSERT INTO log (msg) VALUES (?)",
+	} {
+		if _, err := in.Do(context.Background(), &ExecSQL{Query: query, Args: []interface{}{1, 2}}, handler); err != nil {
+			t.Fatalf("Do() error = %v", err)
+		}
+	}
+
+	if len(records) != 1 {
+		t.Fatalf("Audit() records = %v, want 1", len(records))
+	}
+	record := records[0]
+	if record.Action != StatementUpdate || !reflect.DeepEqual(record.Tables, []string{"user"}) || record.Rows != 2 ||
+		!reflect.DeepEqual(record.Args, []interface{}{"***", "***"}) {
+		t.Errorf("Audit() record = %+v", record)
+	}
+}
+
+func TestSlowQuery(t *testing.T) {
+	// 直接调用中间件 Interceptor.Do 会覆盖为实际耗时
+	slow := SlowQuery(&SlowQueryOptions{
+		FilterOptions: FilterOptions{
+			MLogName:      "middleware_test",
+			Tables:        []string{"user", "log"},
+			ExcludeTables: []string{"log"},
+		},
+		Threshold: 100 * time.Millisecond,
+	})
+
+	tests := []struct {
+		name     string
+		query    string
+		duration time.Duration
+		want     int
+	}{
+		{"below threshold", "SELECT name FROM user WHERE id = ?", 100*time.Millisecond - 1, 0},
+		{"at threshold", "SELECT name FROM user WHERE id = ?", 100 * time.Millisecond, 1},
+		{"above threshold", "UPDATE user SET name = ? WHERE id = ?", time.Second, 1},
+		{"excluded table", "INSERT INTO log (msg) VALUES (?)", time.Second, 0},
+		{"excluded in join", "SELECT user.name FROM user JOIN log ON user.id = log.user_id", time.Second, 0},
+		{"not included table", "SELECT name FROM orders WHERE id = ?", time.Second, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			middlewareLogger.logs = nil
+			next := func(ctx context.Context, execSQL *ExecSQL) (*ExecResult, error) {
+				return &ExecResult{Rows: 1, Duration: tt.duration}, nil
+			}
+			stmt, err := Parse(tt.query)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if _, err := slow(context.Background(), &ExecSQL{Query: tt.query, Args: []interface{}{1}, Parse: stmt}, next); err != nil {
+				t.Fatalf("SlowQuery() error = %v", err)
+			}
+			if len(middlewareLogger.logs) != tt.want {
+				t.Fatalf("SlowQuery() logs = %q, want %v", middlewareLogger.logs, tt.want)
+			}
+			for _, log := range middlewareLogger.logs {
+				if log[:5] != "warn:" {
+					t.Errorf("SlowQuery() log = %v, want warn level", log)
+				}
+			}
+		})
+	}
+}
+
+func TestSampleRead(t *testing.T) {
+	tests := []struct {
+		name  string
+		rate  float64
+		query string
+		want  int
+	}{
+		{"rate 0", 0, "SELECT name FROM user WHERE id = ?", 0},
+		{"rate 1", 1, "SELECT name FROM user WHERE id = ?", 10},
+		{"rate 1 write", 1, "UPDATE user SET name = ? WHERE id = ?", 0},
+		{"rate 1 excluded table", 1, "SELECT msg FROM log WHERE id = ?", 0},
+		{"rate 1 not included table", 1, "SELECT id FROM orders WHERE id = ?", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := NewInterceptor()
+			in.Use(SampleRead(&SampleReadOptions{
+				FilterOptions: FilterOptions{
+					MLogName:      "middleware_test",
+					Redact:        RedactAll,
+					Tables:        []string{"user", "log"},
+					ExcludeTables: []string{"log"},
+				},
+				Rate: tt.rate,
+			}))
+			handler := func(ctx context.Context, execSQL *ExecSQL) (*ExecResult, error) {
+				return &ExecResult{Rows: 1}, nil
+			}
+
+			middlewareLogger.logs = nil
+			for i := 0; i < 10; i++ {
+				if _, err := in.Do(context.Background(), &ExecSQL{Query: tt.query, Args: []interface{}{1}}, handler); err != nil {
+					t.Fatalf("Do() error = %v", err)
+				}
+			}
+			if len(middlewareLogger.logs) != tt.want {
+				t.Fatalf("SampleRead() logs = %q, want %v", middlewareLogger.logs, tt.want)
+			}
+			for _, log := range middlewareLogger.logs {
+				if log[:6] != "debug:" || !strings.HasSuffix(log, "args: [***], err: <nil>") {
+					t.Errorf("SampleRead() log = %v", log)
+				}
+			}
+		})
+	}
+}
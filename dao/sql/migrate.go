@@ -0,0 +1,600 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	uuidplus "github.com/cheetah-fun-gs/goplus/uuid"
+)
+
+// ErrMigrationLockLost 迁移过程中锁被其他执行者抢占
+var ErrMigrationLockLost = errors.New("migration lock lost")
+
+// MigrationNoSplit 迁移脚本中有独占一行的该标记时 脚本不拆分 整体作为一条语句执行
+const MigrationNoSplit = "-- +migrate nosplit"
+
+// Migration 迁移 每个版本二选一: SQL 或 Go 函数, 同一事务内执行并记录版本
+type Migration struct {
+	Version  int64
+	Name     string
+	Up       string // 可含多条语句 以 ; 分隔
+	Down     string
+	NoSplit  bool // Up Down 不拆分 整体作为一条语句执行, 用于拆分不正确的脚本
+	UpFunc   func(ctx context.Context, tx *sql.Tx) error
+	DownFunc func(ctx context.Context, tx *sql.Tx) error
+}
+
+// MigrationStatus 迁移状态
+type MigrationStatus struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// MigratorOptions 迁移参数
+type MigratorOptions struct {
+	Dialect     Dialect
+	Table       string        // 记录已执行版本的表 默认 schema_migrations, 锁表为 {Table}_lock
+	LockTimeout time.Duration // 锁超时 超时的锁视为执行者已退出, 持有期间每 1/3 超时续期一次 默认 10 分钟
+	DryRun      bool          // 只返回将要执行的迁移 不执行也不建表
+}
+
+// Migrator 迁移器 直接使用 *sql.DB 不经过拦截器, 语句兼容 mysql/postgres/sqlite
+type Migrator struct {
+	db         *sql.DB
+	opts       *MigratorOptions
+	migrations []*Migration
+}
+
+// NewMigrator ...
+func NewMigrator(db *sql.DB, opts *MigratorOptions) *Migrator {
+	o := &MigratorOptions{}
+	if opts != nil {
+		*o = *opts
+	}
+	if o.Table == "" {
+		o.Table = "schema_migrations"
+	}
+	if o.LockTimeout <= 0 {
+		o.LockTimeout = 10 * time.Minute
+	}
+	return &Migrator{db: db, opts: o}
+}
+
+// Register 注册迁移 版本不能重复
+func (m *Migrator) Register(migrations ...*Migration) error {
+	for _, migration := range migrations {
+		if migration.Up == "" && migration.UpFunc == nil {
+			return fmt.Errorf("migration %v has no up", migration.Version)
+		}
+		for _, exists := range m.migrations {
+			if exists.Version == migration.Version {
+				return fmt.Errorf("duplicate migration version: %v", migration.Version)
+			}
+		}
+		m.migrations = append(m.migrations, migration)
+	}
+	sort.Slice(m.migrations, func(i, j int) bool { return m.migrations[i].Version < m.migrations[j].Version })
+	return nil
+}
+
+// parseMigrationFile 解析迁移文件名 {version}_{name}.up.sql 或 {version}_{name}.down.sql
+func parseMigrationFile(filename string) (version int64, name string, up bool, ok bool) {
+	base := filepath.Base(filename)
+	switch {
+	case strings.HasSuffix(base, ".up.sql"):
+		base, up = strings.TrimSuffix(base, ".up.sql"), true
+	case strings.HasSuffix(base, ".down.sql"):
+		base = strings.TrimSuffix(base, ".down.sql")
+	default:
+		return 0, "", false, false
+	}
+
+	parts := strings.SplitN(base, "_", 2)
+	version, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return 0, "", false, false
+	}
+	if len(parts) == 2 {
+		name = parts[1]
+	}
+	return version, name, up, true
+}
+
+// LoadDir 加载目录下的迁移文件 {version}_{name}.up.sql / {version}_{name}.down.sql, 其他文件忽略
+func (m *Migrator) LoadDir(dir string) error {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	migrations := map[int64]*Migration{}
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+		version, name, up, ok := parseMigrationFile(file.Name())
+		if !ok {
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(dir, file.Name()))
+		if err != nil {
+			return err
+		}
+
+		migration, ok := migrations[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: name}
+			migrations[version] = migration
+		}
+		if up {
+			migration.Up = string(data)
+		} else {
+			migration.Down = string(data)
+		}
+	}
+
+	for _, migration := range migrations {
+		if err := m.Register(migration); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Migrator) lockTable() string {
+	return m.opts.Table + "_lock"
+}
+
+func (m *Migrator) ensureTable(ctx context.Context) error {
+	queries := []string{
+		fmt.Sprintf("CREATE TABLE IF NOT EXISTS %v (version BIGINT NOT NULL PRIMARY KEY, name VARCHAR(255) NOT NULL, applied_at BIGINT NOT NULL);", m.opts.Table),
+		fmt.Sprintf("CREATE TABLE IF NOT EXISTS %v (id INT NOT NULL PRIMARY KEY, owner VARCHAR(64) NOT NULL, locked_at BIGINT NOT NULL);", m.lockTable()),
+	}
+	for _, query := range queries {
+		if _, err := m.db.ExecContext(ctx, query); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lock 在锁表插入唯一行 持有期间定时续期, 锁被抢占时取消返回的 ctx; 返回解锁函数
+func (m *Migrator) lock(ctx context.Context) (context.Context, func(), error) {
+	now := time.Now()
+	owner := uuidplus.NewV4().Base62()
+
+	query := Rebind(m.opts.Dialect, fmt.Sprintf("DELETE FROM %v WHERE id = 1 AND locked_at < ?;", m.lockTable()))
+	if _, err := m.db.ExecContext(ctx, query, now.Add(-m.opts.LockTimeout).UnixNano()); err != nil {
+		return nil, nil, err
+	}
+	query = Rebind(m.opts.Dialect, fmt.Sprintf("INSERT INTO %v (id, owner, locked_at) VALUES (1, ?, ?);", m.lockTable()))
+	if _, err := m.db.ExecContext(ctx, query, owner, now.UnixNano()); err != nil {
+		return nil, nil, fmt.Errorf("migration is locked: %v", err)
+	}
+
+	lockCtx, cancel := context.WithCancel(ctx)
+	stop, done := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(m.opts.LockTimeout / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-lockCtx.Done():
+				return
+			case <-ticker.C:
+				if !m.refresh(lockCtx, owner) {
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+
+	return lockCtx, func() {
+		close(stop)
+		<-done
+		cancel()
+		query := Rebind(m.opts.Dialect, fmt.Sprintf("DELETE FROM %v WHERE id = 1 AND owner = ?;", m.lockTable()))
+		m.db.ExecContext(context.Background(), query, owner)
+	}, nil
+}
+
+// refresh 续期 返回锁是否仍被持有, 数据库出错时视为仍持有 下次再试
+func (m *Migrator) refresh(ctx context.Context, owner string) bool {
+	query := Rebind(m.opts.Dialect, fmt.Sprintf("UPDATE %v SET locked_at = ? WHERE id = 1 AND owner = ?;", m.lockTable()))
+	result, err := m.db.ExecContext(ctx, query, time.Now().UnixNano(), owner)
+	if err != nil {
+		return true
+	}
+	n, err := result.RowsAffected()
+	return err != nil || n > 0
+}
+
+// applied 已执行的版本 dry run 时表不存在视为没有执行过
+func (m *Migrator) applied(ctx context.Context) (map[int64]time.Time, error) {
+	result := map[int64]time.Time{}
+	rows, err := m.db.QueryContext(ctx, fmt.Sprintf("SELECT version, applied_at FROM %v;", m.opts.Table))
+	if err != nil {
+		if m.opts.DryRun {
+			return result, nil
+		}
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var version, appliedAt int64
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		result[version] = time.Unix(0, appliedAt)
+	}
+	return result, rows.Err()
+}
+
+// pending 未执行且版本不超过 target 的迁移 按版本升序
+func (m *Migrator) pending(applied map[int64]time.Time, target int64) []*Migration {
+	result := []*Migration{}
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; !ok && migration.Version <= target {
+			result = append(result, migration)
+		}
+	}
+	return result
+}
+
+// rollback 已执行的最近 steps 个迁移 按版本降序
+func (m *Migrator) rollback(applied map[int64]time.Time, steps int) []*Migration {
+	result := []*Migration{}
+	for i := len(m.migrations) - 1; i >= 0 && len(result) < steps; i-- {
+		if _, ok := applied[m.migrations[i].Version]; ok {
+			result = append(result, m.migrations[i])
+		}
+	}
+	return result
+}
+
+// Status 所有已注册迁移的状态 按版本升序
+func (m *Migrator) Status(ctx context.Context) ([]*MigrationStatus, error) {
+	if !m.opts.DryRun {
+		if err := m.ensureTable(ctx); err != nil {
+			return nil, err
+		}
+	}
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []*MigrationStatus{}
+	for _, migration := range m.migrations {
+		appliedAt, ok := applied[migration.Version]
+		result = append(result, &MigrationStatus{
+			Version:   migration.Version,
+			Name:      migration.Name,
+			Applied:   ok,
+			AppliedAt: appliedAt,
+		})
+	}
+	return result, nil
+}
+
+// Up 执行所有未执行的迁移 返回执行了的迁移, 出错时已执行的不回滚
+func (m *Migrator) Up(ctx context.Context) ([]*Migration, error) {
+	return m.UpTo(ctx, 1<<63-1)
+}
+
+// UpTo 执行版本不超过 version 的未执行迁移
+func (m *Migrator) UpTo(ctx context.Context, version int64) ([]*Migration, error) {
+	return m.run(ctx, func(applied map[int64]time.Time) []*Migration {
+		return m.pending(applied, version)
+	}, true)
+}
+
+// Down 回滚最近 steps 个已执行的迁移
+func (m *Migrator) Down(ctx context.Context, steps int) ([]*Migration, error) {
+	return m.run(ctx, func(applied map[int64]time.Time) []*Migration {
+		return m.rollback(applied, steps)
+	}, false)
+}
+
+func (m *Migrator) run(ctx context.Context, plan func(applied map[int64]time.Time) []*Migration, up bool) ([]*Migration, error) {
+	if m.opts.DryRun {
+		applied, err := m.applied(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return plan(applied), nil
+	}
+
+	if err := m.ensureTable(ctx); err != nil {
+		return nil, err
+	}
+	lockCtx, unlock, err := m.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	applied, err := m.applied(lockCtx)
+	if err != nil {
+		return nil, err
+	}
+
+	done := []*Migration{}
+	for _, migration := range plan(applied) {
+		if err := m.apply(lockCtx, migration, up); err != nil {
+			if lockCtx.Err() != nil && ctx.Err() == nil {
+				err = ErrMigrationLockLost
+			}
+			return done, fmt.Errorf("migration %v %v: %v", migration.Version, migration.Name, err)
+		}
+		done = append(done, migration)
+	}
+	return done, nil
+}
+
+// apply 在事务内执行一个迁移并记录 注意: mysql 的 ddl 会隐式提交 无法回滚
+func (m *Migrator) apply(ctx context.Context, migration *Migration, up bool) error {
+	script, fn := migration.Up, migration.UpFunc
+	if !up {
+		script, fn = migration.Down, migration.DownFunc
+		if script == "" && fn == nil {
+			return fmt.Errorf("no down")
+		}
+	}
+
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := m.applyTx(ctx, tx, migration, script, fn, up); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (m *Migrator) applyTx(ctx context.Context, tx *sql.Tx, migration *Migration, script string,
+	fn func(ctx context.Context, tx *sql.Tx) error, up bool) error {
+	if fn != nil {
+		if err := fn(ctx, tx); err != nil {
+			return err
+		}
+	} else {
+		for _, query := range migrationStatements(m.opts.Dialect, script, migration.NoSplit) {
+			if _, err := tx.ExecContext(ctx, query); err != nil {
+				return err
+			}
+		}
+	}
+
+	if up {
+		query := Rebind(m.opts.Dialect, fmt.Sprintf("INSERT INTO %v (version, name, applied_at) VALUES (?, ?, ?);", m.opts.Table))
+		_, err := tx.ExecContext(ctx, query, migration.Version, migration.Name, time.Now().UnixNano())
+		return err
+	}
+	query := Rebind(m.opts.Dialect, fmt.Sprintf("DELETE FROM %v WHERE version = ?;", m.opts.Table))
+	_, err := tx.ExecContext(ctx, query, migration.Version)
+	return err
+}
+
+// migrationStatements 脚本中的语句 noSplit 或含 MigrationNoSplit 标记时不拆分
+func migrationStatements(dialect Dialect, script string, noSplit bool) []string {
+	if !noSplit {
+		for _, line := range strings.Split(script, "\n") {
+			if strings.TrimSpace(line) == MigrationNoSplit {
+				noSplit = true
+				break
+			}
+		}
+	}
+	if !noSplit {
+		return splitStatements(dialect, script)
+	}
+	if script = strings.TrimSpace(script); script == "" {
+		return []string{}
+	}
+	return []string{script}
+}
+
+// splitStatements 按语句外的分号 拆分语句, 去掉注释和空语句
+// 引号、/* */ -- 注释、BEGIN ... END 块(触发器/存储过程)中的分号不拆分;
+// mysql 另支持引号内的反斜杠转义、# 注释和客户端的 DELIMITER 指令(指令行本身不输出), postgres 另支持 $tag$ 函数体
+func splitStatements(dialect Dialect, query string) []string {
+	statements := []string{}
+	var builder strings.Builder
+	runes := []rune(query)
+	delimiter := []rune(";")
+	depth := 0 // BEGIN/CASE 块的嵌套层数
+	lineStart := true
+	flush := func() {
+		if statement := strings.TrimSpace(builder.String()); statement != "" {
+			statements = append(statements, statement)
+		}
+		builder.Reset()
+		depth = 0
+	}
+	hasPrefix := func(i int, prefix []rune) bool {
+		if i+len(prefix) > len(runes) {
+			return false
+		}
+		for j, c := range prefix {
+			if runes[i+j] != c {
+				return false
+			}
+		}
+		return true
+	}
+	lineEnd := func(i int) int {
+		for i < len(runes) && runes[i] != '\n' {
+			i++
+		}
+		return i
+	}
+
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
+		if lineStart && dialect == DialectMySQL {
+			// DELIMITER 指令 须独占一行
+			j := i
+			for j < len(runes) && (runes[j] == ' ' || runes[j] == '\t') {
+				j++
+			}
+			if word, end := readWord(runes, j); strings.EqualFold(word, "DELIMITER") && end < len(runes) && (runes[end] == ' ' || runes[end] == '\t') {
+				i = lineEnd(end)
+				if d := strings.TrimSpace(string(runes[end:i])); d != "" {
+					flush()
+					delimiter = []rune(d)
+				}
+				continue
+			}
+		}
+		lineStart = c == '\n'
+
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			// 引号内原样输出 连续两个引号视为结束后紧接新的引号
+			builder.WriteRune(c)
+			for i++; i < len(runes); i++ {
+				builder.WriteRune(runes[i])
+				if runes[i] == '\\' && c != '`' && dialect == DialectMySQL && i+1 < len(runes) {
+					i++
+					builder.WriteRune(runes[i])
+					continue
+				}
+				if runes[i] == c {
+					break
+				}
+			}
+			continue
+		case c == '-' && hasPrefix(i, []rune("--")) || c == '#' && dialect == DialectMySQL:
+			i = lineEnd(i)
+			builder.WriteRune('\n')
+			lineStart = true
+			continue
+		case hasPrefix(i, []rune("/*")):
+			end := i + 2
+			for end < len(runes) && !hasPrefix(end, []rune("*/")) {
+				end++
+			}
+			if end += 2; end > len(runes) {
+				end = len(runes)
+			}
+			// /*! */ 和 /*+ */ 是 mysql 的可执行注释和优化器提示 保留
+			if comment := string(runes[i:end]); strings.HasPrefix(comment, "/*!") || strings.HasPrefix(comment, "/*+") {
+				builder.WriteString(comment)
+			} else {
+				builder.WriteRune(' ')
+			}
+			i = end - 1
+			continue
+		case hasPrefix(i, delimiter) && (depth == 0 || string(delimiter) != ";"):
+			// 自定义 DELIMITER 时 块内也拆分
+			flush()
+			i += len(delimiter) - 1
+			continue
+		case c == '$' && dialect == DialectPostgres:
+			// postgres 的 $tag$ ... $tag$ 函数体
+			if tag := dollarTag(runes, i); tag != "" {
+				rest := string(runes[i+len(tag):])
+				body := tag + rest
+				if end := strings.Index(rest, tag); end >= 0 {
+					body = tag + rest[:end+len(tag)]
+				}
+				builder.WriteString(body)
+				i += len([]rune(body)) - 1
+				continue
+			}
+		case isWordStart(runes, i):
+			word, end := readWord(runes, i)
+			depth = blockDepth(runes, end, word, depth)
+			builder.WriteString(word)
+			i = end - 1
+			continue
+		}
+		builder.WriteRune(c)
+	}
+	flush()
+	return statements
+}
+
+// blockDepth 遇到 word 后的 BEGIN/CASE 块嵌套层数
+// BEGIN 后紧跟 ; TRANSACTION WORK 或结束时是事务语句 不算块; END IF/LOOP/WHILE/REPEAT 结束的块开头未计数 不减少
+func blockDepth(runes []rune, end int, word string, depth int) int {
+	next, _ := readWord(runes, skipSpace(runes, end))
+	switch strings.ToUpper(word) {
+	case "BEGIN":
+		i := skipSpace(runes, end)
+		if i >= len(runes) || runes[i] == ';' {
+			return depth
+		}
+		switch strings.ToUpper(next) {
+		case "TRANSACTION", "WORK", "DEFERRED", "IMMEDIATE", "EXCLUSIVE":
+			return depth
+		}
+		return depth + 1
+	case "CASE":
+		return depth + 1
+	case "END":
+		switch strings.ToUpper(next) {
+		case "IF", "LOOP", "WHILE", "REPEAT":
+			return depth
+		}
+		if depth > 0 {
+			return depth - 1
+		}
+	}
+	return depth
+}
+
+func isWordRune(c rune) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c > 127
+}
+
+// dollarTag i 处的 $tag$ 标签 tag 可为空, 不是标签时返回空字符串
+func dollarTag(runes []rune, i int) string {
+	j := i + 1
+	for j < len(runes) && (runes[j] == '_' || runes[j] >= 'a' && runes[j] <= 'z' || runes[j] >= 'A' && runes[j] <= 'Z' ||
+		j > i+1 && runes[j] >= '0' && runes[j] <= '9') {
+		j++
+	}
+	if j < len(runes) && runes[j] == '$' {
+		return string(runes[i : j+1])
+	}
+	return ""
+}
+
+// isWordStart i 处是否为单词开头
+func isWordStart(runes []rune, i int) bool {
+	return isWordRune(runes[i]) && (i == 0 || !isWordRune(runes[i-1]))
+}
+
+// readWord 读取 i 开始的单词 返回单词和结束位置
+func readWord(runes []rune, i int) (string, int) {
+	end := i
+	for end < len(runes) && isWordRune(runes[end]) {
+		end++
+	}
+	return string(runes[i:end]), end
+}
+
+func skipSpace(runes []rune, i int) int {
+	for i < len(runes) && (runes[i] == ' ' || runes[i] == '\t' || runes[i] == '\n' || runes[i] == '\r') {
+		i++
+	}
+	return i
+}
@@ -0,0 +1,120 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestMigratorSQLite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "migrate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	db, err := sql.Open("sqlite3", filepath.Join(dir, "test.db")+"?_busy_timeout=5000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+
+	m := NewMigrator(db, &MigratorOptions{Dialect: DialectSQLite})
+	if err := m.Register(
+		&Migration{Version: 1, Name: "tables",
+			Up: `-- user; counter
+CREATE TABLE user (id INTEGER PRIMARY KEY, name TEXT NOT NULL DEFAULT ';');
+/* 计数; 由触发器维护 */
+CREATE TABLE counter (n INTEGER NOT NULL);
+INSERT INTO counter (n) VALUES (0);`,
+			Down: "DROP TABLE counter; DROP TABLE user;"},
+		&Migration{Version: 2, Name: "trigger",
+			Up: `CREATE TRIGGER user_count AFTER INSERT ON user BEGIN
+	UPDATE counter SET n = n + 1;
+	UPDATE counter SET n = CASE WHEN n > 100 THEN 100 ELSE n END;
+END;`,
+			Down: "DROP TRIGGER user_count;"},
+		&Migration{Version: 3, Name: "quotes",
+			Up:   `INSERT INTO user (name) VALUES ('it''s; a'); INSERT INTO user (name) VALUES ('c:\');`,
+			Down: `DELETE FROM user WHERE name IN ('it''s; a', 'c:\');`},
+		&Migration{Version: 4, Name: "no split",
+			Up:   MigrationNoSplit + "\nINSERT INTO user (name) VALUES ('x'); INSERT INTO user (name) VALUES ('y');",
+			Down: "DELETE FROM user WHERE name IN ('x', 'y');"},
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	query := func(query string) []string {
+		rows, err := db.Query(query)
+		if err != nil {
+			t.Fatalf("Query(%v) error = %v", query, err)
+		}
+		defer rows.Close()
+		result := []string{}
+		for rows.Next() {
+			var s string
+			if err := rows.Scan(&s); err != nil {
+				t.Fatal(err)
+			}
+			result = append(result, s)
+		}
+		return result
+	}
+	versions := func(migrations []*Migration) []int64 {
+		result := []int64{}
+		for _, migration := range migrations {
+			result = append(result, migration.Version)
+		}
+		return result
+	}
+
+	done, err := m.Up(ctx)
+	if err != nil {
+		t.Fatalf("Up() error = %v", err)
+	}
+	if got := versions(done); !reflect.DeepEqual(got, []int64{1, 2, 3, 4}) {
+		t.Errorf("Up() versions = %v", got)
+	}
+	if got := query("SELECT name FROM user ORDER BY id"); !reflect.DeepEqual(got, []string{"it's; a", `c:\`, "x", "y"}) {
+		t.Errorf("user names = %q", got)
+	}
+	if got := query("SELECT n FROM counter"); !reflect.DeepEqual(got, []string{"4"}) {
+		t.Errorf("trigger counter = %v, want 4", got)
+	}
+
+	// 失败的迁移整体回滚 版本不记录
+	if err := m.Register(&Migration{Version: 5, Name: "broken",
+		Up: "CREATE TABLE t5 (id INTEGER); INSERT INTO missing (id) VALUES (1);"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Up(ctx); err == nil || !strings.Contains(err.Error(), "missing") {
+		t.Errorf("Up() broken error = %v", err)
+	}
+	if got := query("SELECT name FROM sqlite_master WHERE name = 't5'"); len(got) != 0 {
+		t.Errorf("failed migration not rolled back: %v", got)
+	}
+	if got := query("SELECT version FROM schema_migrations ORDER BY version"); !reflect.DeepEqual(got, []string{"1", "2", "3", "4"}) {
+		t.Errorf("applied versions = %v", got)
+	}
+
+	done, err = m.Down(ctx, 4)
+	if err != nil {
+		t.Fatalf("Down() error = %v", err)
+	}
+	if got := versions(done); !reflect.DeepEqual(got, []int64{4, 3, 2, 1}) {
+		t.Errorf("Down() versions = %v", got)
+	}
+	if got := query("SELECT name FROM sqlite_master WHERE name NOT LIKE 'schema_migrations%' AND name NOT LIKE 'sqlite_%'"); len(got) != 0 {
+		t.Errorf("Down() left %v", got)
+	}
+	if got := query("SELECT owner FROM schema_migrations_lock"); len(got) != 0 {
+		t.Errorf("lock not released: %v", got)
+	}
+}
@@ -0,0 +1,217 @@
+package sql
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseMigrationFile(t *testing.T) {
+	tests := []struct {
+		filename    string
+		wantVersion int64
+		wantName    string
+		wantUp      bool
+		wantOk      bool
+	}{
+		{"20200101_create_user.up.sql", 20200101, "create_user", true, true},
+		{"dir/2_add_index.down.sql", 2, "add_index", false, true},
+		{"3.up.sql", 3, "", true, true},
+		{"create_user.up.sql", 0, "", false, false},
+		{"1_readme.md", 0, "", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.filename, func(t *testing.T) {
+			version, name, up, ok := parseMigrationFile(tt.filename)
+			if version != tt.wantVersion || name != tt.wantName || up != tt.wantUp || ok != tt.wantOk {
+				t.Errorf("parseMigrationFile() = %v %v %v %v, want %v %v %v %v",
+					version, name, up, ok, tt.wantVersion, tt.wantName, tt.wantUp, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		name    string
+		dialect Dialect
+		query   string
+		want    []string
+	}{
+		{
+			name:  "quotes and line comments",
+			query: "-- create\nCREATE TABLE a (b VARCHAR(8) DEFAULT ';');\n\nINSERT INTO a (b) VALUES ('--');\n-- end\n",
+			want:  []string{"CREATE TABLE a (b VARCHAR(8) DEFAULT ';')", "INSERT INTO a (b) VALUES ('--')"},
+		},
+		{
+			name:  "hash comment",
+			query: "# create\nCREATE TABLE a (id INT); # trailing; comment\nDROP TABLE b;",
+			want:  []string{"CREATE TABLE a (id INT)", "DROP TABLE b"},
+		},
+		{
+			name:  "block comment",
+			query: "/* a; b */ CREATE TABLE a (id INT) /* c;\nd */; DROP TABLE b",
+			want:  []string{"CREATE TABLE a (id INT)", "DROP TABLE b"},
+		},
+		{
+			name:  "executable comment kept",
+			query: "CREATE TABLE a (id INT) /*!50100 ENGINE=InnoDB */; DROP TABLE b",
+			want:  []string{"CREATE TABLE a (id INT) /*!50100 ENGINE=InnoDB */", "DROP TABLE b"},
+		},
+		{
+			name:  "backslash escaped quotes",
+			query: `INSERT INTO a (b) VALUES ('it\'s; fine'), ("say \"hi;\""); INSERT INTO a (b) VALUES ('c:\\');`,
+			want:  []string{`INSERT INTO a (b) VALUES ('it\'s; fine'), ("say \"hi;\"")`, `INSERT INTO a (b) VALUES ('c:\\')`},
+		},
+		{
+			name:  "doubled quotes",
+			query: "INSERT INTO a (b) VALUES ('it''s; fine'); DROP TABLE b",
+			want:  []string{"INSERT INTO a (b) VALUES ('it''s; fine')", "DROP TABLE b"},
+		},
+		{
+			name: "trigger body",
+			query: `CREATE TRIGGER t AFTER INSERT ON a FOR EACH ROW BEGIN
+	UPDATE b SET n = n + 1;
+	UPDATE c SET n = CASE WHEN n > 0 THEN n - 1 ELSE 0 END;
+END;
+INSERT INTO a (id) VALUES (1);`,
+			want: []string{`CREATE TRIGGER t AFTER INSERT ON a FOR EACH ROW BEGIN
+	UPDATE b SET n = n + 1;
+	UPDATE c SET n = CASE WHEN n > 0 THEN n - 1 ELSE 0 END;
+END`, "INSERT INTO a (id) VALUES (1)"},
+		},
+		{
+			name: "procedure with nested blocks",
+			query: `CREATE PROCEDURE p() BEGIN
+	IF 1 THEN BEGIN SELECT 1; END; END IF;
+	WHILE 0 DO SELECT 2; END WHILE;
+END; SELECT 3`,
+			want: []string{`CREATE PROCEDURE p() BEGIN
+	IF 1 THEN BEGIN SELECT 1; END; END IF;
+	WHILE 0 DO SELECT 2; END WHILE;
+END`, "SELECT 3"},
+		},
+		{
+			name:  "transaction begin",
+			query: "BEGIN; INSERT INTO a (id) VALUES (1); BEGIN TRANSACTION; COMMIT;",
+			want:  []string{"BEGIN", "INSERT INTO a (id) VALUES (1)", "BEGIN TRANSACTION", "COMMIT"},
+		},
+		{
+			name:  "identifiers containing keywords",
+			query: "UPDATE a SET begin_at = 1, legend = 2; SELECT 1",
+			want:  []string{"UPDATE a SET begin_at = 1, legend = 2", "SELECT 1"},
+		},
+		{
+			name:  "delimiter",
+			query: "DELIMITER $$\nCREATE PROCEDURE p() SELECT 1; SELECT 2$$\nDELIMITER ;\nSELECT 3;",
+			want:  []string{"CREATE PROCEDURE p() SELECT 1; SELECT 2", "SELECT 3"},
+		},
+		{
+			name:    "dollar quoted body",
+			dialect: DialectPostgres,
+			query:   "CREATE FUNCTION f() RETURNS trigger AS $body$ BEGIN NEW.n := 1; RETURN NEW; END; $body$ LANGUAGE plpgsql; SELECT $1",
+			want:    []string{"CREATE FUNCTION f() RETURNS trigger AS $body$ BEGIN NEW.n := 1; RETURN NEW; END; $body$ LANGUAGE plpgsql", "SELECT $1"},
+		},
+		{
+			name:    "sqlite backslash is literal",
+			dialect: DialectSQLite,
+			query:   `INSERT INTO a (b) VALUES ('c:\'); SELECT "a#b"; SELECT 1 # 2;`,
+			want:    []string{`INSERT INTO a (b) VALUES ('c:\')`, `SELECT "a#b"`, "SELECT 1 # 2"},
+		},
+		{
+			name:    "postgres hash is operator",
+			dialect: DialectPostgres,
+			query:   "SELECT 5 # 1; SELECT 2",
+			want:    []string{"SELECT 5 # 1", "SELECT 2"},
+		},
+		{
+			name:  "empty",
+			query: " ;\n-- only comment\n; ",
+			want:  []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := splitStatements(tt.dialect, tt.query); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitStatements() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMigrationStatements(t *testing.T) {
+	tests := []struct {
+		name    string
+		script  string
+		noSplit bool
+		want    []string
+	}{
+		{"split", "SELECT 1; SELECT 2;", false, []string{"SELECT 1", "SELECT 2"}},
+		{"no split field", "SELECT 1; SELECT 2;", true, []string{"SELECT 1; SELECT 2;"}},
+		{"no split marker", MigrationNoSplit + "\nSELECT 1; SELECT 2;", false, []string{MigrationNoSplit + "\nSELECT 1; SELECT 2;"}},
+		{"marker not alone", "SELECT 1; " + MigrationNoSplit + "\nSELECT 2;", false, []string{"SELECT 1", "SELECT 2"}},
+		{"no split empty", " ", true, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := migrationStatements(DialectMySQL, tt.script, tt.noSplit); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("migrationStatements() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMigratorPlan(t *testing.T) {
+	dir, err := ioutil.TempDir("", "migrate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"1_a.up.sql":   "CREATE TABLE a (id INT);",
+		"1_a.down.sql": "DROP TABLE a;",
+		"3_c.up.sql":   "CREATE TABLE c (id INT);",
+		"README.md":    "ignored",
+	}
+	for name, data := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m := NewMigrator(nil, nil)
+	if err := m.LoadDir(dir); err != nil {
+		t.Fatalf("LoadDir() error = %v", err)
+	}
+	if err := m.Register(&Migration{Version: 2, Name: "b", Up: "CREATE TABLE b (id INT);"}); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	if err := m.Register(&Migration{Version: 3, Up: "x"}); err == nil {
+		t.Errorf("Register() duplicate want error")
+	}
+
+	versions := func(migrations []*Migration) []int64 {
+		result := []int64{}
+		for _, migration := range migrations {
+			result = append(result, migration.Version)
+		}
+		return result
+	}
+	applied := map[int64]time.Time{1: time.Now()}
+	if got := versions(m.pending(applied, 1<<63-1)); !reflect.DeepEqual(got, []int64{2, 3}) {
+		t.Errorf("pending() = %v, want [2 3]", got)
+	}
+	if got := versions(m.pending(applied, 2)); !reflect.DeepEqual(got, []int64{2}) {
+		t.Errorf("pending() = %v, want [2]", got)
+	}
+	applied[3] = time.Now()
+	if got := versions(m.rollback(applied, 5)); !reflect.DeepEqual(got, []int64{3, 1}) {
+		t.Errorf("rollback() = %v, want [3 1]", got)
+	}
+	if m.migrations[0].Down != "DROP TABLE a;" {
+		t.Errorf("LoadDir() down = %q", m.migrations[0].Down)
+	}
+}
@@ -0,0 +1,81 @@
+package sql
+
+import (
+	"fmt"
+
+	"github.com/knocknote/vitess-sqlparser/sqlparser"
+)
+
+// 语句类型 定义
+const (
+	StatementSelect = "select"
+	StatementInsert = "insert"
+	StatementUpdate = "update"
+	StatementDelete = "delete"
+	StatementDDL    = "ddl"
+	StatementOther  = "other"
+)
+
+// Parse 解析语句 解析器对部分语句(如 SHOW)会 panic, 此时返回错误
+func Parse(query string) (stmt sqlparser.Statement, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			stmt, err = nil, fmt.Errorf("parse panic: %v", r)
+		}
+	}()
+	return sqlparser.Parse(query)
+}
+
+// StatementType 语句类型 解析失败为 other
+func StatementType(stmt sqlparser.Statement) string {
+	switch stmt.(type) {
+	case *sqlparser.Select, *sqlparser.Union, *sqlparser.ParenSelect:
+		return StatementSelect
+	case *sqlparser.Insert:
+		return StatementInsert
+	case *sqlparser.Update:
+		return StatementUpdate
+	case *sqlparser.Delete:
+		return StatementDelete
+	case *sqlparser.DDL, *sqlparser.CreateTable:
+		return StatementDDL
+	}
+	return StatementOther
+}
+
+// StatementTables 语句涉及的表名 含 join 和子查询, 按出现顺序去重, 不含 dual
+func StatementTables(stmt sqlparser.Statement) []string {
+	tables := []string{}
+	if stmt == nil {
+		return tables
+	}
+
+	exists := map[string]bool{}
+	add := func(table sqlparser.TableName) {
+		name := table.Name.String()
+		if name == "" || name == "dual" || exists[name] {
+			return
+		}
+		exists[name] = true
+		tables = append(tables, name)
+	}
+
+	switch v := stmt.(type) {
+	case *sqlparser.Insert:
+		add(v.Table)
+	case *sqlparser.DDL:
+		add(v.Table)
+	case *sqlparser.CreateTable:
+		add(v.DDL.NewName)
+	}
+
+	sqlparser.Walk(func(node sqlparser.SQLNode) (bool, error) {
+		if expr, ok := node.(*sqlparser.AliasedTableExpr); ok {
+			if table, ok := expr.Expr.(sqlparser.TableName); ok {
+				add(table)
+			}
+		}
+		return true, nil
+	}, stmt)
+	return tables
+}
@@ -0,0 +1,231 @@
+package sql
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/knocknote/vitess-sqlparser/sqlparser"
+)
+
+// UnparsedMode 语句无法解析时的处理方式
+type UnparsedMode int
+
+// 无法解析时的处理方式 定义
+const (
+	UnparsedAllow UnparsedMode = iota // 放行 不做其他检查
+	UnparsedDeny                      // 拒绝执行
+)
+
+// SafePolicy 安全策略 每条规则可单独开关
+type SafePolicy struct {
+	DenyStar             bool         // select 不允许 *
+	RequireInsertColumns bool         // insert 必须指定列名
+	RequireWhere         bool         // update/delete 必须有 where
+	DenyTautology        bool         // update/delete 的 where 不允许恒真, 如 1=1
+	MaxLimit             int          // select 必须有 limit 且不超过该值, 0 不检查; 无 from 的 select 和占位符 limit 不检查
+	DenyTables           []string     // 禁止访问的表
+	DenyColumns          []string     // 禁止访问的列
+	DenyDDL              bool         // 禁止 ddl, 如生产环境
+	DenyMultiStatement   bool         // 禁止一次执行多条语句
+	Unparsed             UnparsedMode // 语句无法解析时的处理方式
+}
+
+// DefaultSafePolicy 默认安全策略 select 不允许 *, insert 须有列名, update/delete 须有 where
+func DefaultSafePolicy() *SafePolicy {
+	return &SafePolicy{
+		DenyStar:             true,
+		RequireInsertColumns: true,
+		RequireWhere:         true,
+	}
+}
+
+// Middleware 检查不通过的语句 中断执行
+func (policy *SafePolicy) Middleware() Middleware {
+	return func(ctx context.Context, execSQL *ExecSQL, next Handler) (*ExecResult, error) {
+		if err := policy.Check(execSQL); err != nil {
+			return nil, err
+		}
+		return next(ctx, execSQL)
+	}
+}
+
+// Check 检查语句 execSQL.Parse 为空时会解析 Query
+func (policy *SafePolicy) Check(execSQL *ExecSQL) error {
+	if policy.DenyMultiStatement && countStatements(execSQL.Query) > 1 {
+		return fmt.Errorf("multi statement not allow")
+	}
+
+	if execSQL.Parse == nil && execSQL.ParseErr == nil {
+		execSQL.Parse, execSQL.ParseErr = Parse(execSQL.Query)
+	}
+	if execSQL.Parse == nil {
+		if policy.Unparsed == UnparsedDeny {
+			return fmt.Errorf("sql can not be parsed: %v", execSQL.ParseErr)
+		}
+		return nil
+	}
+
+	switch v := execSQL.Parse.(type) {
+	case *sqlparser.Select:
+		if err := policy.checkSelect(v); err != nil {
+			return err
+		}
+	case *sqlparser.Insert:
+		if policy.RequireInsertColumns && len(v.Columns) == 0 {
+			return fmt.Errorf("insert must have column name")
+		}
+	case *sqlparser.Update:
+		if err := policy.checkWhere("update", v.Where); err != nil {
+			return err
+		}
+	case *sqlparser.Delete:
+		if err := policy.checkWhere("delete", v.Where); err != nil {
+			return err
+		}
+	}
+
+	if policy.DenyDDL && StatementType(execSQL.Parse) == StatementDDL {
+		return fmt.Errorf("ddl not allow")
+	}
+	if err := policy.checkTables(execSQL.Parse); err != nil {
+		return err
+	}
+	return policy.checkColumns(execSQL.Parse)
+}
+
+func (policy *SafePolicy) checkSelect(v *sqlparser.Select) error {
+	if policy.DenyStar {
+		for _, expr := range v.SelectExprs {
+			if _, ok := expr.(*sqlparser.StarExpr); ok {
+				return fmt.Errorf("select must have column name, not allow *")
+			}
+		}
+	}
+
+	if policy.MaxLimit <= 0 || len(StatementTables(v)) == 0 {
+		return nil
+	}
+	if v.Limit == nil || v.Limit.Rowcount == nil {
+		return fmt.Errorf("select must have limit")
+	}
+	val, ok := v.Limit.Rowcount.(*sqlparser.SQLVal)
+	if !ok || val.Type != sqlparser.IntVal {
+		return nil
+	}
+	if limit, err := strconv.Atoi(string(val.Val)); err == nil && limit > policy.MaxLimit {
+		return fmt.Errorf("select limit %v exceeds %v", limit, policy.MaxLimit)
+	}
+	return nil
+}
+
+func (policy *SafePolicy) checkWhere(action string, where *sqlparser.Where) error {
+	if policy.RequireWhere && where == nil {
+		return fmt.Errorf("%v must have where", action)
+	}
+	if policy.DenyTautology && where != nil && isTautology(where.Expr) {
+		return fmt.Errorf("%v where is always true", action)
+	}
+	return nil
+}
+
+func (policy *SafePolicy) checkTables(stmt sqlparser.Statement) error {
+	if len(policy.DenyTables) == 0 {
+		return nil
+	}
+	for _, table := range StatementTables(stmt) {
+		for _, deny := range policy.DenyTables {
+			if strings.EqualFold(table, deny) {
+				return fmt.Errorf("table not allow: %v", table)
+			}
+		}
+	}
+	return nil
+}
+
+func (policy *SafePolicy) checkColumns(stmt sqlparser.Statement) error {
+	if len(policy.DenyColumns) == 0 {
+		return nil
+	}
+	check := func(column sqlparser.ColIdent) error {
+		for _, deny := range policy.DenyColumns {
+			if column.EqualString(deny) {
+				return fmt.Errorf("column not allow: %v", column.String())
+			}
+		}
+		return nil
+	}
+
+	if v, ok := stmt.(*sqlparser.Insert); ok {
+		for _, column := range v.Columns {
+			if err := check(column); err != nil {
+				return err
+			}
+		}
+	}
+	return sqlparser.Walk(func(node sqlparser.SQLNode) (bool, error) {
+		if v, ok := node.(*sqlparser.ColName); ok {
+			if err := check(v.Name); err != nil {
+				return false, err
+			}
+		}
+		return true, nil
+	}, stmt)
+}
+
+// isTautology 条件是否恒真 仅识别常见写法: 1, true, 1=1, 'a'='a', a=a, 以及它们的 and/or 组合
+func isTautology(expr sqlparser.Expr) bool {
+	switch v := expr.(type) {
+	case sqlparser.BoolVal:
+		return bool(v)
+	case *sqlparser.SQLVal:
+		return v.Type == sqlparser.IntVal && string(v.Val) != "0"
+	case *sqlparser.ParenExpr:
+		return isTautology(v.Expr)
+	case *sqlparser.OrExpr:
+		return isTautology(v.Left) || isTautology(v.Right)
+	case *sqlparser.AndExpr:
+		return isTautology(v.Left) && isTautology(v.Right)
+	case *sqlparser.ComparisonExpr:
+		if v.Operator != sqlparser.EqualStr && v.Operator != sqlparser.GreaterEqualStr && v.Operator != sqlparser.LessEqualStr {
+			return false
+		}
+		if val, ok := v.Left.(*sqlparser.SQLVal); ok && val.Type == sqlparser.ValArg {
+			return false
+		}
+		return sqlparser.String(v.Left) == sqlparser.String(v.Right)
+	}
+	return false
+}
+
+// countStatements 按引号外的分号 统计语句数量
+func countStatements(query string) int {
+	var quote rune
+	count := 0
+	hasContent := false
+	for _, c := range query {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+			hasContent = true
+		case c == ';':
+			if hasContent {
+				count++
+			}
+			hasContent = false
+			continue
+		}
+		if quote == 0 && c != ' ' && c != '\t' && c != '\n' && c != '\r' {
+			hasContent = true
+		}
+	}
+	if hasContent {
+		count++
+	}
+	return count
+}
@@ -0,0 +1,54 @@
+package sql
+
+import (
+	"testing"
+)
+
+func TestSafePolicyCheck(t *testing.T) {
+	strict := &SafePolicy{
+		DenyStar:             true,
+		RequireInsertColumns: true,
+		RequireWhere:         true,
+		DenyTautology:        true,
+		MaxLimit:             100,
+		DenyTables:           []string{"secret"},
+		DenyColumns:          []string{"password"},
+		DenyDDL:              true,
+		DenyMultiStatement:   true,
+		Unparsed:             UnparsedDeny,
+	}
+	tests := []struct {
+		name    string
+		policy  *SafePolicy
+		query   string
+		wantErr bool
+	}{
+		{"default star", DefaultSafePolicy(), "SELECT * FROM t", true},
+		{"default unparsed", DefaultSafePolicy(), "SELEC a FRM t", false},
+		{"default update no where", DefaultSafePolicy(), "UPDATE t SET a = 1", true},
+		{"default tautology", DefaultSafePolicy(), "UPDATE t SET a = 1 WHERE 1 = 1", false},
+		{"strict ok", strict, "SELECT a FROM t WHERE b = ? LIMIT 10", false},
+		{"strict unparsed", strict, "SELEC a FRM t", true},
+		{"default parser panic", DefaultSafePolicy(), "SHOW FULL PROCESSLIST", false},
+		{"strict parser panic", strict, "SHOW FULL PROCESSLIST", true},
+		{"strict no limit", strict, "SELECT a FROM t WHERE b = ?", true},
+		{"strict over limit", strict, "SELECT a FROM t LIMIT 1000", true},
+		{"strict no from", strict, "SELECT 1", false},
+		{"strict tautology", strict, "DELETE FROM t WHERE 1 = 1", true},
+		{"strict tautology or", strict, "UPDATE t SET a = 1 WHERE id = ? OR 'x' = 'x'", true},
+		{"strict where", strict, "UPDATE t SET a = 1 WHERE id = ?", false},
+		{"strict deny table", strict, "SELECT a FROM t JOIN secret ON t.id = secret.id LIMIT 1", true},
+		{"strict deny column", strict, "SELECT password FROM t LIMIT 1", true},
+		{"strict deny insert column", strict, "INSERT INTO t (a, password) VALUES (?, ?)", true},
+		{"strict ddl", strict, "DROP TABLE t", true},
+		{"strict multi", strict, "UPDATE t SET a = 1 WHERE id = 1; DROP TABLE t", true},
+		{"strict quoted semicolon", strict, "UPDATE t SET a = ';' WHERE id = 1;", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.policy.Check(&ExecSQL{Query: tt.query}); (err != nil) != tt.wantErr {
+				t.Errorf("Check() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -30,19 +30,19 @@ const (
 )
 
 type testData struct {
-	ID int            `json:"id,omitempty"`
-	A  sql.NullString `json:"a,omitempty"`
-	B  int            `json:"b,omitempty"`
-	C  int64          `json:"c,omitempty"`
-	D  time.Time      `json:"d,omitempty"`
-	E  time.Time      `json:"e,omitempty"`
-	F  float64        `json:"f,omitempty"`
-	G  float32        `json:"g,omitempty"`
-	H  sql.NullString `json:"h,omitempty"`
-	I  sql.NullString `json:"i,omitempty"`
-	J  time.Time      `json:"j,omitempty"`
-	K  sql.NullTime   `json:"k,omitempty"`
-	L  []byte         `json:"l,omitempty"`
+	ID int            `db:"id"`
+	A  sql.NullString `db:"a"`
+	B  int            `db:"b"`
+	C  int64          `db:"c"`
+	D  time.Time      `db:"d"`
+	E  time.Time      `db:"e"`
+	F  float64        `db:"f"`
+	G  float32        `db:"g"`
+	H  sql.NullString `db:"h"`
+	I  sql.NullString `db:"i"`
+	J  time.Time      `db:"j"`
+	K  sql.NullTime   `db:"k"`
+	L  []byte         `db:"l"`
 }
 
 func main() {
@@ -3,10 +3,7 @@ package sql
 import (
 	"database/sql"
 	"fmt"
-	"strings"
-
-	jsonplus "github.com/cheetah-fun-gs/goplus/encoding/json"
-	reflectplus "github.com/cheetah-fun-gs/goplus/reflect"
+	"reflect"
 )
 
 // RowsAffected ...
@@ -33,33 +30,14 @@ func LastInsertId(result sql.Result, err error) (int, error) {
 	return int(lastInsertID), nil
 }
 
-func scanOne(rows *sql.Rows, columns []string, fields map[string]interface{}) (map[string]interface{}, error) {
-	var dest []interface{}
-
-	for _, name := range columns {
-		val, ok := fields[name]
-		if !ok {
-			return nil, fmt.Errorf("column not in fields: %v", name)
-		}
-		dest = append(dest, val)
-	}
-
-	if err := rows.Scan(dest...); err != nil {
-		return nil, err
-	}
-
-	result := map[string]interface{}{}
-	for i := 0; i < len(columns); i++ {
-		result[columns[i]] = dest[i]
-	}
-	return result, nil
-}
-
-// Get v map[string]interface{} or struct
+// Get 读取第一行并关闭 rows, v 结构体/map/单列基础类型 的指针, 或非 nil 的 map
+// 结构体列名取 db tag, 其次 json tag, 否则为字段名
 func Get(rows *sql.Rows, v interface{}) error {
-	fields, ok := v.(map[string]interface{})
-	if !ok {
-		fields = reflectplus.Mock(v).DisableRecurse().Random().Pointer().Value().(map[string]interface{})
+	defer rows.Close()
+
+	dest := reflect.ValueOf(v)
+	if dest.Kind() != reflect.Ptr && dest.Kind() != reflect.Map || dest.IsNil() {
+		return fmt.Errorf("v must be a non-nil pointer or map")
 	}
 
 	columns, err := rows.Columns()
@@ -67,21 +45,29 @@ func Get(rows *sql.Rows, v interface{}) error {
 		return err
 	}
 
-	if rows.Next() {
-		data, err := scanOne(rows, columns, fields)
-		if err != nil {
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
 			return err
 		}
-		return jsonplus.Convert(data, v)
+		return sql.ErrNoRows
 	}
-	return sql.ErrNoRows
+	scanner := &rowScanner{columns: columns}
+	return scanner.scan(rows, dest)
 }
 
-// Select ...
+// Select 读取所有行并关闭 rows, v 切片的指针 元素为结构体/map/单列基础类型 或其指针
 func Select(rows *sql.Rows, v interface{}) error {
-	vv, ok := reflectplus.Mock(v).DisableRecurse().Random().Value().([]interface{})
-	if !ok {
-		return fmt.Errorf("v is not []interface{}")
+	defer rows.Close()
+
+	dest := reflect.ValueOf(v)
+	if dest.Kind() != reflect.Ptr || dest.IsNil() || dest.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("v must be a non-nil pointer of slice")
+	}
+	slice := dest.Elem()
+	itemType := slice.Type().Elem()
+	isPtr := itemType.Kind() == reflect.Ptr
+	if isPtr {
+		itemType = itemType.Elem()
 	}
 
 	columns, err := rows.Columns()
@@ -89,34 +75,25 @@ func Select(rows *sql.Rows, v interface{}) error {
 		return err
 	}
 
-	data := []map[string]interface{}{}
+	scanner := &rowScanner{columns: columns}
+	items := reflect.MakeSlice(slice.Type(), 0, 0)
 	for rows.Next() {
-		fields := reflectplus.Mock(vv[0]).DisableRecurse().Pointer().Value().(map[string]interface{})
-		result, err := scanOne(rows, columns, fields)
-		if err != nil {
+		item := reflect.New(itemType)
+		if itemType.Kind() == reflect.Map {
+			item.Elem().Set(reflect.MakeMap(itemType))
+		}
+		if err := scanner.scan(rows, item); err != nil {
 			return err
 		}
-		data = append(data, result)
-	}
-	return jsonplus.Convert(data, v)
-}
-
-// GenInsert 生成insert sql
-func GenInsert(tableName string, v interface{}) (string, []interface{}) {
-	fields, ok := v.(map[string]interface{})
-	if !ok {
-		fields = reflectplus.Mock(v).DisableRecurse().Value().(map[string]interface{})
+		if isPtr {
+			items = reflect.Append(items, item)
+		} else {
+			items = reflect.Append(items, item.Elem())
+		}
 	}
-
-	coloums := []string{}
-	args := []interface{}{}
-	marks := []string{}
-	for key, val := range fields {
-		coloums = append(coloums, key)
-		args = append(args, val)
-		marks = append(marks, "?")
+	if err := rows.Err(); err != nil {
+		return err
 	}
-
-	return fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s);", tableName,
-		strings.Join(coloums, ", "), strings.Join(marks, ", ")), args
+	slice.Set(items)
+	return nil
 }
@@ -0,0 +1,116 @@
+package sql
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type testBase struct {
+	ID        int       `db:"id"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+type testExtra struct {
+	Memo string `db:"memo"`
+}
+
+type testRow struct {
+	testBase
+	*testExtra
+	Name    sql.NullString `db:"name"`
+	Age     *int           `db:"age"`
+	Score   float64        `json:"score,omitempty"`
+	Ignored string         `db:"-"`
+	Raw     []byte
+	private int
+}
+
+func TestGetStructMap(t *testing.T) {
+	m := getStructMap(reflect.TypeOf(testRow{}))
+	got := []string{}
+	for _, f := range m.fields {
+		got = append(got, f.name)
+	}
+	want := []string{"id", "created_at", "memo", "name", "age", "score", "Raw"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("getStructMap() = %v, want %v", got, want)
+	}
+	if f, ok := m.lookup("RAW"); !ok || f.name != "Raw" {
+		t.Errorf("lookup() case insensitive failed")
+	}
+}
+
+func TestGenInsert(t *testing.T) {
+	age := 10
+	now := time.Now()
+	row := &testRow{
+		testBase: testBase{ID: 1, CreatedAt: now},
+		Age:      &age,
+	}
+
+	query, args := GenInsert("test", row)
+	wantQuery := "INSERT INTO test (id, created_at, name, age, score, Raw) VALUES (?, ?, ?, ?, ?, ?);"
+	if query != wantQuery {
+		t.Errorf("GenInsert() query = %v, want %v", query, wantQuery)
+	}
+	wantArgs := []interface{}{1, now, sql.NullString{}, &age, float64(0), []byte(nil)}
+	if !reflect.DeepEqual(args, wantArgs) {
+		t.Errorf("GenInsert() args = %v, want %v", args, wantArgs)
+	}
+}
+
+type testShadowA struct {
+	Tag string `db:"tag"`
+}
+
+type testShadowB struct {
+	Tag string `db:"tag"`
+}
+
+// 嵌入结构体在前 外层同名列仍优先; 同一深度的同名列都忽略
+type testShadowRow struct {
+	testBase
+	testShadowA
+	testShadowB
+	ID   int    `db:"id"`
+	Note string `db:"note"`
+}
+
+func TestGetStructMapShadow(t *testing.T) {
+	m := getStructMap(reflect.TypeOf(testShadowRow{}))
+	got := []string{}
+	for _, f := range m.fields {
+		got = append(got, f.name)
+	}
+	want := []string{"created_at", "id", "note"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("getStructMap() = %v, want %v", got, want)
+	}
+	if f, ok := m.lookup("id"); !ok || !reflect.DeepEqual(f.index, []int{3}) {
+		t.Errorf("lookup(id) should be outer field")
+	}
+	if _, ok := m.lookup("tag"); ok {
+		t.Errorf("lookup(tag) ambiguous column should be ignored")
+	}
+
+	now := time.Now()
+	row := &testShadowRow{testBase: testBase{ID: 1, CreatedAt: now}, ID: 2, Note: "n"}
+	query, args := GenInsert("t", row)
+	wantQuery := "INSERT INTO t (created_at, id, note) VALUES (?, ?, ?);"
+	if query != wantQuery {
+		t.Errorf("GenInsert() query = %v, want %v", query, wantQuery)
+	}
+	if wantArgs := []interface{}{now, 2, "n"}; !reflect.DeepEqual(args, wantArgs) {
+		t.Errorf("GenInsert() args = %v, want %v", args, wantArgs)
+	}
+
+	query, args, err := GenUpdate("t", row, "note")
+	if err != nil {
+		t.Fatalf("GenUpdate() error = %v", err)
+	}
+	if wantArgs := []interface{}{"n", 2}; !reflect.DeepEqual(args, wantArgs) {
+		t.Errorf("GenUpdate() query = %v, args = %v, want %v", query, args, wantArgs)
+	}
+}
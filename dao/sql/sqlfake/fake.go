@@ -0,0 +1,296 @@
+// Package sqlfake 内存中的 database/sql 假驱动 用于测试
+// 预先登记期望的语句及返回的行/结果/错误, 语句按 sqlparser 规范化后比较
+//
+//	db, fake := sqlfake.New()
+//	multisqldb.Register("test", db)
+//	fake.ExpectQuery("SELECT id, name FROM user WHERE id = ?").WithArgs(1).
+//		WillReturnRows(sqlfake.NewRows("id", "name").AddRow(1, "a"))
+//	...
+//	if err := fake.ExpectationsWereMet(); err != nil {
+//		t.Error(err)
+//	}
+package sqlfake
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	sqlplus "github.com/cheetah-fun-gs/goplus/dao/sql"
+	"github.com/knocknote/vitess-sqlparser/sqlparser"
+)
+
+// 期望类型 定义
+const (
+	kindQuery    = "query"
+	kindExec     = "exec"
+	kindBegin    = "begin"
+	kindCommit   = "commit"
+	kindRollback = "rollback"
+)
+
+// Normalize 规范化语句 能解析的按 sqlparser 重新输出, 否则合并空白并去掉末尾分号
+func Normalize(query string) string {
+	query = strings.TrimSuffix(strings.TrimSpace(query), ";")
+	if stmt, err := sqlplus.Parse(query); err == nil {
+		return sqlparser.String(stmt)
+	}
+	return strings.Join(strings.Fields(query), " ")
+}
+
+// Argument 自定义参数匹配
+type Argument interface {
+	Match(v driver.Value) bool
+}
+
+type anyArg struct{}
+
+func (anyArg) Match(v driver.Value) bool {
+	return true
+}
+
+// AnyArg 匹配任意参数
+func AnyArg() Argument {
+	return anyArg{}
+}
+
+// Rows 预设的返回行
+type Rows struct {
+	columns []string
+	values  [][]driver.Value
+	err     error // 遍历到末尾时返回的错误
+}
+
+// NewRows ...
+func NewRows(columns ...string) *Rows {
+	return &Rows{columns: columns}
+}
+
+// AddRow 追加一行 值的数量须与列数一致, 值按 driver 默认规则转换 如 int 转为 int64
+func (rows *Rows) AddRow(values ...interface{}) *Rows {
+	if len(values) != len(rows.columns) {
+		panic(fmt.Sprintf("sqlfake: row has %v values, want %v", len(values), len(rows.columns)))
+	}
+	row := make([]driver.Value, len(values))
+	for i, v := range values {
+		value, err := driver.DefaultParameterConverter.ConvertValue(v)
+		if err != nil {
+			panic(fmt.Sprintf("sqlfake: column %v: %v", rows.columns[i], err))
+		}
+		row[i] = value
+	}
+	rows.values = append(rows.values, row)
+	return rows
+}
+
+// RowError 遍历完所有行后 rows.Err() 返回 err
+func (rows *Rows) RowError(err error) *Rows {
+	rows.err = err
+	return rows
+}
+
+// Expectation 期望的调用
+type Expectation struct {
+	kind      string
+	query     string // 规范化后的语句
+	raw       string
+	args      []interface{}
+	checkArgs bool
+	rows      *Rows
+	result    driver.Result
+	err       error
+	times     int // 期望的次数
+	called    int
+}
+
+// WithArgs 期望的参数 不调用则不检查参数
+func (e *Expectation) WithArgs(args ...interface{}) *Expectation {
+	e.args, e.checkArgs = args, true
+	return e
+}
+
+// WillReturnRows 查询返回的行
+func (e *Expectation) WillReturnRows(rows *Rows) *Expectation {
+	e.rows = rows
+	return e
+}
+
+// WillReturnResult 执行返回的 lastInsertId 和影响行数
+func (e *Expectation) WillReturnResult(lastInsertID, rowsAffected int64) *Expectation {
+	e.result = result{lastInsertID: lastInsertID, rowsAffected: rowsAffected}
+	return e
+}
+
+// WillReturnError 返回错误
+func (e *Expectation) WillReturnError(err error) *Expectation {
+	e.err = err
+	return e
+}
+
+// Times 期望调用的次数 默认 1
+func (e *Expectation) Times(n int) *Expectation {
+	e.times = n
+	return e
+}
+
+func (e *Expectation) String() string {
+	if e.raw == "" {
+		return e.kind
+	}
+	if e.checkArgs {
+		return fmt.Sprintf("%v %q with args %v", e.kind, e.raw, e.args)
+	}
+	return fmt.Sprintf("%v %q", e.kind, e.raw)
+}
+
+func (e *Expectation) met() bool {
+	return e.called >= e.times
+}
+
+// match 语句和参数是否匹配 返回不匹配的原因
+func (e *Expectation) match(kind, query string, args []driver.NamedValue) error {
+	if e.kind != kind {
+		return fmt.Errorf("want %v", e)
+	}
+	if e.query != query {
+		return fmt.Errorf("want %v", e)
+	}
+	if !e.checkArgs {
+		return nil
+	}
+	if len(e.args) != len(args) {
+		return fmt.Errorf("%v: got %v args, want %v", e, len(args), len(e.args))
+	}
+	for i, want := range e.args {
+		if arg, ok := want.(Argument); ok {
+			if !arg.Match(args[i].Value) {
+				return fmt.Errorf("%v: arg %v %v not match", e, i, args[i].Value)
+			}
+			continue
+		}
+		value, err := driver.DefaultParameterConverter.ConvertValue(want)
+		if err != nil {
+			return fmt.Errorf("%v: arg %v: %v", e, i, err)
+		}
+		if !reflect.DeepEqual(value, args[i].Value) {
+			return fmt.Errorf("%v: arg %v is %#v, want %#v", e, i, args[i].Value, value)
+		}
+	}
+	return nil
+}
+
+// Fake 假数据库
+type Fake struct {
+	mutex        sync.Mutex
+	expectations []*Expectation
+	unordered    bool
+}
+
+// New 新建假数据库 返回的 *sql.DB 可直接用于 multisqldb.Register
+func New() (*sql.DB, *Fake) {
+	fake := &Fake{}
+	return sql.OpenDB(&connector{fake: fake}), fake
+}
+
+// MatchOrder 是否要求按登记顺序调用 默认 true
+func (fake *Fake) MatchOrder(ordered bool) {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+	fake.unordered = !ordered
+}
+
+func (fake *Fake) expect(kind, query string) *Expectation {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+	e := &Expectation{kind: kind, raw: query, times: 1}
+	if query != "" {
+		e.query = Normalize(query)
+	}
+	fake.expectations = append(fake.expectations, e)
+	return e
+}
+
+// ExpectQuery 期望的查询 Query/QueryRow/Get/Select
+func (fake *Fake) ExpectQuery(query string) *Expectation {
+	return fake.expect(kindQuery, query)
+}
+
+// ExpectExec 期望的执行 Exec/Insert/Update/Delete
+func (fake *Fake) ExpectExec(query string) *Expectation {
+	return fake.expect(kindExec, query)
+}
+
+// ExpectBegin 期望开启事务
+func (fake *Fake) ExpectBegin() *Expectation {
+	return fake.expect(kindBegin, "")
+}
+
+// ExpectCommit 期望提交事务
+func (fake *Fake) ExpectCommit() *Expectation {
+	return fake.expect(kindCommit, "")
+}
+
+// ExpectRollback 期望回滚事务
+func (fake *Fake) ExpectRollback() *Expectation {
+	return fake.expect(kindRollback, "")
+}
+
+// ExpectationsWereMet 所有期望是否都已被调用 返回未满足的期望
+func (fake *Fake) ExpectationsWereMet() error {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+	unmet := []string{}
+	for _, e := range fake.expectations {
+		if !e.met() {
+			unmet = append(unmet, fmt.Sprintf("%v (called %v/%v)", e, e.called, e.times))
+		}
+	}
+	if len(unmet) > 0 {
+		return fmt.Errorf("unmet expectations:\n  %v", strings.Join(unmet, "\n  "))
+	}
+	return nil
+}
+
+// call 找到匹配的期望并记录调用
+func (fake *Fake) call(kind, raw string, args []driver.NamedValue) (*Expectation, error) {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	query := ""
+	if raw != "" {
+		query = Normalize(raw)
+	}
+	var reason error
+	for _, e := range fake.expectations {
+		if e.met() {
+			continue
+		}
+		err := e.match(kind, query, args)
+		if err == nil {
+			e.called++
+			return e, e.err
+		}
+		if reason == nil {
+			reason = err
+		}
+		if !fake.unordered {
+			break
+		}
+	}
+
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+	got := kind
+	if raw != "" {
+		got = fmt.Sprintf("%v %q with args %v", kind, raw, values)
+	}
+	if reason == nil {
+		return nil, fmt.Errorf("sqlfake: unexpected %v, all expectations were met", got)
+	}
+	return nil, fmt.Errorf("sqlfake: unexpected %v, %v", got, reason)
+}
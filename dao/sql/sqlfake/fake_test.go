@@ -0,0 +1,92 @@
+package sqlfake
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	a := Normalize("SELECT  id, name\n FROM user WHERE id = ?;")
+	b := Normalize("select id, name from user where id = ?")
+	if a != b {
+		t.Errorf("Normalize() = %q, %q, want equal", a, b)
+	}
+	if got := Normalize("SHOW  FULL\tPROCESSLIST"); got != "SHOW FULL PROCESSLIST" {
+		t.Errorf("Normalize() = %q", got)
+	}
+}
+
+func TestFake(t *testing.T) {
+	db, fake := New()
+	defer db.Close()
+
+	fake.ExpectQuery("SELECT id, name FROM user WHERE id = ?").WithArgs(1).
+		WillReturnRows(NewRows("id", "name").AddRow(1, "a"))
+	fake.ExpectExec("UPDATE user SET name = ? WHERE id = ?").WithArgs("b", AnyArg()).WillReturnResult(0, 1)
+	fake.ExpectBegin()
+	fake.ExpectExec("DELETE FROM user WHERE id = ?").WillReturnError(errors.New("boom"))
+	fake.ExpectRollback()
+	fake.ExpectExec("INSERT INTO log (id) VALUES (?)")
+
+	var id int
+	var name string
+	if err := db.QueryRow("select id, name from user where id = ?", 1).Scan(&id, &name); err != nil || id != 1 || name != "a" {
+		t.Fatalf("QueryRow() = %v %v %v", id, name, err)
+	}
+	result, err := db.Exec("UPDATE user SET name = ? WHERE id = ?", "b", 1)
+	if err != nil {
+		t.Fatalf("Exec() error = %v", err)
+	}
+	if rows, _ := result.RowsAffected(); rows != 1 {
+		t.Errorf("RowsAffected() = %v, want 1", rows)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("Begin() error = %v", err)
+	}
+	if _, err := tx.Exec("DELETE FROM user WHERE id = ?", 1); err == nil || err.Error() != "boom" {
+		t.Errorf("Exec() error = %v, want boom", err)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Errorf("Rollback() error = %v", err)
+	}
+
+	// 顺序不符
+	if _, err := db.Exec("UPDATE user SET name = ? WHERE id = ?", "c", 2); err == nil {
+		t.Errorf("Exec() unexpected query want error")
+	}
+	if err := fake.ExpectationsWereMet(); err == nil || !strings.Contains(err.Error(), "INSERT INTO log") {
+		t.Errorf("ExpectationsWereMet() = %v, want unmet insert", err)
+	}
+	if _, err := db.Exec("INSERT INTO log (id) VALUES (?)", 1); err != nil {
+		t.Errorf("Exec() error = %v", err)
+	}
+	if err := fake.ExpectationsWereMet(); err != nil {
+		t.Errorf("ExpectationsWereMet() = %v", err)
+	}
+}
+
+func TestFakeArgs(t *testing.T) {
+	db, fake := New()
+	defer db.Close()
+	fake.MatchOrder(false)
+	fake.ExpectExec("UPDATE user SET name = ? WHERE id = ?").WithArgs("a", 1)
+	fake.ExpectExec("DELETE FROM user WHERE id = ?").WithArgs(2).Times(2)
+
+	if _, err := db.Exec("UPDATE user SET name = ? WHERE id = ?", "a", 2); err == nil || !strings.Contains(err.Error(), "arg 1") {
+		t.Errorf("Exec() wrong arg error = %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := db.Exec("DELETE FROM user WHERE id = ?", 2); err != nil {
+			t.Errorf("Exec() error = %v", err)
+		}
+	}
+	if _, err := db.Exec("UPDATE user SET name = ? WHERE id = ?", "a", 1); err != nil {
+		t.Errorf("Exec() error = %v", err)
+	}
+	if err := fake.ExpectationsWereMet(); err != nil {
+		t.Errorf("ExpectationsWereMet() = %v", err)
+	}
+}
@@ -0,0 +1,81 @@
+package sql
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// 时间戳 tag 选项 db:"name,created" / db:"name,updated" / db:"name,deleted"
+// 字段类型为 time.Time, *time.Time, sql.NullTime 或整数(unix 秒)
+// created: insert 时为零值则填充当前时间, update 默认不更新
+// updated: insert 时为零值则填充, update 时总是更新为当前时间
+// deleted: 软删除, 类型须为 *time.Time, sql.NullTime 或整数, 未删除为 NULL 或 0
+
+var (
+	now          = time.Now
+	nullTimeType = reflect.TypeOf(sql.NullTime{})
+)
+
+// timestampValue 按字段类型 返回时间 t 的值, 不支持的类型返回 false
+func timestampValue(typ reflect.Type, t time.Time) (interface{}, bool) {
+	switch typ {
+	case timeType:
+		return t, true
+	case reflect.PtrTo(timeType):
+		return &t, true
+	case nullTimeType:
+		return sql.NullTime{Time: t, Valid: true}, true
+	}
+	switch typ.Kind() {
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		return reflect.ValueOf(t.Unix()).Convert(typ).Interface(), true
+	case reflect.Uint, reflect.Uint32, reflect.Uint64:
+		return reflect.ValueOf(uint64(t.Unix())).Convert(typ).Interface(), true
+	}
+	return nil, false
+}
+
+// insertValue insert 时列的值 created/updated 为零值时填充时间 t
+func insertValue(v reflect.Value, m *structMap, f *structField, t time.Time) interface{} {
+	fv, ok := fieldValueByIndex(reflect.Indirect(v), f.index)
+	if !ok {
+		return nil
+	}
+	if (f == m.created || f == m.updated) && fv.IsZero() {
+		if val, ok := timestampValue(f.typ, t); ok {
+			return val
+		}
+	}
+	return fv.Interface()
+}
+
+// notDeleted 未软删除的条件
+func notDeleted(f *structField) (string, error) {
+	switch f.typ {
+	case reflect.PtrTo(timeType), nullTimeType:
+		return fmt.Sprintf("%s IS NULL", f.name), nil
+	}
+	switch f.typ.Kind() {
+	case reflect.Int, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		return fmt.Sprintf("%s = 0", f.name), nil
+	}
+	return "", fmt.Errorf("deleted column %v must be *time.Time, sql.NullTime or integer", f.name)
+}
+
+// SoftDeleteWhere 结构体未软删除的条件 如 deleted_at IS NULL, 没有 deleted 字段返回空
+func SoftDeleteWhere(v interface{}) (string, error) {
+	typ := reflect.TypeOf(v)
+	for typ != nil && typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ == nil || typ.Kind() != reflect.Struct {
+		return "", fmt.Errorf("v must be struct or pointer of struct")
+	}
+	m := getStructMap(typ)
+	if m.deleted == nil {
+		return "", nil
+	}
+	return notDeleted(m.deleted)
+}
@@ -0,0 +1,105 @@
+package sql
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type testArticle struct {
+	ID        int        `db:"id"`
+	Title     string     `db:"title"`
+	CreatedAt time.Time  `db:"created_at,created"`
+	UpdatedAt int64      `db:"updated_at,updated"`
+	DeletedAt *time.Time `db:"deleted_at,deleted"`
+}
+
+func TestTimestamp(t *testing.T) {
+	fixed := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	now = func() time.Time { return fixed }
+	defer func() { now = time.Now }()
+
+	article := &testArticle{ID: 1, Title: "a"}
+	query, args := GenInsert("article", article)
+	if want := "INSERT INTO article (id, title, created_at, updated_at, deleted_at) VALUES (?, ?, ?, ?, ?);"; query != want {
+		t.Errorf("GenInsert() = %v, want %v", query, want)
+	}
+	if !reflect.DeepEqual(args, []interface{}{1, "a", fixed, fixed.Unix(), (*time.Time)(nil)}) {
+		t.Errorf("GenInsert() args = %v", args)
+	}
+
+	query, args, err := GenUpdate("article", article)
+	if err != nil {
+		t.Fatalf("GenUpdate() error = %v", err)
+	}
+	if want := "UPDATE article SET title = ?, updated_at = ? WHERE id = ?;"; query != want || !reflect.DeepEqual(args, []interface{}{"a", fixed.Unix(), 1}) {
+		t.Errorf("GenUpdate() = %v %v", query, args)
+	}
+	query, _, _ = GenUpdate("article", article, "title")
+	if want := "UPDATE article SET title = ?, updated_at = ? WHERE id = ?;"; query != want {
+		t.Errorf("GenUpdate() mask = %v, want %v", query, want)
+	}
+
+	query, args, err = GenDelete("article", article)
+	if err != nil {
+		t.Fatalf("GenDelete() error = %v", err)
+	}
+	if want := "UPDATE article SET deleted_at = ? WHERE id = ? AND deleted_at IS NULL;"; query != want {
+		t.Errorf("GenDelete() = %v, want %v", query, want)
+	}
+	if deletedAt, ok := args[0].(*time.Time); !ok || !deletedAt.Equal(fixed) || args[1] != 1 {
+		t.Errorf("GenDelete() args = %v", args)
+	}
+	if query, _, _ = GenHardDelete("article", article); query != "DELETE FROM article WHERE id = ?;" {
+		t.Errorf("GenHardDelete() = %v", query)
+	}
+
+	query, _, _ = GenGet("article", article)
+	if want := "SELECT id, title, created_at, updated_at, deleted_at FROM article WHERE id = ? AND deleted_at IS NULL LIMIT 1;"; query != want {
+		t.Errorf("GenGet() = %v, want %v", query, want)
+	}
+	query, _, _ = GenGetWithDeleted("article", article)
+	if want := "SELECT id, title, created_at, updated_at, deleted_at FROM article WHERE id = ? LIMIT 1;"; query != want {
+		t.Errorf("GenGetWithDeleted() = %v, want %v", query, want)
+	}
+}
+
+func TestSelectBuilderModel(t *testing.T) {
+	tests := []struct {
+		name    string
+		builder *SelectBuilder
+		want    string
+	}{
+		{
+			name:    "no where",
+			builder: NewSelect().Model(&testArticle{}).From("article"),
+			want:    "SELECT id, title, created_at, updated_at, deleted_at FROM article WHERE (deleted_at IS NULL);",
+		},
+		{
+			name:    "one where",
+			builder: NewSelect("id").Model(testArticle{}).From("article").Where("id > ?", 1),
+			want:    "SELECT id FROM article WHERE (id > ?) AND (deleted_at IS NULL);",
+		},
+		{
+			name:    "or",
+			builder: NewSelect("id").Model(testArticle{}).From("article").Where("id > ?", 1).Or("title = ?", "a"),
+			want:    "SELECT id FROM article WHERE ((id > ?) OR (title = ?)) AND (deleted_at IS NULL);",
+		},
+		{
+			name:    "with deleted",
+			builder: NewSelect("id").Model(testArticle{}).WithDeleted().From("article"),
+			want:    "SELECT id FROM article;",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, _, err := tt.builder.Build()
+			if err != nil {
+				t.Fatalf("Build() error = %v", err)
+			}
+			if query != tt.want {
+				t.Errorf("Build() = %v, want %v", query, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package sql
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// ErrStaleVersion 乐观锁更新影响 0 行: 记录已被其他人修改或已删除
+var ErrStaleVersion = errors.New("stale version")
+
+// HasVersion 结构体是否有乐观锁 version 字段 tag 如 db:"version,version"
+func HasVersion(v interface{}) bool {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	return rv.Kind() == reflect.Struct && getStructMap(rv.Type()).version != nil
+}
+
+// IncrVersion 更新成功后 将 v 的 version 字段加 1 与数据库保持一致, v 须为结构体指针
+func IncrVersion(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("v must be a non-nil pointer of struct")
+	}
+	m := getStructMap(rv.Elem().Type())
+	if m.version == nil {
+		return fmt.Errorf("version not found in %v", rv.Elem().Type())
+	}
+
+	field := fieldByIndex(rv.Elem(), m.version.index)
+	switch field.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		field.SetInt(field.Int() + 1)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		field.SetUint(field.Uint() + 1)
+	default:
+		return fmt.Errorf("version must be integer: %v", field.Type())
+	}
+	return nil
+}
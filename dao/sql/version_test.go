@@ -0,0 +1,63 @@
+package sql
+
+import (
+	"reflect"
+	"testing"
+)
+
+type testVersioned struct {
+	UID     int    `db:"uid,pk"`
+	Name    string `db:"name"`
+	Version uint32 `db:"version,version"`
+}
+
+func TestGenUpdateVersion(t *testing.T) {
+	v := &testVersioned{UID: 1, Name: "a", Version: 3}
+	tests := []struct {
+		name      string
+		fields    []string
+		wantQuery string
+	}{
+		{name: "all", wantQuery: "UPDATE test SET name = ?, version = version + 1 WHERE uid = ? AND version = ?;"},
+		{name: "with version", fields: []string{"name", "version"}, wantQuery: "UPDATE test SET name = ?, version = version + 1 WHERE uid = ? AND version = ?;"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, args, err := GenUpdate("test", v, tt.fields...)
+			if err != nil {
+				t.Fatalf("GenUpdate() error = %v", err)
+			}
+			wantArgs := []interface{}{"a", 1, uint32(3)}
+			if query != tt.wantQuery || !reflect.DeepEqual(args, wantArgs) {
+				t.Errorf("GenUpdate() = %v %v, want %v %v", query, args, tt.wantQuery, wantArgs)
+			}
+		})
+	}
+}
+
+func TestIncrVersion(t *testing.T) {
+	v := &testVersioned{Version: 3}
+	if !HasVersion(v) || HasVersion(&testUser{}) {
+		t.Errorf("HasVersion() mismatch")
+	}
+	if err := IncrVersion(v); err != nil || v.Version != 4 {
+		t.Errorf("IncrVersion() = %v %v, want nil 4", err, v.Version)
+	}
+	if err := IncrVersion(testVersioned{}); err == nil {
+		t.Errorf("IncrVersion() non-pointer want error")
+	}
+	if err := IncrVersion(&testUser{}); err == nil {
+		t.Errorf("IncrVersion() without version want error")
+	}
+}
+
+func TestGenGet(t *testing.T) {
+	query, args, err := GenGet("test", &testVersioned{UID: 1})
+	if err != nil {
+		t.Fatalf("GenGet() error = %v", err)
+	}
+	wantQuery := "SELECT uid, name, version FROM test WHERE uid = ? LIMIT 1;"
+	if query != wantQuery || !reflect.DeepEqual(args, []interface{}{1}) {
+		t.Errorf("GenGet() = %v %v, want %v [1]", query, args, wantQuery)
+	}
+}
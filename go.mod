@@ -4,6 +4,7 @@ go 1.13
 
 require (
 	github.com/alecthomas/log4go v0.0.0-20180109082532-d146e6b86faa
+	github.com/alicebob/miniredis/v2 v2.17.0
 	github.com/fatih/structs v1.1.0
 	github.com/globalsign/mgo v0.0.0-20181015135952-eeefdecb41b8
 	github.com/go-sql-driver/mysql v1.5.0
@@ -13,6 +14,7 @@ require (
 	github.com/juju/loggo v0.0.0-20190526231331-6e530bcce5d8 // indirect
 	github.com/juju/testing v0.0.0-20191001232224-ce9dec17d28b // indirect
 	github.com/knocknote/vitess-sqlparser v0.0.0-20190712090058-385243f72d33
+	github.com/mattn/go-sqlite3 v1.14.5
 	github.com/nicksnyder/basen v1.0.0
 	github.com/spf13/viper v1.4.0
 	golang.org/x/text v0.3.2 // indirect
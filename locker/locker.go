@@ -2,6 +2,8 @@ package locker
 
 import (
 	"fmt"
+	"reflect"
+	"sync"
 	"time"
 
 	uuidplus "github.com/cheetah-fun-gs/goplus/uuid"
@@ -11,6 +13,19 @@ import (
 // ErrorLocked 错误: 已锁
 var ErrorLocked = fmt.Errorf("locked")
 
+// Backend 锁的存储后端 expire 单位毫秒, 须为可比较的类型(如指针) 同一后端的锁共用续期定时器
+type Backend interface {
+	Lock(name, nonce string, expire int) error   // 加锁 已被他人持有返回 ErrorLocked
+	Extend(name, nonce string, expire int) error // 续期 锁不存在时重新加锁, 被他人持有返回 ErrorLocked
+	Unlock(name, nonce string) error             // 解锁 仅删除 nonce 匹配的锁
+}
+
+// BatchBackend 支持批量续期的后端 返回每个锁各自的续期结果
+type BatchBackend interface {
+	Backend
+	ExtendBatch(names, nonces []string, expires []int) []error
+}
+
 // Lock 简单锁: 超时释放, 秒级, 无需解锁
 func Lock(conn redigo.Conn, name string, expire int) error {
 	if expire < 1 {
@@ -27,110 +42,110 @@ func Lock(conn redigo.Conn, name string, expire int) error {
 }
 
 const (
-	defaultInterval = 100  // 默认间隔 毫秒
-	deleteInterval  = 1000 // 执行删除锁操作的最小间隔 毫秒
+	defaultTTL = 3000 // 默认租期 毫秒
+	deleteTTL  = 2000 // 执行删除锁操作的最小租期 毫秒
 )
 
+// Options 守护锁参数 单位毫秒
+type Options struct {
+	TTL     int // 租期 默认 3000
+	Period  int // 续期间隔 必须小于 TTL, 默认 TTL 的 1/3
+	MaxHold int // 最长持有时间 超过后不再续期并解锁, 0 不限制
+}
+
 // Locker 守护锁: 需解锁, 进程退出自动解锁
 type Locker struct {
-	pool     *redigo.Pool
+	backend  Backend
 	name     string // 锁名称 唯一
 	nonce    string // 随机字符串
-	interval int    // 锁间隔
-	ticker   *time.Ticker
+	ttl      int    // 租期
+	period   int    // 续期间隔
+	deadline time.Time
+	mutex    sync.Mutex
 	isClose  bool // 是否已经关闭 在锁被覆盖的情况下会被标记
 }
 
-// New 获取一个守护锁
+// New 获取一个 redis 守护锁
+// intervals[0]: 续期间隔 毫秒, 租期为其2倍; 不传使用默认参数
 func New(pool *redigo.Pool, name string, intervals ...int) (*Locker, error) {
-	var interval int
-	if len(intervals) > 0 {
-		interval = intervals[0]
-	}
-	if interval <= 0 {
-		interval = defaultInterval
+	return NewWithBackend(NewRedisBackend(pool), name, intervals...)
+}
+
+// NewWithBackend 使用指定后端获取一个守护锁 intervals 同 New
+func NewWithBackend(backend Backend, name string, intervals ...int) (*Locker, error) {
+	opts := &Options{}
+	if len(intervals) > 0 && intervals[0] > 0 {
+		opts.TTL = 2 * intervals[0]
+		opts.Period = intervals[0]
 	}
+	return NewWithOptions(backend, name, opts)
+}
 
-	ticker := time.NewTicker(time.Duration(interval) * time.Millisecond)
+// NewWithOptions 使用指定后端和参数获取一个守护锁
+func NewWithOptions(backend Backend, name string, opts *Options) (*Locker, error) {
+	ttl, period := defaultTTL, 0
+	if opts != nil && opts.TTL > 0 {
+		ttl = opts.TTL
+	}
+	if opts != nil && opts.Period > 0 {
+		period = opts.Period
+	}
+	if period == 0 {
+		period = ttl / 3
+	}
+	if period <= 0 || period >= ttl {
+		return nil, fmt.Errorf("period must be positive and less than ttl")
+	}
+	if backend == nil || !reflect.TypeOf(backend).Comparable() {
+		return nil, fmt.Errorf("backend must be comparable: %T", backend)
+	}
 
 	locker := &Locker{
-		pool:     pool,
-		name:     name,
-		nonce:    uuidplus.NewV4().Base62(),
-		interval: interval,
-		ticker:   ticker,
+		backend: backend,
+		name:    name,
+		nonce:   uuidplus.NewV4().Base62(),
+		ttl:     ttl,
+		period:  period,
 	}
-	err := locker.lock()
-	if err != nil {
-		ticker.Stop()
-		return nil, err
+	if opts != nil && opts.MaxHold > 0 {
+		locker.deadline = time.Now().Add(time.Duration(opts.MaxHold) * time.Millisecond)
 	}
 
-	go func() {
-		for {
-			<-locker.ticker.C
-			err := locker.extend()
-			if err != nil {
-				locker.ticker.Stop()
-				locker.isClose = true // 锁出错了 被覆盖了 标记已关闭
-			}
-		}
-	}()
-
+	if err := locker.backend.Lock(locker.name, locker.nonce, locker.ttl); err != nil {
+		return nil, err
+	}
+	addRenew(locker)
 	return locker, nil
 }
 
-// Close 守护锁解锁
-func (locker *Locker) Close() {
-	if !locker.isClose {
-		locker.ticker.Stop()
-		if locker.interval > deleteInterval { // 间隔太长需要解锁
-			conn := locker.pool.Get()
-			defer conn.Close()
+// IsClose 是否已关闭 锁被覆盖或超过最长持有时间也会被标记
+func (locker *Locker) IsClose() bool {
+	locker.mutex.Lock()
+	defer locker.mutex.Unlock()
+	return locker.isClose
+}
 
-			conn.Do("DEL", locker.name)
-		}
-		locker.isClose = true // 标记已关闭
+// 标记关闭 返回是否由本次标记
+func (locker *Locker) markClose() bool {
+	locker.mutex.Lock()
+	defer locker.mutex.Unlock()
+	if locker.isClose {
+		return false
 	}
+	locker.isClose = true
+	return true
 }
 
-func (locker *Locker) lock() error {
-	conn := locker.pool.Get()
-	defer conn.Close()
-
-	ok, err := redigo.String(conn.Do("SET", locker.name, locker.nonce, "PX", 2*locker.interval, "NX"))
-	if err != nil && err != redigo.ErrNil {
-		return err
-	}
-	if err == redigo.ErrNil || ok != "OK" {
-		return ErrorLocked
-	}
-	return nil
+// Close 守护锁解锁
+func (locker *Locker) Close() {
+	locker.close(locker.ttl > deleteTTL) // 租期太长需要解锁
 }
 
-func (locker *Locker) extend() error {
-	// 脚本统一返回 OK 成功; nil 失败
-	scriptContext := `local v = redis.call("GET", KEYS[1])
-	if (v == nil or (type(v) == 'boolean' and v == false))
-	then
-		return redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[2], "NX")
-	elseif v == ARGV[1]
-	then
-		redis.call("PEXPIRE", KEYS[1], ARGV[2])
-		return "OK"
-	else
-		return nil
-	end`
-	conn := locker.pool.Get()
-	defer conn.Close()
-
-	script := redigo.NewScript(1, scriptContext)
-	ok, err := redigo.String(script.Do(conn, locker.name, locker.nonce, 2*locker.interval))
-	if err != nil && err != redigo.ErrNil {
-		return err
-	}
-	if err == redigo.ErrNil || ok != "OK" {
-		return ErrorLocked
+func (locker *Locker) close(isUnlock bool) {
+	if locker.markClose() {
+		removeRenew(locker)
+		if isUnlock {
+			locker.backend.Unlock(locker.name, locker.nonce)
+		}
 	}
-	return nil
 }
@@ -0,0 +1,98 @@
+package locker
+
+import (
+	"testing"
+	"time"
+
+	redigo "github.com/gomodule/redigo/redis"
+)
+
+func TestNewWithBackend(t *testing.T) {
+	backend := NewMemoryBackend()
+
+	l1, err := NewWithBackend(backend, "test", 2000)
+	if err != nil {
+		t.Fatalf("NewWithBackend() error = %v", err)
+	}
+
+	if _, err := NewWithBackend(backend, "test", 2000); err != ErrorLocked {
+		t.Errorf("NewWithBackend() error = %v, want %v", err, ErrorLocked)
+	}
+
+	l1.Close()
+
+	l2, err := NewWithBackend(backend, "test", 2000)
+	if err != nil {
+		t.Fatalf("NewWithBackend() after Close error = %v", err)
+	}
+	l2.Close()
+}
+
+func TestNewWithOptions(t *testing.T) {
+	backend := NewMemoryBackend()
+
+	if _, err := NewWithOptions(backend, "invalid", &Options{TTL: 100, Period: 100}); err == nil {
+		t.Errorf("NewWithOptions() period >= ttl want error")
+	}
+
+	l, err := NewWithOptions(backend, "test", &Options{TTL: 300, Period: 50, MaxHold: 120})
+	if err != nil {
+		t.Fatalf("NewWithOptions() error = %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if l.IsClose() {
+		t.Errorf("IsClose() = true before MaxHold")
+	}
+	time.Sleep(200 * time.Millisecond)
+	if !l.IsClose() {
+		t.Errorf("IsClose() = false after MaxHold")
+	}
+	if _, err := NewWithOptions(backend, "test", &Options{TTL: 300}); err != nil {
+		t.Errorf("NewWithOptions() after MaxHold error = %v", err)
+	}
+}
+
+func TestNewRedisBackend(t *testing.T) {
+	p1, p2 := &redigo.Pool{}, &redigo.Pool{}
+	if NewRedisBackend(p1) != NewRedisBackend(p1) {
+		t.Errorf("NewRedisBackend() same pool returns different backends")
+	}
+	if NewRedisBackend(p1) == NewRedisBackend(p2) {
+		t.Errorf("NewRedisBackend() different pools return same backend")
+	}
+}
+
+func TestSharedRenewer(t *testing.T) {
+	backend := NewMemoryBackend()
+	l1, err := NewWithBackend(backend, "shared1", 1000)
+	if err != nil {
+		t.Fatalf("NewWithBackend() error = %v", err)
+	}
+	defer l1.Close()
+	l2, err := NewWithBackend(backend, "shared2", 1000)
+	if err != nil {
+		t.Fatalf("NewWithBackend() error = %v", err)
+	}
+	defer l2.Close()
+
+	renewMutex.Lock()
+	r := renewers[renewKey{backend: backend, period: 1000}]
+	n := len(r.lockers)
+	renewMutex.Unlock()
+	if n != 2 {
+		t.Errorf("renewer lockers = %v, want 2", n)
+	}
+}
+
+// sliceBackend 不可比较的后端
+type sliceBackend []string
+
+func (sliceBackend) Lock(name, nonce string, expire int) error   { return nil }
+func (sliceBackend) Extend(name, nonce string, expire int) error { return nil }
+func (sliceBackend) Unlock(name, nonce string) error             { return nil }
+
+func TestNewWithOptionsNotComparable(t *testing.T) {
+	if _, err := NewWithOptions(sliceBackend{}, "test", nil); err == nil {
+		t.Errorf("NewWithOptions() not comparable backend want error")
+	}
+}
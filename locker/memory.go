@@ -0,0 +1,76 @@
+package locker
+
+import (
+	"sync"
+	"time"
+)
+
+type memoryItem struct {
+	nonce    string
+	deadline time.Time
+}
+
+// MemoryBackend 内存后端 仅进程内有效
+type MemoryBackend struct {
+	mutex sync.Mutex
+	items map[string]*memoryItem
+}
+
+// NewMemoryBackend ...
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{
+		items: map[string]*memoryItem{},
+	}
+}
+
+// Lock ...
+func (backend *MemoryBackend) Lock(name, nonce string, expire int) error {
+	backend.mutex.Lock()
+	defer backend.mutex.Unlock()
+
+	now := time.Now()
+	if item, ok := backend.items[name]; ok && item.deadline.After(now) {
+		return ErrorLocked
+	}
+	backend.items[name] = &memoryItem{
+		nonce:    nonce,
+		deadline: now.Add(time.Duration(expire) * time.Millisecond),
+	}
+	return nil
+}
+
+// Extend ...
+func (backend *MemoryBackend) Extend(name, nonce string, expire int) error {
+	backend.mutex.Lock()
+	defer backend.mutex.Unlock()
+
+	now := time.Now()
+	if item, ok := backend.items[name]; ok && item.deadline.After(now) && item.nonce != nonce {
+		return ErrorLocked
+	}
+	backend.items[name] = &memoryItem{
+		nonce:    nonce,
+		deadline: now.Add(time.Duration(expire) * time.Millisecond),
+	}
+	return nil
+}
+
+// ExtendBatch ...
+func (backend *MemoryBackend) ExtendBatch(names, nonces []string, expires []int) []error {
+	errs := make([]error, len(names))
+	for i := range names {
+		errs[i] = backend.Extend(names[i], nonces[i], expires[i])
+	}
+	return errs
+}
+
+// Unlock ...
+func (backend *MemoryBackend) Unlock(name, nonce string) error {
+	backend.mutex.Lock()
+	defer backend.mutex.Unlock()
+
+	if item, ok := backend.items[name]; ok && item.nonce == nonce {
+		delete(backend.items, name)
+	}
+	return nil
+}
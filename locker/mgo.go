@@ -0,0 +1,91 @@
+package locker
+
+import (
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+)
+
+// MgoBackend mongo 后端 每个锁一个文档, _id 为锁名称
+type MgoBackend struct {
+	db         *mgo.Database
+	collection string
+}
+
+// NewMgoBackend ...
+func NewMgoBackend(db *mgo.Database, collection string) *MgoBackend {
+	return &MgoBackend{
+		db:         db,
+		collection: collection,
+	}
+}
+
+// EnsureIndex 创建 TTL 索引 由 mongo 定期清理过期文档
+func (backend *MgoBackend) EnsureIndex() error {
+	session := backend.db.Session.Clone()
+	defer session.Close()
+
+	return backend.db.With(session).C(backend.collection).EnsureIndex(mgo.Index{
+		Key:         []string{"deadline"},
+		ExpireAfter: time.Second,
+	})
+}
+
+// Lock ...
+func (backend *MgoBackend) Lock(name, nonce string, expire int) error {
+	session := backend.db.Session.Clone()
+	defer session.Close()
+
+	c := backend.db.With(session).C(backend.collection)
+	now := time.Now()
+
+	// 清理已过期的锁
+	err := c.Remove(bson.M{"_id": name, "deadline": bson.M{"$lt": now}})
+	if err != nil && err != mgo.ErrNotFound {
+		return err
+	}
+
+	err = c.Insert(bson.M{
+		"_id":      name,
+		"nonce":    nonce,
+		"deadline": now.Add(time.Duration(expire) * time.Millisecond),
+	})
+	if err != nil && !mgo.IsDup(err) {
+		return err
+	}
+	if mgo.IsDup(err) {
+		return ErrorLocked
+	}
+	return nil
+}
+
+// Extend ...
+func (backend *MgoBackend) Extend(name, nonce string, expire int) error {
+	session := backend.db.Session.Clone()
+	defer session.Close()
+
+	c := backend.db.With(session).C(backend.collection)
+	deadline := time.Now().Add(time.Duration(expire) * time.Millisecond)
+
+	err := c.Update(bson.M{"_id": name, "nonce": nonce}, bson.M{"$set": bson.M{"deadline": deadline}})
+	if err != nil && err != mgo.ErrNotFound {
+		return err
+	}
+	if err == mgo.ErrNotFound { // 锁不存在 重新加锁
+		return backend.Lock(name, nonce, expire)
+	}
+	return nil
+}
+
+// Unlock ...
+func (backend *MgoBackend) Unlock(name, nonce string) error {
+	session := backend.db.Session.Clone()
+	defer session.Close()
+
+	err := backend.db.With(session).C(backend.collection).Remove(bson.M{"_id": name, "nonce": nonce})
+	if err != nil && err != mgo.ErrNotFound {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package locker
+
+import (
+	"sync"
+
+	redigoplus "github.com/cheetah-fun-gs/goplus/dao/redigo"
+	redigo "github.com/gomodule/redigo/redis"
+)
+
+const extendBatchSize = 500 // 批量续期 单次脚本的最大锁数量
+
+// 批量续期 ARGV 依次为每个锁的 nonce 和 expire, 返回每个锁的结果 1 成功 0 失败
+var extendBatchScript = redigoplus.NewScript(-1, `local r = {}
+	for i = 1, #KEYS do
+		local nonce = ARGV[2*i-1]
+		local expire = ARGV[2*i]
+		local v = redis.call("GET", KEYS[i])
+		if (v == nil or (type(v) == 'boolean' and v == false))
+		then
+			if redis.call("SET", KEYS[i], nonce, "PX", expire, "NX") then
+				r[i] = 1
+			else
+				r[i] = 0
+			end
+		elseif v == nonce
+		then
+			redis.call("PEXPIRE", KEYS[i], expire)
+			r[i] = 1
+		else
+			r[i] = 0
+		end
+	end
+	return r`)
+
+// 续期 锁不存在时重新加锁, 返回 OK 成功; nil 失败
+var extendScript = redigoplus.NewScript(1, `local v = redis.call("GET", KEYS[1])
+	if (v == nil or (type(v) == 'boolean' and v == false))
+	then
+		return redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[2], "NX")
+	elseif v == ARGV[1]
+	then
+		redis.call("PEXPIRE", KEYS[1], ARGV[2])
+		return "OK"
+	else
+		return nil
+	end`)
+
+// 解锁 仅删除 nonce 匹配的锁
+var unlockScript = redigoplus.NewScript(1, `if redis.call("GET", KEYS[1]) == ARGV[1]
+	then
+		return redis.call("DEL", KEYS[1])
+	else
+		return 0
+	end`)
+
+// RedisBackend redis 后端
+type RedisBackend struct {
+	pool *redigo.Pool
+}
+
+var redisBackends sync.Map // *redigo.Pool -> *RedisBackend
+
+// NewRedisBackend 同一连接池返回同一后端 使其上的锁共用续期定时器
+func NewRedisBackend(pool *redigo.Pool) *RedisBackend {
+	backend, _ := redisBackends.LoadOrStore(pool, &RedisBackend{pool: pool})
+	return backend.(*RedisBackend)
+}
+
+// Lock ...
+func (backend *RedisBackend) Lock(name, nonce string, expire int) error {
+	conn := backend.pool.Get()
+	defer conn.Close()
+
+	ok, err := redigo.String(conn.Do("SET", name, nonce, "PX", expire, "NX"))
+	if err != nil && err != redigo.ErrNil {
+		return err
+	}
+	if err == redigo.ErrNil || ok != "OK" {
+		return ErrorLocked
+	}
+	return nil
+}
+
+// Extend ...
+func (backend *RedisBackend) Extend(name, nonce string, expire int) error {
+	conn := backend.pool.Get()
+	defer conn.Close()
+
+	var ok string
+	found, err := extendScript.Do(conn, name, nonce, expire).Value(&ok)
+	if err != nil {
+		return err
+	}
+	if !found || ok != "OK" {
+		return ErrorLocked
+	}
+	return nil
+}
+
+// Unlock ...
+func (backend *RedisBackend) Unlock(name, nonce string) error {
+	conn := backend.pool.Get()
+	defer conn.Close()
+
+	_, err := unlockScript.Do(conn, name, nonce).Reply()
+	return err
+}
+
+// ExtendBatch 批量续期 按 extendBatchSize 分片后 pipeline 发送
+func (backend *RedisBackend) ExtendBatch(names, nonces []string, expires []int) []error {
+	errs := make([]error, len(names))
+
+	conn := backend.pool.Get()
+	defer conn.Close()
+
+	p := redigoplus.NewPipeline(conn)
+	futures := []*redigoplus.Future{}
+	for start := 0; start < len(names); start += extendBatchSize {
+		end := start + extendBatchSize
+		if end > len(names) {
+			end = len(names)
+		}
+		args := []interface{}{end - start}
+		for i := start; i < end; i++ {
+			args = append(args, names[i])
+		}
+		for i := start; i < end; i++ {
+			args = append(args, nonces[i], expires[i])
+		}
+		futures = append(futures, p.Script(extendBatchScript, args...))
+	}
+	p.Exec() // 错误记录在各 Future 中
+
+	for chunk, f := range futures {
+		start := chunk * extendBatchSize
+		results, err := redigo.Ints(f.Reply(), f.Err())
+		for i := start; i < start+extendBatchSize && i < len(names); i++ {
+			if err != nil {
+				errs[i] = err
+			} else if i-start >= len(results) || results[i-start] != 1 {
+				errs[i] = ErrorLocked
+			}
+		}
+	}
+	return errs
+}
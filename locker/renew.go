@@ -0,0 +1,116 @@
+package locker
+
+import (
+	"sync"
+	"time"
+)
+
+// 同一后端 同一续期间隔的锁 共用一个定时器批量续期
+type renewKey struct {
+	backend Backend
+	period  int
+}
+
+type renewer struct {
+	key     renewKey
+	ticker  *time.Ticker
+	stop    chan struct{}
+	lockers map[*Locker]bool
+}
+
+var (
+	renewMutex sync.Mutex
+	renewers   = map[renewKey]*renewer{}
+)
+
+func addRenew(locker *Locker) {
+	renewMutex.Lock()
+	defer renewMutex.Unlock()
+
+	key := renewKey{backend: locker.backend, period: locker.period}
+	r, ok := renewers[key]
+	if !ok {
+		r = &renewer{
+			key:     key,
+			ticker:  time.NewTicker(time.Duration(key.period) * time.Millisecond),
+			stop:    make(chan struct{}),
+			lockers: map[*Locker]bool{},
+		}
+		renewers[key] = r
+		go r.run()
+	}
+	r.lockers[locker] = true
+}
+
+func removeRenew(locker *Locker) {
+	renewMutex.Lock()
+	defer renewMutex.Unlock()
+
+	key := renewKey{backend: locker.backend, period: locker.period}
+	r, ok := renewers[key]
+	if !ok {
+		return
+	}
+	delete(r.lockers, locker)
+	if len(r.lockers) == 0 {
+		r.ticker.Stop()
+		close(r.stop)
+		delete(renewers, key)
+	}
+}
+
+func (r *renewer) run() {
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-r.ticker.C:
+			r.renew()
+		}
+	}
+}
+
+func (r *renewer) renew() {
+	renewMutex.Lock()
+	lockers := []*Locker{}
+	for locker := range r.lockers {
+		lockers = append(lockers, locker)
+	}
+	renewMutex.Unlock()
+
+	// 超过最长持有时间的锁 直接解锁
+	now := time.Now()
+	names, nonces, expires := []string{}, []string{}, []int{}
+	holding := []*Locker{}
+	for _, locker := range lockers {
+		if locker.IsClose() {
+			continue
+		}
+		if !locker.deadline.IsZero() && now.After(locker.deadline) {
+			locker.close(true)
+			continue
+		}
+		names = append(names, locker.name)
+		nonces = append(nonces, locker.nonce)
+		expires = append(expires, locker.ttl)
+		holding = append(holding, locker)
+	}
+	if len(holding) == 0 {
+		return
+	}
+
+	var errs []error
+	if backend, ok := r.key.backend.(BatchBackend); ok {
+		errs = backend.ExtendBatch(names, nonces, expires)
+	} else {
+		for i := range holding {
+			errs = append(errs, r.key.backend.Extend(names[i], nonces[i], expires[i]))
+		}
+	}
+
+	for i, locker := range holding {
+		if errs[i] != nil { // 锁出错了 被覆盖了 标记已关闭
+			locker.close(false)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package locker
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	sqlplus "github.com/cheetah-fun-gs/goplus/dao/sql"
+)
+
+const sqlTableCreateSQL = `CREATE TABLE IF NOT EXISTS %v (
+	name varchar(191) NOT NULL,
+	nonce varchar(64) NOT NULL,
+	deadline bigint(20) NOT NULL,
+	PRIMARY KEY (name)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='locker'`
+
+// SQLBackend sql 后端 每个锁一行 deadline 为毫秒时间戳, 过期行在加锁时清理
+type SQLBackend struct {
+	db        *sql.DB
+	tableName string
+}
+
+// NewSQLBackend ...
+func NewSQLBackend(db *sql.DB, tableName string) *SQLBackend {
+	return &SQLBackend{
+		db:        db,
+		tableName: tableName,
+	}
+}
+
+// CreateTable 建表 mysql 语法
+func (backend *SQLBackend) CreateTable() error {
+	_, err := backend.db.Exec(fmt.Sprintf(sqlTableCreateSQL, backend.tableName))
+	return err
+}
+
+func sqlDeadline(expire int) int64 {
+	return time.Now().Add(time.Duration(expire)*time.Millisecond).UnixNano() / int64(time.Millisecond)
+}
+
+func sqlNow() int64 {
+	return time.Now().UnixNano() / int64(time.Millisecond)
+}
+
+// Lock ...
+func (backend *SQLBackend) Lock(name, nonce string, expire int) error {
+	// 清理已过期的锁
+	query := fmt.Sprintf("DELETE FROM %v WHERE name = ? AND deadline < ?;", backend.tableName)
+	if _, err := backend.db.Exec(query, name, sqlNow()); err != nil {
+		return err
+	}
+
+	query = fmt.Sprintf("INSERT INTO %v (name, nonce, deadline) VALUES (?, ?, ?);", backend.tableName)
+	_, err := backend.db.Exec(query, name, nonce, sqlDeadline(expire))
+	if err == nil {
+		return nil
+	}
+
+	// 插入失败 区分主键冲突和其他异常
+	var holder string
+	query = fmt.Sprintf("SELECT nonce FROM %v WHERE name = ?;", backend.tableName)
+	if errQuery := backend.db.QueryRow(query, name).Scan(&holder); errQuery != nil {
+		return err
+	}
+	if holder == nonce {
+		return nil
+	}
+	return ErrorLocked
+}
+
+// Extend ...
+func (backend *SQLBackend) Extend(name, nonce string, expire int) error {
+	query := fmt.Sprintf("UPDATE %v SET deadline = ? WHERE name = ? AND nonce = ?;", backend.tableName)
+	rowsAffected, err := sqlplus.RowsAffected(backend.db.Exec(query, sqlDeadline(expire), name, nonce))
+	if err != nil {
+		return err
+	}
+	if rowsAffected > 0 {
+		return nil
+	}
+	// 锁不存在 重新加锁
+	return backend.Lock(name, nonce, expire)
+}
+
+// Unlock ...
+func (backend *SQLBackend) Unlock(name, nonce string) error {
+	query := fmt.Sprintf("DELETE FROM %v WHERE name = ? AND nonce = ?;", backend.tableName)
+	_, err := backend.db.Exec(query, name, nonce)
+	return err
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"sync"
 
+	redigoplus "github.com/cheetah-fun-gs/goplus/dao/redigo"
+	mlogger "github.com/cheetah-fun-gs/goplus/multier/multilogger"
 	redigo "github.com/gomodule/redigo/redis"
 )
 
@@ -14,28 +16,66 @@ const (
 type mutilPool map[string]*redigo.Pool
 
 var (
-	once  sync.Once
-	mutil mutilPool
+	once       sync.Once
+	mutil      mutilPool
+	preloading sync.WaitGroup // 进行中的预加载
 )
 
-// Init 初始化
+// Options 注册参数
+type Options struct {
+	NoPreload bool   // 不预加载脚本
+	MLogName  string // 预加载失败时输出警告的日志器 默认 default
+}
+
+// Init 初始化 同 Register 会预加载脚本
 func Init(defaultPool *redigo.Pool) {
+	InitWithOptions(defaultPool, nil)
+}
+
+// InitWithOptions 初始化 opts 同 RegisterWithOptions
+func InitWithOptions(defaultPool *redigo.Pool, opts *Options) {
 	once.Do(func() {
 		mutil = mutilPool{
 			d: defaultPool,
 		}
+		preload(defaultPool, opts)
 	})
 }
 
-// Register 注册连接池
+// Register 注册连接池 并在后台预加载 redigoplus.NewScript 声明的脚本
 func Register(name string, pool *redigo.Pool) error {
+	return RegisterWithOptions(name, pool, nil)
+}
+
+// RegisterWithOptions 注册连接池 opts 为 nil 时同 Register
+func RegisterWithOptions(name string, pool *redigo.Pool, opts *Options) error {
 	if _, ok := mutil[name]; ok {
 		return fmt.Errorf("duplicate name: %v", name)
 	}
 	mutil[name] = pool
+	preload(pool, opts)
 	return nil
 }
 
+// preload 在后台预加载脚本 不阻塞注册; 失败只输出警告, 执行时脚本不存在会回退 EVAL
+func preload(pool *redigo.Pool, opts *Options) {
+	if pool == nil || opts != nil && opts.NoPreload {
+		return
+	}
+	mlogName := "default"
+	if opts != nil && opts.MLogName != "" {
+		mlogName = opts.MLogName
+	}
+
+	preloading.Add(1)
+	go func() {
+		defer preloading.Done()
+		if err := redigoplus.PreloadScripts(pool); err != nil {
+			mlogger.WarnN(mlogName, "preload redis scripts error: %v", err)
+		}
+	}()
+}
+
 // Retrieve 获取 *redigo.Pool
 func Retrieve() *redigo.Pool {
 	return mutil[d]
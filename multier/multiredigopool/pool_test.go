@@ -0,0 +1,123 @@
+package multiredigopool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	mlogger "github.com/cheetah-fun-gs/goplus/multier/multilogger"
+	redigo "github.com/gomodule/redigo/redis"
+)
+
+// fakeConn 记录发送的命令 每条命令都返回 OK
+type fakeConn struct {
+	commands [][]interface{}
+	pending  int
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Err() error {
+	return nil
+}
+
+func (c *fakeConn) Do(commandName string, args ...interface{}) (interface{}, error) {
+	if commandName != "" {
+		c.Send(commandName, args...)
+	}
+	c.pending = 0
+	return "OK", nil
+}
+
+func (c *fakeConn) Send(commandName string, args ...interface{}) error {
+	c.commands = append(c.commands, append([]interface{}{commandName}, args...))
+	c.pending++
+	return nil
+}
+
+func (c *fakeConn) Flush() error {
+	return nil
+}
+
+func (c *fakeConn) Receive() (interface{}, error) {
+	c.pending--
+	return "OK", nil
+}
+
+// testLogger 记录警告日志
+type testLogger struct {
+	warns []string
+}
+
+func (l *testLogger) Debug(format string, v ...interface{}) {}
+func (l *testLogger) Info(format string, v ...interface{})  {}
+func (l *testLogger) Warn(format string, v ...interface{}) {
+	l.warns = append(l.warns, fmt.Sprintf(format, v...))
+}
+func (l *testLogger) Error(format string, v ...interface{})                       {}
+func (l *testLogger) Debugc(ctx context.Context, format string, v ...interface{}) {}
+func (l *testLogger) Infoc(ctx context.Context, format string, v ...interface{})  {}
+func (l *testLogger) Warnc(ctx context.Context, format string, v ...interface{})  {}
+func (l *testLogger) Errorc(ctx context.Context, format string, v ...interface{}) {}
+
+func TestRegisterPreloadScripts(t *testing.T) {
+	Init(nil)
+	logger := &testLogger{}
+	if err := mlogger.Register("multiredigopool_test", logger); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		opts         *Options
+		dialErr      error
+		wantCommands bool
+		wantWarn     bool
+	}{
+		{name: "preload", wantCommands: true},
+		{name: "no preload", opts: &Options{NoPreload: true}},
+		{name: "dial error", opts: &Options{MLogName: "multiredigopool_test"}, dialErr: errors.New("connection refused"), wantWarn: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger.warns = nil
+			conn := &fakeConn{}
+			dial := make(chan struct{})
+			pool := &redigo.Pool{
+				Dial: func() (redigo.Conn, error) {
+					<-dial // 连接阻塞时注册不等待
+					if tt.dialErr != nil {
+						return nil, tt.dialErr
+					}
+					return conn, nil
+				},
+			}
+			if err := RegisterWithOptions(tt.name, pool, tt.opts); err != nil {
+				t.Fatal(err)
+			}
+			close(dial)
+			preloading.Wait()
+
+			if err := Register(tt.name, pool); err == nil {
+				t.Errorf("Register() duplicate name want error")
+			}
+			if got := MustRetrieveN(tt.name); got != pool {
+				t.Errorf("MustRetrieveN() = %v, want %v", got, pool)
+			}
+			if (len(conn.commands) > 0) != tt.wantCommands {
+				t.Errorf("Register() commands = %v, want preload %v", len(conn.commands), tt.wantCommands)
+			}
+			for _, command := range conn.commands {
+				if command[0] != "SCRIPT" || command[1] != "LOAD" {
+					t.Errorf("Register() command = %v, want SCRIPT LOAD", command[:2])
+				}
+			}
+			if (len(logger.warns) > 0) != tt.wantWarn {
+				t.Errorf("Register() warns = %q, want warn %v", logger.warns, tt.wantWarn)
+			}
+		})
+	}
+}
@@ -15,6 +15,18 @@ const (
 type dbWithInterceptor struct {
 	*sql.DB
 	*sqlplus.Interceptor
+	replicas *replicaSet // 从库 可为 nil
+}
+
+// Close 关闭主库 含从库时停止健康检查并关闭从库
+func (db *dbWithInterceptor) Close() error {
+	if db.replicas != nil {
+		if err := db.replicas.close(); err != nil {
+			db.DB.Close()
+			return err
+		}
+	}
+	return db.DB.Close()
 }
 
 type mutilDB map[string]*dbWithInterceptor
@@ -70,6 +82,16 @@ func RegisterWithInterceptor(name string, db *sql.DB, in *sqlplus.Interceptor) e
 	return nil
 }
 
+// CloseN 关闭并注销 sql db 含从库时停止健康检查并关闭从库
+func CloseN(name string) error {
+	db, ok := mutil[name]
+	if !ok {
+		return fmt.Errorf("name not found: %v", name)
+	}
+	delete(mutil, name)
+	return db.Close()
+}
+
 // Retrieve 获取 *redigo.Pool
 func Retrieve() *sql.DB {
 	return mutil[d].DB
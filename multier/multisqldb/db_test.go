@@ -0,0 +1,113 @@
+package multisqldb
+
+import (
+	"context"
+	"testing"
+
+	sqlplus "github.com/cheetah-fun-gs/goplus/dao/sql"
+	"github.com/cheetah-fun-gs/goplus/dao/sql/sqlfake"
+)
+
+type testUser struct {
+	ID      int    `db:"id"`
+	Name    string `db:"name"`
+	Version int    `db:"version,version"`
+}
+
+func init() {
+	db, _ := sqlfake.New()
+	Init(db)
+}
+
+func TestWrapper(t *testing.T) {
+	db, fake := sqlfake.New()
+	if err := RegisterWithInterceptor("wrapper", db, sqlplus.NewSafeInterceptor()); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	fake.ExpectExec("INSERT INTO user (id, name, version) VALUES (?, ?, ?)").WithArgs(1, "a", 0).WillReturnResult(1, 1)
+	fake.ExpectQuery("SELECT id, name, version FROM user WHERE id = ? LIMIT 1").WithArgs(1).
+		WillReturnRows(sqlfake.NewRows("id", "name", "version").AddRow(1, "a", 0))
+	fake.ExpectExec("UPDATE user SET name = ?, version = version + 1 WHERE id = ? AND version = ?").
+		WithArgs("b", 1, 0).WillReturnResult(0, 1)
+	fake.ExpectQuery("SELECT id, name FROM user WHERE id > ? LIMIT 10").
+		WillReturnRows(sqlfake.NewRows("id", "name").AddRow(1, "b").AddRow(2, "c").AddRow(3, "d"))
+
+	if _, err := InsertContextN(ctx, "wrapper", "user", &testUser{ID: 1, Name: "a"}); err != nil {
+		t.Fatalf("InsertContextN() error = %v", err)
+	}
+	user := &testUser{ID: 1}
+	query, args, _ := sqlplus.GenGet("user", user)
+	if err := GetContextN(ctx, "wrapper", user, query, args...); err != nil || user.Name != "a" {
+		t.Fatalf("GetContextN() = %+v, %v", user, err)
+	}
+	user.Name = "b"
+	if _, err := UpdateContextN(ctx, "wrapper", "user", user); err != nil || user.Version != 1 {
+		t.Fatalf("UpdateContextN() version = %v, %v", user.Version, err)
+	}
+
+	names := []string{}
+	err := SelectEach(ctx, "wrapper", "SELECT id, name FROM user WHERE id > ? LIMIT 10", func(u *testUser) error {
+		names = append(names, u.Name)
+		if len(names) == 2 {
+			return sqlplus.ErrStop
+		}
+		return nil
+	}, 0)
+	if err != nil || len(names) != 2 {
+		t.Errorf("SelectEach() = %v, %v", names, err)
+	}
+
+	// 安全拦截器 拒绝没有 where 的删除 不会到达驱动
+	if _, err := ExecContextN(ctx, "wrapper", "DELETE FROM user"); err == nil {
+		t.Errorf("ExecContextN() delete without where want error")
+	}
+	if err := fake.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestUpdateVersionNonPointer(t *testing.T) {
+	db, fake := sqlfake.New()
+	if err := Register("nonpointer", db); err != nil {
+		t.Fatal(err)
+	}
+	defer CloseN("nonpointer")
+	fake.ExpectExec("UPDATE user SET name = ?, version = version + 1 WHERE id = ? AND version = ?").
+		WithArgs("a", 1, 0).WillReturnResult(0, 1)
+
+	if _, err := UpdateContextN(context.Background(), "nonpointer", "user", testUser{ID: 1, Name: "a"}); err == nil {
+		t.Errorf("UpdateContextN() non-pointer with version want error")
+	}
+	// 执行前拒绝 UPDATE 不会到达驱动
+	if err := fake.ExpectationsWereMet(); err == nil {
+		t.Errorf("UpdateContextN() non-pointer executed the update")
+	}
+}
+
+func TestUpdateWithRetry(t *testing.T) {
+	db, fake := sqlfake.New()
+	if err := Register("retry", db); err != nil {
+		t.Fatal(err)
+	}
+
+	fake.ExpectExec("UPDATE user SET name = ?, version = version + 1 WHERE id = ? AND version = ?").
+		WithArgs("a!", 1, 0).WillReturnResult(0, 0)
+	fake.ExpectQuery("SELECT id, name, version FROM user WHERE id = ? LIMIT 1").WithArgs(1).
+		WillReturnRows(sqlfake.NewRows("id", "name", "version").AddRow(1, "b", 5))
+	fake.ExpectExec("UPDATE user SET name = ?, version = version + 1 WHERE id = ? AND version = ?").
+		WithArgs("b!", 1, 5).WillReturnResult(0, 1)
+
+	user := &testUser{ID: 1, Name: "a"}
+	err := UpdateWithRetry(context.Background(), "retry", "user", user, func() error {
+		user.Name += "!"
+		return nil
+	})
+	if err != nil || user.Name != "b!" || user.Version != 6 {
+		t.Errorf("UpdateWithRetry() = %+v, %v", user, err)
+	}
+	if err := fake.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,162 @@
+package multisqldb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+
+	sqlplus "github.com/cheetah-fun-gs/goplus/dao/sql"
+)
+
+// executor *sql.DB 和 *sql.Tx 的公共方法
+type executor interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+func execResult(result sql.Result, err error) (*sqlplus.ExecResult, error) {
+	if err != nil {
+		return nil, err
+	}
+	rows, errRows := sqlplus.RowsAffected(result, nil)
+	if errRows != nil {
+		rows = -1
+	}
+	return &sqlplus.ExecResult{Result: result, Rows: rows}, nil
+}
+
+func doExec(ctx context.Context, in *sqlplus.Interceptor, e executor, kind sqlplus.ExecKind, query string, args ...interface{}) (sql.Result, error) {
+	execSQL := &sqlplus.ExecSQL{Kind: kind, Query: query, Args: args}
+	result, err := in.Do(ctx, execSQL, func(ctx context.Context, execSQL *sqlplus.ExecSQL) (*sqlplus.ExecResult, error) {
+		return execResult(e.ExecContext(ctx, execSQL.Query, execSQL.Args...))
+	})
+	if err != nil || result == nil {
+		return nil, err
+	}
+	return result.Result, nil
+}
+
+func interceptExec(ctx context.Context, in *sqlplus.Interceptor, e executor, query string, args ...interface{}) (sql.Result, error) {
+	return doExec(ctx, in, e, sqlplus.ExecKindExec, query, args...)
+}
+
+func interceptInsert(ctx context.Context, in *sqlplus.Interceptor, e executor, tableName string, v interface{}) (sql.Result, error) {
+	query, args := sqlplus.GenInsert(tableName, v)
+	return doExec(ctx, in, e, sqlplus.ExecKindInsert, query, args...)
+}
+
+// interceptUpdate 按主键更新 有 version 字段时影响 0 行返回 ErrStaleVersion, 成功则 v 的 version 加 1
+func interceptUpdate(ctx context.Context, in *sqlplus.Interceptor, e executor, tableName string, v interface{}, fields ...string) (sql.Result, error) {
+	versioned := sqlplus.HasVersion(v)
+	if versioned && reflect.ValueOf(v).Kind() != reflect.Ptr {
+		// 更新成功后无法回写 version, 须在执行前拒绝
+		return nil, fmt.Errorf("v must be a pointer of struct with version field")
+	}
+	query, args, err := sqlplus.GenUpdate(tableName, v, fields...)
+	if err != nil {
+		return nil, err
+	}
+	result, err := doExec(ctx, in, e, sqlplus.ExecKindUpdate, query, args...)
+	if err != nil || !versioned {
+		return result, err
+	}
+
+	rows, err := sqlplus.RowsAffected(result, nil)
+	if err != nil {
+		return result, err
+	}
+	if rows == 0 {
+		return result, sqlplus.ErrStaleVersion
+	}
+	return result, sqlplus.IncrVersion(v)
+}
+
+// interceptDelete 按主键删除 有 deleted 字段时为软删除
+func interceptDelete(ctx context.Context, in *sqlplus.Interceptor, e executor, tableName string, v interface{}) (sql.Result, error) {
+	query, args, err := sqlplus.GenDelete(tableName, v)
+	if err != nil {
+		return nil, err
+	}
+	return doExec(ctx, in, e, sqlplus.ExecKindDelete, query, args...)
+}
+
+func interceptPrepare(ctx context.Context, in *sqlplus.Interceptor, e executor, query string) (*sql.Stmt, error) {
+	var stmt *sql.Stmt
+	execSQL := &sqlplus.ExecSQL{Kind: sqlplus.ExecKindPrepare, Query: query}
+	_, err := in.Do(ctx, execSQL, func(ctx context.Context, execSQL *sqlplus.ExecSQL) (*sqlplus.ExecResult, error) {
+		var err error
+		stmt, err = e.PrepareContext(ctx, execSQL.Query)
+		return &sqlplus.ExecResult{Rows: -1}, err
+	})
+	return stmt, err
+}
+
+func interceptQuery(ctx context.Context, in *sqlplus.Interceptor, e executor, query string, args ...interface{}) (*sql.Rows, error) {
+	var rows *sql.Rows
+	execSQL := &sqlplus.ExecSQL{Kind: sqlplus.ExecKindQuery, Query: query, Args: args}
+	_, err := in.Do(ctx, execSQL, func(ctx context.Context, execSQL *sqlplus.ExecSQL) (*sqlplus.ExecResult, error) {
+		var err error
+		rows, err = e.QueryContext(ctx, execSQL.Query, execSQL.Args...)
+		return &sqlplus.ExecResult{Rows: -1}, err
+	})
+	return rows, err
+}
+
+func interceptQueryRow(ctx context.Context, in *sqlplus.Interceptor, e executor, query string, args ...interface{}) (*sql.Row, error) {
+	var row *sql.Row
+	execSQL := &sqlplus.ExecSQL{Kind: sqlplus.ExecKindQueryRow, Query: query, Args: args}
+	_, err := in.Do(ctx, execSQL, func(ctx context.Context, execSQL *sqlplus.ExecSQL) (*sqlplus.ExecResult, error) {
+		row = e.QueryRowContext(ctx, execSQL.Query, execSQL.Args...)
+		return &sqlplus.ExecResult{Rows: -1}, nil
+	})
+	return row, err
+}
+
+func interceptGet(ctx context.Context, in *sqlplus.Interceptor, e executor, v interface{}, query string, args ...interface{}) error {
+	execSQL := &sqlplus.ExecSQL{Kind: sqlplus.ExecKindGet, Query: query, Args: args}
+	_, err := in.Do(ctx, execSQL, func(ctx context.Context, execSQL *sqlplus.ExecSQL) (*sqlplus.ExecResult, error) {
+		rows, err := e.QueryContext(ctx, execSQL.Query, execSQL.Args...)
+		if err != nil {
+			return nil, err
+		}
+		if err = sqlplus.Get(rows, v); err != nil {
+			if err == sql.ErrNoRows {
+				return &sqlplus.ExecResult{Rows: 0}, err
+			}
+			return nil, err
+		}
+		return &sqlplus.ExecResult{Rows: 1}, nil
+	})
+	return err
+}
+
+func interceptSelect(ctx context.Context, in *sqlplus.Interceptor, e executor, v interface{}, query string, args ...interface{}) error {
+	execSQL := &sqlplus.ExecSQL{Kind: sqlplus.ExecKindSelect, Query: query, Args: args}
+	_, err := in.Do(ctx, execSQL, func(ctx context.Context, execSQL *sqlplus.ExecSQL) (*sqlplus.ExecResult, error) {
+		rows, err := e.QueryContext(ctx, execSQL.Query, execSQL.Args...)
+		if err != nil {
+			return nil, err
+		}
+		if err = sqlplus.Select(rows, v); err != nil {
+			return nil, err
+		}
+		return &sqlplus.ExecResult{Rows: reflect.Indirect(reflect.ValueOf(v)).Len()}, nil
+	})
+	return err
+}
+
+func interceptEach(ctx context.Context, in *sqlplus.Interceptor, e executor, query string, fn interface{}, args ...interface{}) error {
+	execSQL := &sqlplus.ExecSQL{Kind: sqlplus.ExecKindEach, Query: query, Args: args}
+	_, err := in.Do(ctx, execSQL, func(ctx context.Context, execSQL *sqlplus.ExecSQL) (*sqlplus.ExecResult, error) {
+		rows, err := e.QueryContext(ctx, execSQL.Query, execSQL.Args...)
+		if err != nil {
+			return nil, err
+		}
+		count, err := sqlplus.Each(rows, fn)
+		return &sqlplus.ExecResult{Rows: count}, err
+	})
+	return err
+}
@@ -0,0 +1,20 @@
+package multisqldb
+
+import (
+	"fmt"
+
+	sqlplus "github.com/cheetah-fun-gs/goplus/dao/sql"
+)
+
+// Migrator 获取 default 的迁移器
+func Migrator(opts *sqlplus.MigratorOptions) (*sqlplus.Migrator, error) {
+	return MigratorN(d, opts)
+}
+
+// MigratorN 获取迁移器 使用主库, 不经过拦截器
+func MigratorN(name string, opts *sqlplus.MigratorOptions) (*sqlplus.Migrator, error) {
+	if db, ok := mutil[name]; ok {
+		return sqlplus.NewMigrator(db.DB, opts), nil
+	}
+	return nil, fmt.Errorf("name not found: %v", name)
+}
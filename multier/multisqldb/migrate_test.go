@@ -0,0 +1,177 @@
+package multisqldb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	sqlplus "github.com/cheetah-fun-gs/goplus/dao/sql"
+	"github.com/cheetah-fun-gs/goplus/dao/sql/sqlfake"
+)
+
+func TestMigratorRun(t *testing.T) {
+	db, fake := sqlfake.New()
+	if err := Register("migrate", db); err != nil {
+		t.Fatal(err)
+	}
+	defer CloseN("migrate")
+	ctx := context.Background()
+	versions := func(versions ...int64) *sqlfake.Rows {
+		rows := sqlfake.NewRows("version", "applied_at")
+		for _, version := range versions {
+			rows.AddRow(version, time.Now().UnixNano())
+		}
+		return rows
+	}
+	expectLock := func() {
+		fake.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations (version BIGINT NOT NULL PRIMARY KEY, name VARCHAR(255) NOT NULL, applied_at BIGINT NOT NULL);")
+		fake.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations_lock (id INT NOT NULL PRIMARY KEY, owner VARCHAR(64) NOT NULL, locked_at BIGINT NOT NULL);")
+		fake.ExpectExec("DELETE FROM schema_migrations_lock WHERE id = 1 AND locked_at < ?").WithArgs(sqlfake.AnyArg())
+		fake.ExpectExec("INSERT INTO schema_migrations_lock (id, owner, locked_at) VALUES (1, ?, ?)").
+			WithArgs(sqlfake.AnyArg(), sqlfake.AnyArg())
+	}
+	expectUnlock := func() {
+		fake.ExpectExec("DELETE FROM schema_migrations_lock WHERE id = 1 AND owner = ?").WithArgs(sqlfake.AnyArg())
+	}
+
+	tests := []struct {
+		name         string
+		lockTimeout  time.Duration
+		expect       func()
+		run          func(m *sqlplus.Migrator) ([]*sqlplus.Migration, error)
+		wantVersions []int64
+		wantErr      string
+	}{
+		{
+			name: "up",
+			expect: func() {
+				expectLock()
+				fake.ExpectQuery("SELECT version, applied_at FROM schema_migrations").WillReturnRows(versions(1))
+				fake.ExpectBegin()
+				fake.ExpectExec("CREATE TABLE b (id INT)")
+				fake.ExpectExec("INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)").
+					WithArgs(2, "b", sqlfake.AnyArg())
+				fake.ExpectCommit()
+				fake.ExpectBegin()
+				fake.ExpectExec("CREATE TABLE c (id INT)")
+				fake.ExpectExec("INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)").
+					WithArgs(3, "c", sqlfake.AnyArg())
+				fake.ExpectCommit()
+				expectUnlock()
+			},
+			run: func(m *sqlplus.Migrator) ([]*sqlplus.Migration, error) {
+				return m.Up(ctx)
+			},
+			wantVersions: []int64{2, 3},
+		},
+		{
+			name: "down",
+			expect: func() {
+				expectLock()
+				fake.ExpectQuery("SELECT version, applied_at FROM schema_migrations").WillReturnRows(versions(1, 2, 3))
+				fake.ExpectBegin()
+				fake.ExpectExec("DROP TABLE c")
+				fake.ExpectExec("DELETE FROM schema_migrations WHERE version = ?").WithArgs(3)
+				fake.ExpectCommit()
+				expectUnlock()
+			},
+			run: func(m *sqlplus.Migrator) ([]*sqlplus.Migration, error) {
+				return m.Down(ctx, 1)
+			},
+			wantVersions: []int64{3},
+		},
+		{
+			name: "failed migration rolls back",
+			expect: func() {
+				expectLock()
+				fake.ExpectQuery("SELECT version, applied_at FROM schema_migrations").WillReturnRows(versions(1))
+				fake.ExpectBegin()
+				fake.ExpectExec("CREATE TABLE b (id INT)").WillReturnError(errors.New("table exists"))
+				fake.ExpectRollback()
+				expectUnlock()
+			},
+			run: func(m *sqlplus.Migrator) ([]*sqlplus.Migration, error) {
+				return m.Up(ctx)
+			},
+			wantErr: "table exists",
+		},
+		{
+			name: "locked",
+			expect: func() {
+				fake.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations (version BIGINT NOT NULL PRIMARY KEY, name VARCHAR(255) NOT NULL, applied_at BIGINT NOT NULL);")
+				fake.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations_lock (id INT NOT NULL PRIMARY KEY, owner VARCHAR(64) NOT NULL, locked_at BIGINT NOT NULL);")
+				fake.ExpectExec("DELETE FROM schema_migrations_lock WHERE id = 1 AND locked_at < ?").WithArgs(sqlfake.AnyArg())
+				fake.ExpectExec("INSERT INTO schema_migrations_lock (id, owner, locked_at) VALUES (1, ?, ?)").
+					WithArgs(sqlfake.AnyArg(), sqlfake.AnyArg()).WillReturnError(errors.New("duplicate entry"))
+			},
+			run: func(m *sqlplus.Migrator) ([]*sqlplus.Migration, error) {
+				return m.Up(ctx)
+			},
+			wantErr: "migration is locked",
+		},
+		{
+			name:        "lock lost",
+			lockTimeout: 30 * time.Millisecond,
+			expect: func() {
+				fake.MatchOrder(false)
+				expectLock()
+				fake.ExpectQuery("SELECT version, applied_at FROM schema_migrations").WillReturnRows(versions(1, 2, 3))
+				fake.ExpectBegin()
+				fake.ExpectExec("UPDATE schema_migrations_lock SET locked_at = ? WHERE id = 1 AND owner = ?").
+					WithArgs(sqlfake.AnyArg(), sqlfake.AnyArg()).WillReturnResult(0, 0)
+				fake.ExpectRollback()
+				expectUnlock()
+			},
+			run: func(m *sqlplus.Migrator) ([]*sqlplus.Migration, error) {
+				if err := m.Register(&sqlplus.Migration{Version: 4, Name: "slow", UpFunc: func(ctx context.Context, tx *sql.Tx) error {
+					<-ctx.Done()
+					return ctx.Err()
+				}}); err != nil {
+					return nil, err
+				}
+				return m.Up(ctx)
+			},
+			wantErr: sqlplus.ErrMigrationLockLost.Error(),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer fake.MatchOrder(true)
+			m, err := MigratorN("migrate", &sqlplus.MigratorOptions{LockTimeout: tt.lockTimeout})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := m.Register(
+				&sqlplus.Migration{Version: 1, Name: "a", Up: "CREATE TABLE a (id INT)", Down: "DROP TABLE a"},
+				&sqlplus.Migration{Version: 2, Name: "b", Up: "CREATE TABLE b (id INT)", Down: "DROP TABLE b"},
+				&sqlplus.Migration{Version: 3, Name: "c", Up: "CREATE TABLE c (id INT)", Down: "DROP TABLE c"},
+			); err != nil {
+				t.Fatal(err)
+			}
+
+			tt.expect()
+			done, err := tt.run(m)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("run() error = %v, want %v", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Errorf("run() error = %v", err)
+			}
+			var got []int64
+			for _, migration := range done {
+				got = append(got, migration.Version)
+			}
+			if !reflect.DeepEqual(got, tt.wantVersions) {
+				t.Errorf("run() versions = %v, want %v", got, tt.wantVersions)
+			}
+			if err := fake.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
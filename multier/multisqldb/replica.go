@@ -0,0 +1,207 @@
+package multisqldb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	sqlplus "github.com/cheetah-fun-gs/goplus/dao/sql"
+)
+
+// ReplicaStrategy 从库选择策略
+type ReplicaStrategy int
+
+// 从库选择策略 定义
+const (
+	ReplicaRoundRobin ReplicaStrategy = iota // 轮询
+	ReplicaLatency                           // 按健康检查的延迟加权随机, 延迟越低权重越高
+)
+
+// ReplicaOptions 从库参数
+type ReplicaOptions struct {
+	Strategy       ReplicaStrategy
+	HealthInterval time.Duration        // 健康检查间隔 默认 5 秒
+	HealthTimeout  time.Duration        // 健康检查超时 默认 1 秒
+	Interceptor    *sqlplus.Interceptor // 主从共用的拦截器
+}
+
+type replica struct {
+	db      *sql.DB
+	healthy int32 // 1 健康 0 不健康
+	latency int64 // 最近一次健康检查的延迟 纳秒
+}
+
+type replicaSet struct {
+	replicas []*replica
+	strategy ReplicaStrategy
+	counter  uint64
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+type primaryKey struct{}
+
+// WithPrimary 强制读主库 用于写后立即读
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+func isPrimary(ctx context.Context) bool {
+	v, _ := ctx.Value(primaryKey{}).(bool)
+	return v
+}
+
+// 只读语句才会路由到从库
+func isReadQuery(query string) bool {
+	query = strings.ToUpper(strings.TrimSpace(query))
+	return strings.HasPrefix(query, "SELECT") && !strings.Contains(query, "FOR UPDATE") &&
+		!strings.Contains(query, "LOCK IN SHARE MODE")
+}
+
+func newReplicaSet(dbs []*sql.DB, opts *ReplicaOptions) *replicaSet {
+	rs := &replicaSet{
+		strategy: opts.Strategy,
+		stop:     make(chan struct{}),
+	}
+	for _, db := range dbs {
+		rs.replicas = append(rs.replicas, &replica{db: db, healthy: 1, latency: int64(time.Millisecond)})
+	}
+
+	interval, timeout := opts.HealthInterval, opts.HealthTimeout
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	if timeout <= 0 {
+		timeout = time.Second
+	}
+	go rs.healthCheck(interval, timeout)
+	return rs
+}
+
+func (rs *replicaSet) healthCheck(interval, timeout time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-rs.stop:
+			return
+		case <-ticker.C:
+			rs.check(timeout)
+		}
+	}
+}
+
+// check 检查一遍从库 ping 失败的摘除, 恢复的重新加入
+func (rs *replicaSet) check(timeout time.Duration) {
+	for _, r := range rs.replicas {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		start := time.Now()
+		err := r.db.PingContext(ctx)
+		cancel()
+		if err != nil {
+			atomic.StoreInt32(&r.healthy, 0)
+			continue
+		}
+		atomic.StoreInt64(&r.latency, int64(time.Since(start)))
+		atomic.StoreInt32(&r.healthy, 1)
+	}
+}
+
+// close 停止健康检查 并关闭从库
+func (rs *replicaSet) close() error {
+	rs.stopOnce.Do(func() {
+		close(rs.stop)
+	})
+	var err error
+	for _, r := range rs.replicas {
+		if e := r.db.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// pick 选择一个健康的从库 没有返回 nil
+func (rs *replicaSet) pick() *sql.DB {
+	healthy := []*replica{}
+	for _, r := range rs.replicas {
+		if atomic.LoadInt32(&r.healthy) == 1 {
+			healthy = append(healthy, r)
+		}
+	}
+	if len(healthy) == 0 {
+		return nil
+	}
+
+	if rs.strategy == ReplicaLatency {
+		weights := make([]float64, len(healthy))
+		total := 0.0
+		for i, r := range healthy {
+			latency := atomic.LoadInt64(&r.latency)
+			if latency <= 0 {
+				latency = 1
+			}
+			weights[i] = 1 / float64(latency)
+			total += weights[i]
+		}
+		n := rand.Float64() * total
+		for i, weight := range weights {
+			if n < weight {
+				return healthy[i].db
+			}
+			n -= weight
+		}
+		return healthy[len(healthy)-1].db
+	}
+
+	n := atomic.AddUint64(&rs.counter, 1)
+	return healthy[n%uint64(len(healthy))].db
+}
+
+// reader 读语句使用的库: 有健康从库且未强制读主库时使用从库, 否则使用主库
+func (db *dbWithInterceptor) reader(ctx context.Context, query string) *sql.DB {
+	if db.replicas == nil || isPrimary(ctx) || !isReadQuery(query) {
+		return db.DB
+	}
+	if r := db.replicas.pick(); r != nil {
+		return r
+	}
+	return db.DB
+}
+
+// InitWithReplicas 初始化db 含从库
+func InitWithReplicas(primary *sql.DB, replicas []*sql.DB, opts *ReplicaOptions) {
+	if opts == nil {
+		opts = &ReplicaOptions{}
+	}
+	once.Do(func() {
+		mutil = mutilDB{
+			d: &dbWithInterceptor{
+				DB:          primary,
+				Interceptor: opts.Interceptor,
+				replicas:    newReplicaSet(replicas, opts),
+			},
+		}
+	})
+}
+
+// RegisterWithReplicas 注册 sql db 含从库: 读语句轮询或按延迟选择健康的从库, 写语句和事务使用主库
+func RegisterWithReplicas(name string, primary *sql.DB, replicas []*sql.DB, opts *ReplicaOptions) error {
+	if _, ok := mutil[name]; ok {
+		return fmt.Errorf("duplicate name: %v", name)
+	}
+	if opts == nil {
+		opts = &ReplicaOptions{}
+	}
+	mutil[name] = &dbWithInterceptor{
+		DB:          primary,
+		Interceptor: opts.Interceptor,
+		replicas:    newReplicaSet(replicas, opts),
+	}
+	return nil
+}
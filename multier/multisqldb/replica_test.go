@@ -0,0 +1,152 @@
+package multisqldb
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/cheetah-fun-gs/goplus/dao/sql/sqlfake"
+)
+
+func TestReplicaRouting(t *testing.T) {
+	primary, primaryFake := sqlfake.New()
+	replica1, replica1Fake := sqlfake.New()
+	replica2, replica2Fake := sqlfake.New()
+	opts := &ReplicaOptions{HealthInterval: time.Hour}
+	if err := RegisterWithReplicas("replica", primary, []*sql.DB{replica1, replica2}, opts); err != nil {
+		t.Fatal(err)
+	}
+	defer CloseN("replica")
+	ctx := context.Background()
+	rows := func() *sqlfake.Rows {
+		return sqlfake.NewRows("id", "name", "version").AddRow(1, "a", 0)
+	}
+
+	tests := []struct {
+		name   string
+		expect func()
+		run    func() error
+	}{
+		{
+			name: "read round robin",
+			expect: func() {
+				replica1Fake.ExpectQuery("SELECT id, name, version FROM user WHERE id = ?").WithArgs(1).WillReturnRows(rows())
+				replica2Fake.ExpectQuery("SELECT id, name, version FROM user WHERE id = ?").WithArgs(1).WillReturnRows(rows())
+			},
+			run: func() error {
+				for i := 0; i < 2; i++ {
+					if err := GetContextN(ctx, "replica", &testUser{}, "SELECT id, name, version FROM user WHERE id = ?", 1); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		},
+		{
+			name: "write to primary",
+			expect: func() {
+				primaryFake.ExpectExec("UPDATE user SET name = ? WHERE id = ?").WithArgs("a", 1).WillReturnResult(0, 1)
+			},
+			run: func() error {
+				_, err := ExecContextN(ctx, "replica", "UPDATE user SET name = ? WHERE id = ?", "a", 1)
+				return err
+			},
+		},
+		{
+			name: "locking read to primary",
+			expect: func() {
+				primaryFake.ExpectQuery("SELECT id, name, version FROM user WHERE id = ? FOR UPDATE").WithArgs(1).WillReturnRows(rows())
+			},
+			run: func() error {
+				return GetContextN(ctx, "replica", &testUser{}, "SELECT id, name, version FROM user WHERE id = ? FOR UPDATE", 1)
+			},
+		},
+		{
+			name: "with primary",
+			expect: func() {
+				primaryFake.ExpectQuery("SELECT id, name, version FROM user WHERE id = ?").WithArgs(1).WillReturnRows(rows())
+			},
+			run: func() error {
+				return GetContextN(WithPrimary(ctx), "replica", &testUser{}, "SELECT id, name, version FROM user WHERE id = ?", 1)
+			},
+		},
+		{
+			name: "tx to primary",
+			expect: func() {
+				primaryFake.ExpectBegin()
+				primaryFake.ExpectQuery("SELECT id, name, version FROM user WHERE id = ?").WithArgs(1).WillReturnRows(rows())
+				primaryFake.ExpectCommit()
+			},
+			run: func() error {
+				return WithTx(ctx, "replica", func(tx *Tx) error {
+					return tx.Get(&testUser{}, "SELECT id, name, version FROM user WHERE id = ?", 1)
+				})
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.expect()
+			if err := tt.run(); err != nil {
+				t.Fatalf("run() error = %v", err)
+			}
+			for _, fake := range []*sqlfake.Fake{primaryFake, replica1Fake, replica2Fake} {
+				if err := fake.ExpectationsWereMet(); err != nil {
+					t.Error(err)
+				}
+			}
+		})
+	}
+}
+
+func TestReplicaHealth(t *testing.T) {
+	primary, _ := sqlfake.New()
+	replica1, _ := sqlfake.New()
+	replica2, _ := sqlfake.New()
+	rs := newReplicaSet([]*sql.DB{replica1, replica2}, &ReplicaOptions{HealthInterval: time.Hour})
+	db := &dbWithInterceptor{DB: primary, replicas: rs}
+	ctx := context.Background()
+
+	// 关闭的库 ping 失败
+	closed, _ := sqlfake.New()
+	closed.Close()
+
+	rs.replicas[0].db = closed
+	rs.check(time.Second)
+	for i := 0; i < 4; i++ {
+		if got := db.reader(ctx, "SELECT 1"); got != replica2 {
+			t.Fatalf("reader() evicted replica still picked")
+		}
+	}
+
+	rs.replicas[1].db = closed
+	rs.check(time.Second)
+	if got := db.reader(ctx, "SELECT 1"); got != primary {
+		t.Errorf("reader() no healthy replica want primary")
+	}
+
+	rs.replicas[0].db, rs.replicas[1].db = replica1, replica2
+	rs.check(time.Second)
+	picked := map[*sql.DB]bool{}
+	for i := 0; i < 4; i++ {
+		picked[db.reader(ctx, "SELECT 1")] = true
+	}
+	if !picked[replica1] || !picked[replica2] {
+		t.Errorf("reader() recovered replicas not picked")
+	}
+
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	select {
+	case <-rs.stop:
+	default:
+		t.Errorf("Close() health check not stopped")
+	}
+	for _, r := range []*sql.DB{primary, replica1, replica2} {
+		if err := r.Ping(); err == nil {
+			t.Errorf("Close() db not closed")
+		}
+	}
+}
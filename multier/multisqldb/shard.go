@@ -0,0 +1,300 @@
+package multisqldb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"hash/crc32"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// TablePlaceholder 分片语句中的表名占位符 执行时替换为分片的实际表名
+const TablePlaceholder = "{table}"
+
+// Shard 分片 已注册的 db 名称和实际表名
+type Shard struct {
+	DB    string
+	Table string
+}
+
+func (shard *Shard) String() string {
+	return shard.DB + "." + shard.Table
+}
+
+// GenShards 生成分片 每个 db 分 tables 张表, 表名为 table_0 ~ table_{len(dbs)*tables-1} 全局编号
+func GenShards(dbs []string, table string, tables int) []*Shard {
+	if tables <= 0 {
+		tables = 1
+	}
+	shards := []*Shard{}
+	for i, db := range dbs {
+		for j := 0; j < tables; j++ {
+			shards = append(shards, &Shard{DB: db, Table: fmt.Sprintf("%v_%v", table, i*tables+j)})
+		}
+	}
+	return shards
+}
+
+// ShardStrategy 分片策略 返回分片键对应的分片下标
+type ShardStrategy interface {
+	Route(key interface{}, shards []*Shard) (int, error)
+}
+
+// ModStrategy 取模 整数直接取模, 字符串取 crc32 后取模
+type ModStrategy struct{}
+
+// Route ...
+func (ModStrategy) Route(key interface{}, shards []*Shard) (int, error) {
+	n, err := hashKey(key)
+	if err != nil {
+		return 0, err
+	}
+	return int(n % uint64(len(shards))), nil
+}
+
+// RangeStrategy 范围 Bounds 为各分片的上界(不含) 须递增, 分片数量须等于 len(Bounds)
+type RangeStrategy struct {
+	Bounds []int64
+}
+
+// Route ...
+func (strategy RangeStrategy) Route(key interface{}, shards []*Shard) (int, error) {
+	if len(strategy.Bounds) != len(shards) {
+		return 0, fmt.Errorf("bounds length %v not match shards %v", len(strategy.Bounds), len(shards))
+	}
+	n, err := intKey(key)
+	if err != nil {
+		return 0, err
+	}
+	index := sort.Search(len(strategy.Bounds), func(i int) bool { return n < strategy.Bounds[i] })
+	if index == len(strategy.Bounds) {
+		return 0, fmt.Errorf("key out of range: %v", key)
+	}
+	return index, nil
+}
+
+// ConsistentHashStrategy 一致性哈希 按分片名称计算虚拟节点, 增减分片只影响相邻的键
+type ConsistentHashStrategy struct {
+	Replicas int // 每个分片的虚拟节点数 默认 100
+	mutex    sync.Mutex
+	shards   []*Shard
+	hashes   []uint32
+	indexes  map[uint32]int
+}
+
+// NewConsistentHashStrategy ...
+func NewConsistentHashStrategy(replicas int) *ConsistentHashStrategy {
+	return &ConsistentHashStrategy{Replicas: replicas}
+}
+
+// Route ...
+func (strategy *ConsistentHashStrategy) Route(key interface{}, shards []*Shard) (int, error) {
+	var h uint32
+	switch v := key.(type) {
+	case string:
+		h = crc32.ChecksumIEEE([]byte(v))
+	case []byte:
+		h = crc32.ChecksumIEEE(v)
+	default:
+		n, err := intKey(key)
+		if err != nil {
+			return 0, err
+		}
+		h = crc32.ChecksumIEEE([]byte(strconv.FormatInt(n, 10)))
+	}
+
+	strategy.mutex.Lock()
+	defer strategy.mutex.Unlock()
+	if !sameShards(strategy.shards, shards) {
+		strategy.build(shards)
+	}
+	i := sort.Search(len(strategy.hashes), func(i int) bool { return strategy.hashes[i] >= h })
+	if i == len(strategy.hashes) {
+		i = 0
+	}
+	return strategy.indexes[strategy.hashes[i]], nil
+}
+
+func (strategy *ConsistentHashStrategy) build(shards []*Shard) {
+	replicas := strategy.Replicas
+	if replicas <= 0 {
+		replicas = 100
+	}
+	strategy.shards = append([]*Shard{}, shards...)
+	strategy.hashes = []uint32{}
+	strategy.indexes = map[uint32]int{}
+	for index, shard := range shards {
+		for i := 0; i < replicas; i++ {
+			h := crc32.ChecksumIEEE([]byte(shard.String() + "#" + strconv.Itoa(i)))
+			if _, ok := strategy.indexes[h]; ok {
+				continue
+			}
+			strategy.indexes[h] = index
+			strategy.hashes = append(strategy.hashes, h)
+		}
+	}
+	sort.Slice(strategy.hashes, func(i, j int) bool { return strategy.hashes[i] < strategy.hashes[j] })
+}
+
+func sameShards(a, b []*Shard) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if *a[i] != *b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func intKey(key interface{}) (int64, error) {
+	v := reflect.ValueOf(key)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(v.Uint()), nil
+	}
+	return 0, fmt.Errorf("shard key must be integer: %v", key)
+}
+
+func hashKey(key interface{}) (uint64, error) {
+	switch v := key.(type) {
+	case string:
+		return uint64(crc32.ChecksumIEEE([]byte(v))), nil
+	case []byte:
+		return uint64(crc32.ChecksumIEEE(v)), nil
+	}
+	n, err := intKey(key)
+	if err != nil {
+		return 0, fmt.Errorf("shard key must be integer or string: %v", key)
+	}
+	if n < 0 {
+		n = -n
+	}
+	return uint64(n), nil
+}
+
+// Sharding 分片路由 语句中的表名写作 TablePlaceholder
+type Sharding struct {
+	shards   []*Shard
+	strategy ShardStrategy
+}
+
+// NewSharding ...
+func NewSharding(shards []*Shard, strategy ShardStrategy) (*Sharding, error) {
+	if len(shards) == 0 {
+		return nil, fmt.Errorf("shards is empty")
+	}
+	if strategy == nil {
+		strategy = ModStrategy{}
+	}
+	return &Sharding{shards: shards, strategy: strategy}, nil
+}
+
+// Shards 全部分片
+func (sharding *Sharding) Shards() []*Shard {
+	return sharding.shards
+}
+
+// Route 分片键对应的分片
+func (sharding *Sharding) Route(key interface{}) (*Shard, error) {
+	index, err := sharding.strategy.Route(key, sharding.shards)
+	if err != nil {
+		return nil, err
+	}
+	if index < 0 || index >= len(sharding.shards) {
+		return nil, fmt.Errorf("shard index out of range: %v", index)
+	}
+	return sharding.shards[index], nil
+}
+
+func (sharding *Sharding) route(key interface{}, query string) (*Shard, string, error) {
+	shard, err := sharding.Route(key)
+	if err != nil {
+		return nil, "", err
+	}
+	return shard, shardQuery(shard, query), nil
+}
+
+func shardQuery(shard *Shard, query string) string {
+	return strings.Replace(query, TablePlaceholder, shard.Table, -1)
+}
+
+// Exec ...
+func (sharding *Sharding) Exec(ctx context.Context, key interface{}, query string, args ...interface{}) (sql.Result, error) {
+	shard, query, err := sharding.route(key, query)
+	if err != nil {
+		return nil, err
+	}
+	return ExecContextN(ctx, shard.DB, query, args...)
+}
+
+// Insert 插入到分片键对应的分片
+func (sharding *Sharding) Insert(ctx context.Context, key interface{}, v interface{}) (sql.Result, error) {
+	shard, err := sharding.Route(key)
+	if err != nil {
+		return nil, err
+	}
+	return InsertContextN(ctx, shard.DB, shard.Table, v)
+}
+
+// Get ...
+func (sharding *Sharding) Get(ctx context.Context, key interface{}, v interface{}, query string, args ...interface{}) error {
+	shard, query, err := sharding.route(key, query)
+	if err != nil {
+		return err
+	}
+	return GetContextN(ctx, shard.DB, v, query, args...)
+}
+
+// Select ...
+func (sharding *Sharding) Select(ctx context.Context, key interface{}, v interface{}, query string, args ...interface{}) error {
+	shard, query, err := sharding.route(key, query)
+	if err != nil {
+		return err
+	}
+	return SelectContextN(ctx, shard.DB, v, query, args...)
+}
+
+// SelectAll 在全部分片上并发执行 结果按分片顺序合并到 v, v 须为切片指针; less 不为空时对合并结果排序
+func (sharding *Sharding) SelectAll(ctx context.Context, v interface{}, less func(i, j int) bool, query string, args ...interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("v must be a non-nil pointer to slice")
+	}
+
+	results := make([]reflect.Value, len(sharding.shards))
+	errs := make([]error, len(sharding.shards))
+	wg := &sync.WaitGroup{}
+	for i, shard := range sharding.shards {
+		wg.Add(1)
+		go func(i int, shard *Shard) {
+			defer wg.Done()
+			results[i] = reflect.New(rv.Elem().Type())
+			errs[i] = SelectContextN(ctx, shard.DB, results[i].Interface(), shardQuery(shard, query), args...)
+		}(i, shard)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			return fmt.Errorf("shard %v: %v", sharding.shards[i], err)
+		}
+	}
+
+	merged := reflect.MakeSlice(rv.Elem().Type(), 0, 0)
+	for _, result := range results {
+		merged = reflect.AppendSlice(merged, result.Elem())
+	}
+	rv.Elem().Set(merged)
+	if less != nil {
+		sort.SliceStable(rv.Elem().Interface(), less)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package multisqldb
+
+import (
+	"testing"
+)
+
+func TestGenShards(t *testing.T) {
+	shards := GenShards([]string{"db0", "db1"}, "player", 2)
+	want := []Shard{{"db0", "player_0"}, {"db0", "player_1"}, {"db1", "player_2"}, {"db1", "player_3"}}
+	if len(shards) != len(want) {
+		t.Fatalf("GenShards() len = %v, want %v", len(shards), len(want))
+	}
+	for i := range want {
+		if *shards[i] != want[i] {
+			t.Errorf("GenShards()[%v] = %v, want %v", i, shards[i], want[i])
+		}
+	}
+}
+
+func TestShardStrategy(t *testing.T) {
+	shards := GenShards([]string{"db0", "db1"}, "player", 2)
+	tests := []struct {
+		name     string
+		strategy ShardStrategy
+		key      interface{}
+		want     int
+		wantErr  bool
+	}{
+		{name: "mod", strategy: ModStrategy{}, key: 10, want: 2},
+		{name: "mod uint", strategy: ModStrategy{}, key: uint32(7), want: 3},
+		{name: "mod float", strategy: ModStrategy{}, key: 1.5, wantErr: true},
+		{name: "range", strategy: RangeStrategy{Bounds: []int64{100, 200, 300, 400}}, key: 100, want: 1},
+		{name: "range first", strategy: RangeStrategy{Bounds: []int64{100, 200, 300, 400}}, key: int64(-1), want: 0},
+		{name: "range out", strategy: RangeStrategy{Bounds: []int64{100, 200, 300, 400}}, key: 400, wantErr: true},
+		{name: "range string", strategy: RangeStrategy{Bounds: []int64{100, 200, 300, 400}}, key: "a", wantErr: true},
+		{name: "range bounds", strategy: RangeStrategy{Bounds: []int64{100}}, key: 1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.strategy.Route(tt.key, shards)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Route() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("Route() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConsistentHashStrategy(t *testing.T) {
+	shards := GenShards([]string{"db0", "db1"}, "player", 2)
+	strategy := NewConsistentHashStrategy(0)
+
+	before := map[int]int{}
+	for key := 0; key < 1000; key++ {
+		index, err := strategy.Route(key, shards)
+		if err != nil {
+			t.Fatalf("Route() error = %v", err)
+		}
+		before[key] = index
+	}
+
+	// 增加一个分片 原有的键只能迁移到新分片
+	shards = append(shards, &Shard{DB: "db2", Table: "player_4"})
+	moved := 0
+	for key := 0; key < 1000; key++ {
+		index, _ := strategy.Route(key, shards)
+		if index != before[key] {
+			if index != 4 {
+				t.Fatalf("Route(%v) = %v, want %v or 4", key, index, before[key])
+			}
+			moved++
+		}
+	}
+	if moved == 0 || moved > 500 {
+		t.Errorf("moved = %v, want (0, 500]", moved)
+	}
+}
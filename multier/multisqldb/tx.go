@@ -0,0 +1,194 @@
+package multisqldb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	sqlplus "github.com/cheetah-fun-gs/goplus/dao/sql"
+)
+
+// TxRetry WithTx 遇到死锁时的最大重试次数
+var TxRetry = 3
+
+// IsDeadlock 判断是否为死锁错误 可替换 默认识别 mysql 1213 和 postgres 40P01
+var IsDeadlock = func(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "Error 1213") || strings.Contains(msg, "Deadlock found") ||
+		strings.Contains(msg, "deadlock detected")
+}
+
+// Tx 事务 语句同样经过拦截器
+type Tx struct {
+	tx          *sql.Tx
+	interceptor *sqlplus.Interceptor
+	onCommit    []func()
+	onRollback  []func()
+}
+
+// Raw 获取原始 *sql.Tx 注意: 直接使用不经过拦截器
+func (tx *Tx) Raw() *sql.Tx {
+	return tx.tx
+}
+
+// OnCommit 注册提交成功后的回调
+func (tx *Tx) OnCommit(f ...func()) {
+	tx.onCommit = append(tx.onCommit, f...)
+}
+
+// OnRollback 注册回滚后的回调
+func (tx *Tx) OnRollback(f ...func()) {
+	tx.onRollback = append(tx.onRollback, f...)
+}
+
+// Commit ...
+func (tx *Tx) Commit() error {
+	if err := tx.tx.Commit(); err != nil {
+		return err
+	}
+	for _, f := range tx.onCommit {
+		f()
+	}
+	return nil
+}
+
+// Rollback ...
+func (tx *Tx) Rollback() error {
+	if err := tx.tx.Rollback(); err != nil {
+		return err
+	}
+	for _, f := range tx.onRollback {
+		f()
+	}
+	return nil
+}
+
+// Exec ...
+func (tx *Tx) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return tx.ExecContext(context.Background(), query, args...)
+}
+
+// ExecContext ...
+func (tx *Tx) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return interceptExec(ctx, tx.interceptor, tx.tx, query, args...)
+}
+
+// Prepare ...
+func (tx *Tx) Prepare(query string) (*sql.Stmt, error) {
+	return tx.PrepareContext(context.Background(), query)
+}
+
+// PrepareContext ...
+func (tx *Tx) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return interceptPrepare(ctx, tx.interceptor, tx.tx, query)
+}
+
+// Query ...
+func (tx *Tx) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return tx.QueryContext(context.Background(), query, args...)
+}
+
+// QueryContext ...
+func (tx *Tx) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return interceptQuery(ctx, tx.interceptor, tx.tx, query, args...)
+}
+
+// QueryRow ...
+func (tx *Tx) QueryRow(query string, args ...interface{}) (*sql.Row, error) {
+	return tx.QueryRowContext(context.Background(), query, args...)
+}
+
+// QueryRowContext ...
+func (tx *Tx) QueryRowContext(ctx context.Context, query string, args ...interface{}) (*sql.Row, error) {
+	return interceptQueryRow(ctx, tx.interceptor, tx.tx, query, args...)
+}
+
+// Get ...
+func (tx *Tx) Get(v interface{}, query string, args ...interface{}) error {
+	return tx.GetContext(context.Background(), v, query, args...)
+}
+
+// GetContext ...
+func (tx *Tx) GetContext(ctx context.Context, v interface{}, query string, args ...interface{}) error {
+	return interceptGet(ctx, tx.interceptor, tx.tx, v, query, args...)
+}
+
+// Select ...
+func (tx *Tx) Select(v interface{}, query string, args ...interface{}) error {
+	return tx.SelectContext(context.Background(), v, query, args...)
+}
+
+// SelectContext ...
+func (tx *Tx) SelectContext(ctx context.Context, v interface{}, query string, args ...interface{}) error {
+	return interceptSelect(ctx, tx.interceptor, tx.tx, v, query, args...)
+}
+
+// Update 按主键更新 见 UpdateContextN
+func (tx *Tx) Update(tableName string, v interface{}, fields ...string) (sql.Result, error) {
+	return tx.UpdateContext(context.Background(), tableName, v, fields...)
+}
+
+// UpdateContext ...
+func (tx *Tx) UpdateContext(ctx context.Context, tableName string, v interface{}, fields ...string) (sql.Result, error) {
+	return interceptUpdate(ctx, tx.interceptor, tx.tx, tableName, v, fields...)
+}
+
+// Delete 按主键删除 见 DeleteContextN
+func (tx *Tx) Delete(tableName string, v interface{}) (sql.Result, error) {
+	return tx.DeleteContext(context.Background(), tableName, v)
+}
+
+// DeleteContext ...
+func (tx *Tx) DeleteContext(ctx context.Context, tableName string, v interface{}) (sql.Result, error) {
+	return interceptDelete(ctx, tx.interceptor, tx.tx, tableName, v)
+}
+
+// SelectEach 逐行读取 见 SelectEach
+func (tx *Tx) SelectEach(ctx context.Context, query string, fn interface{}, args ...interface{}) error {
+	return interceptEach(ctx, tx.interceptor, tx.tx, query, fn, args...)
+}
+
+// Insert ...
+func (tx *Tx) Insert(tableName string, v interface{}) (sql.Result, error) {
+	return tx.InsertContext(context.Background(), tableName, v)
+}
+
+// InsertContext ...
+func (tx *Tx) InsertContext(ctx context.Context, tableName string, v interface{}) (sql.Result, error) {
+	return interceptInsert(ctx, tx.interceptor, tx.tx, tableName, v)
+}
+
+// WithTx 在事务中执行 f: f 返回错误或 panic 时回滚, 否则提交; 遇到死锁时整体重试 f
+func WithTx(ctx context.Context, name string, f func(tx *Tx) error) error {
+	var err error
+	for i := 0; i <= TxRetry; i++ {
+		err = withTx(ctx, name, f)
+		if err == nil || !IsDeadlock(err) {
+			return err
+		}
+	}
+	return err
+}
+
+func withTx(ctx context.Context, name string, f func(tx *Tx) error) (err error) {
+	tx, err := BeginTxN(ctx, name, nil)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err = f(tx); err != nil {
+		if errRollback := tx.Rollback(); errRollback != nil {
+			return fmt.Errorf("%v, rollback: %v", err, errRollback)
+		}
+		return err
+	}
+	return tx.Commit()
+}
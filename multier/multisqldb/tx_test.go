@@ -0,0 +1,120 @@
+package multisqldb
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/cheetah-fun-gs/goplus/dao/sql/sqlfake"
+)
+
+func TestWithTx(t *testing.T) {
+	db, fake := sqlfake.New()
+	if err := Register("tx", db); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	boom := errors.New("boom")
+
+	tests := []struct {
+		name     string
+		expect   func()
+		f        func(tx *Tx) error
+		wantErr  error
+		panics   bool
+		commit   int
+		rollback int
+	}{
+		{
+			name: "commit",
+			expect: func() {
+				fake.ExpectBegin()
+				fake.ExpectExec("UPDATE user SET name = ? WHERE id = ?").WithArgs("a", 1).WillReturnResult(0, 1)
+				fake.ExpectCommit()
+			},
+			f: func(tx *Tx) error {
+				_, err := tx.Exec("UPDATE user SET name = ? WHERE id = ?", "a", 1)
+				return err
+			},
+			commit: 1,
+		},
+		{
+			name: "rollback on error",
+			expect: func() {
+				fake.ExpectBegin()
+				fake.ExpectExec("UPDATE user SET name = ? WHERE id = ?").WithArgs("a", 1).WillReturnResult(0, 1)
+				fake.ExpectRollback()
+			},
+			f: func(tx *Tx) error {
+				if _, err := tx.Exec("UPDATE user SET name = ? WHERE id = ?", "a", 1); err != nil {
+					return err
+				}
+				return boom
+			},
+			wantErr:  boom,
+			rollback: 1,
+		},
+		{
+			name: "rollback on panic",
+			expect: func() {
+				fake.ExpectBegin()
+				fake.ExpectRollback()
+			},
+			f: func(tx *Tx) error {
+				panic("boom")
+			},
+			panics:   true,
+			rollback: 1,
+		},
+		{
+			name: "retry on deadlock",
+			expect: func() {
+				fake.ExpectBegin()
+				fake.ExpectExec("UPDATE user SET name = ? WHERE id = ?").WithArgs("a", 1).
+					WillReturnError(errors.New("Error 1213: Deadlock found when trying to get lock"))
+				fake.ExpectRollback()
+				fake.ExpectBegin()
+				fake.ExpectExec("UPDATE user SET name = ? WHERE id = ?").WithArgs("a", 1).WillReturnResult(0, 1)
+				fake.ExpectCommit()
+			},
+			f: func(tx *Tx) error {
+				_, err := tx.Exec("UPDATE user SET name = ? WHERE id = ?", "a", 1)
+				return err
+			},
+			commit:   1,
+			rollback: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.expect()
+			commit, rollback := 0, 0
+			f := func(tx *Tx) error {
+				tx.OnCommit(func() { commit++ })
+				tx.OnRollback(func() { rollback++ })
+				return tt.f(tx)
+			}
+
+			var err error
+			panicked := func() (panicked bool) {
+				defer func() {
+					panicked = recover() != nil
+				}()
+				err = WithTx(ctx, "tx", f)
+				return
+			}()
+			if panicked != tt.panics {
+				t.Errorf("WithTx() panicked = %v, want %v", panicked, tt.panics)
+			}
+			if err != tt.wantErr {
+				t.Errorf("WithTx() error = %v, want %v", err, tt.wantErr)
+			}
+			if commit != tt.commit || rollback != tt.rollback {
+				t.Errorf("WithTx() commit = %v, rollback = %v, want %v, %v", commit, rollback, tt.commit, tt.rollback)
+			}
+			if err := fake.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
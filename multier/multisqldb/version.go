@@ -0,0 +1,32 @@
+package multisqldb
+
+import (
+	"context"
+
+	sqlplus "github.com/cheetah-fun-gs/goplus/dao/sql"
+)
+
+// VersionRetry UpdateWithRetry 版本冲突时的最大重试次数
+var VersionRetry = 3
+
+// UpdateWithRetry 乐观锁更新 v 为含 version 字段的结构体指针
+// 先调用 mutate 修改 v 再更新, 版本冲突时从主库按主键重新读取 v 并再次调用 mutate, 重试次数用尽返回 ErrStaleVersion
+func UpdateWithRetry(ctx context.Context, name, tableName string, v interface{}, mutate func() error, fields ...string) error {
+	for i := 0; ; i++ {
+		if err := mutate(); err != nil {
+			return err
+		}
+		_, err := UpdateContextN(ctx, name, tableName, v, fields...)
+		if err != sqlplus.ErrStaleVersion || i >= VersionRetry {
+			return err
+		}
+
+		query, args, err := sqlplus.GenGet(tableName, v)
+		if err != nil {
+			return err
+		}
+		if err := GetContextN(WithPrimary(ctx), name, v, query, args...); err != nil {
+			return err
+		}
+	}
+}
@@ -6,16 +6,15 @@ import (
 	"fmt"
 
 	sqlplus "github.com/cheetah-fun-gs/goplus/dao/sql"
-	"github.com/knocknote/vitess-sqlparser/sqlparser"
 )
 
 // Begin ...
-func Begin() (*sql.Tx, error) {
+func Begin() (*Tx, error) {
 	return BeginN(d)
 }
 
 // BeginTx ...
-func BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+func BeginTx(ctx context.Context, opts *sql.TxOptions) (*Tx, error) {
 	return BeginTxN(ctx, d, opts)
 }
 
@@ -65,17 +64,21 @@ func Stats() sql.DBStats {
 }
 
 // BeginN ...
-func BeginN(name string) (*sql.Tx, error) {
-	if db, ok := mutil[name]; ok {
-		return db.Begin()
-	}
-	return nil, fmt.Errorf("name not found: %v", name)
+func BeginN(name string) (*Tx, error) {
+	return BeginTxN(context.Background(), name, nil)
 }
 
-// BeginTxN ...
-func BeginTxN(ctx context.Context, name string, opts *sql.TxOptions) (*sql.Tx, error) {
+// BeginTxN 事务内的语句同样经过拦截器
+func BeginTxN(ctx context.Context, name string, opts *sql.TxOptions) (*Tx, error) {
 	if db, ok := mutil[name]; ok {
-		return db.BeginTx(ctx, opts)
+		tx, err := db.BeginTx(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		return &Tx{
+			tx:          tx,
+			interceptor: db.Interceptor,
+		}, nil
 	}
 	return nil, fmt.Errorf("name not found: %v", name)
 }
@@ -88,23 +91,7 @@ func ExecN(name, query string, args ...interface{}) (sql.Result, error) {
 // ExecContextN ...
 func ExecContextN(ctx context.Context, name, query string, args ...interface{}) (sql.Result, error) {
 	if db, ok := mutil[name]; ok {
-		var execSQL *sqlplus.ExecSQL
-		if db.Interceptor != nil {
-			parse, _ := sqlparser.Parse(query)
-			execSQL = &sqlplus.ExecSQL{
-				Query: query,
-				Args:  args,
-				Parse: parse,
-			}
-			if err := db.Interceptor.BeforeExec(ctx, execSQL); err != nil {
-				return nil, err
-			}
-		}
-		result, err := db.ExecContext(ctx, query, args...)
-		if db.Interceptor != nil && err == nil {
-			db.Interceptor.BehindExec(ctx, execSQL, result)
-		}
-		return result, err
+		return interceptExec(ctx, db.Interceptor, db.DB, query, args...)
 	}
 	return nil, fmt.Errorf("name not found: %v", name)
 }
@@ -117,17 +104,7 @@ func PrepareN(name, query string) (*sql.Stmt, error) {
 // PrepareContextN ...
 func PrepareContextN(ctx context.Context, name, query string) (*sql.Stmt, error) {
 	if db, ok := mutil[name]; ok {
-		if db.Interceptor != nil {
-			parse, _ := sqlparser.Parse(query)
-			execSQL := &sqlplus.ExecSQL{
-				Query: query,
-				Parse: parse,
-			}
-			if err := db.Interceptor.BeforeExec(ctx, execSQL); err != nil {
-				return nil, err
-			}
-		}
-		return db.PrepareContext(ctx, query)
+		return interceptPrepare(ctx, db.Interceptor, db.DB, query)
 	}
 	return nil, fmt.Errorf("name not found: %v", name)
 }
@@ -140,23 +117,7 @@ func QueryN(name, query string, args ...interface{}) (*sql.Rows, error) {
 // QueryContextN ...
 func QueryContextN(ctx context.Context, name, query string, args ...interface{}) (*sql.Rows, error) {
 	if db, ok := mutil[name]; ok {
-		var execSQL *sqlplus.ExecSQL
-		if db.Interceptor != nil {
-			parse, _ := sqlparser.Parse(query)
-			execSQL = &sqlplus.ExecSQL{
-				Query: query,
-				Args:  args,
-				Parse: parse,
-			}
-			if err := db.Interceptor.BeforeExec(ctx, execSQL); err != nil {
-				return nil, err
-			}
-		}
-		rows, err := db.QueryContext(ctx, query, args...)
-		if db.Interceptor != nil && err == nil {
-			db.Interceptor.BehindExec(ctx, execSQL, nil)
-		}
-		return rows, err
+		return interceptQuery(ctx, db.Interceptor, db.reader(ctx, query), query, args...)
 	}
 	return nil, fmt.Errorf("name not found: %v", name)
 }
@@ -169,23 +130,7 @@ func QueryRowN(name, query string, args ...interface{}) (*sql.Row, error) {
 // QueryRowContextN ...
 func QueryRowContextN(ctx context.Context, name, query string, args ...interface{}) (*sql.Row, error) {
 	if db, ok := mutil[name]; ok {
-		var execSQL *sqlplus.ExecSQL
-		if db.Interceptor != nil {
-			parse, _ := sqlparser.Parse(query)
-			execSQL = &sqlplus.ExecSQL{
-				Query: query,
-				Args:  args,
-				Parse: parse,
-			}
-			if err := db.Interceptor.BeforeExec(ctx, execSQL); err != nil {
-				return nil, err
-			}
-		}
-		row := db.QueryRowContext(ctx, query, args...)
-		if db.Interceptor != nil {
-			db.Interceptor.BehindExec(ctx, execSQL, nil)
-		}
-		return row, nil
+		return interceptQueryRow(ctx, db.Interceptor, db.reader(ctx, query), query, args...)
 	}
 	return nil, fmt.Errorf("name not found: %v", name)
 }
@@ -216,11 +161,7 @@ func GetN(name string, v interface{}, query string, args ...interface{}) error {
 // GetContextN ...
 func GetContextN(ctx context.Context, name string, v interface{}, query string, args ...interface{}) error {
 	if db, ok := mutil[name]; ok {
-		rows, err := db.QueryContext(ctx, query, args...)
-		if err != nil {
-			return err
-		}
-		return sqlplus.Get(rows, v)
+		return interceptGet(ctx, db.Interceptor, db.reader(ctx, query), v, query, args...)
 	}
 	return fmt.Errorf("name not found: %v", name)
 }
@@ -243,15 +184,67 @@ func SelectN(name string, v interface{}, query string, args ...interface{}) erro
 // SelectContextN ...
 func SelectContextN(ctx context.Context, name string, v interface{}, query string, args ...interface{}) error {
 	if db, ok := mutil[name]; ok {
-		rows, err := db.QueryContext(ctx, query, args...)
-		if err != nil {
-			return err
-		}
-		return sqlplus.Select(rows, v)
+		return interceptSelect(ctx, db.Interceptor, db.reader(ctx, query), v, query, args...)
 	}
 	return fmt.Errorf("name not found: %v", name)
 }
 
+// SelectEach 逐行读取不缓存结果, 适合大结果集; fn 为 func(T) error, 返回 sqlplus.ErrStop 提前结束
+func SelectEach(ctx context.Context, name, query string, fn interface{}, args ...interface{}) error {
+	if db, ok := mutil[name]; ok {
+		return interceptEach(ctx, db.Interceptor, db.reader(ctx, query), query, fn, args...)
+	}
+	return fmt.Errorf("name not found: %v", name)
+}
+
+// GetBy 使用构造器查询
+func GetBy(v interface{}, builder sqlplus.Builder) error {
+	return GetByContextN(context.Background(), d, v, builder)
+}
+
+// GetByContext ...
+func GetByContext(ctx context.Context, v interface{}, builder sqlplus.Builder) error {
+	return GetByContextN(ctx, d, v, builder)
+}
+
+// GetByN ...
+func GetByN(name string, v interface{}, builder sqlplus.Builder) error {
+	return GetByContextN(context.Background(), name, v, builder)
+}
+
+// GetByContextN ...
+func GetByContextN(ctx context.Context, name string, v interface{}, builder sqlplus.Builder) error {
+	query, args, err := builder.Build()
+	if err != nil {
+		return err
+	}
+	return GetContextN(ctx, name, v, query, args...)
+}
+
+// SelectBy 使用构造器查询
+func SelectBy(v interface{}, builder sqlplus.Builder) error {
+	return SelectByContextN(context.Background(), d, v, builder)
+}
+
+// SelectByContext ...
+func SelectByContext(ctx context.Context, v interface{}, builder sqlplus.Builder) error {
+	return SelectByContextN(ctx, d, v, builder)
+}
+
+// SelectByN ...
+func SelectByN(name string, v interface{}, builder sqlplus.Builder) error {
+	return SelectByContextN(context.Background(), name, v, builder)
+}
+
+// SelectByContextN ...
+func SelectByContextN(ctx context.Context, name string, v interface{}, builder sqlplus.Builder) error {
+	query, args, err := builder.Build()
+	if err != nil {
+		return err
+	}
+	return SelectContextN(ctx, name, v, query, args...)
+}
+
 // Insert ...
 func Insert(tableName string, v interface{}) (sql.Result, error) {
 	return InsertContextN(context.Background(), d, tableName, v)
@@ -270,8 +263,54 @@ func InsertN(name, tableName string, v interface{}) (sql.Result, error) {
 // InsertContextN ...
 func InsertContextN(ctx context.Context, name, tableName string, v interface{}) (sql.Result, error) {
 	if db, ok := mutil[name]; ok {
-		query, args := sqlplus.GenInsert(tableName, v)
-		return db.ExecContext(ctx, query, args...)
+		return interceptInsert(ctx, db.Interceptor, db.DB, tableName, v)
+	}
+	return nil, fmt.Errorf("name not found: %v", name)
+}
+
+// Update 按主键更新 见 UpdateContextN
+func Update(tableName string, v interface{}, fields ...string) (sql.Result, error) {
+	return UpdateContextN(context.Background(), d, tableName, v, fields...)
+}
+
+// UpdateContext ...
+func UpdateContext(ctx context.Context, tableName string, v interface{}, fields ...string) (sql.Result, error) {
+	return UpdateContextN(ctx, d, tableName, v, fields...)
+}
+
+// UpdateN ...
+func UpdateN(name, tableName string, v interface{}, fields ...string) (sql.Result, error) {
+	return UpdateContextN(context.Background(), name, tableName, v, fields...)
+}
+
+// UpdateContextN 按主键更新 fields 为空则更新全部非主键列
+// 有 version 字段时为乐观锁更新: 影响 0 行返回 sqlplus.ErrStaleVersion, 成功则 v 的 version 加 1
+func UpdateContextN(ctx context.Context, name, tableName string, v interface{}, fields ...string) (sql.Result, error) {
+	if db, ok := mutil[name]; ok {
+		return interceptUpdate(ctx, db.Interceptor, db.DB, tableName, v, fields...)
+	}
+	return nil, fmt.Errorf("name not found: %v", name)
+}
+
+// Delete 按主键删除 见 DeleteContextN
+func Delete(tableName string, v interface{}) (sql.Result, error) {
+	return DeleteContextN(context.Background(), d, tableName, v)
+}
+
+// DeleteContext ...
+func DeleteContext(ctx context.Context, tableName string, v interface{}) (sql.Result, error) {
+	return DeleteContextN(ctx, d, tableName, v)
+}
+
+// DeleteN ...
+func DeleteN(name, tableName string, v interface{}) (sql.Result, error) {
+	return DeleteContextN(context.Background(), name, tableName, v)
+}
+
+// DeleteContextN 按主键删除 结构体有 deleted 字段时为软删除
+func DeleteContextN(ctx context.Context, name, tableName string, v interface{}) (sql.Result, error) {
+	if db, ok := mutil[name]; ok {
+		return interceptDelete(ctx, db.Interceptor, db.DB, tableName, v)
 	}
 	return nil, fmt.Errorf("name not found: %v", name)
 }